@@ -18,6 +18,7 @@ package types
 
 import (
 	"github.com/rulego/rulego/pool"
+	"github.com/rulego/rulego/state"
 	"math"
 	"sort"
 	"time"
@@ -60,8 +61,20 @@ type Config struct {
 	//Udf 注册自定义Golang函数和原生脚本，js等脚本引擎运行时可以调用
 	//不同脚本类型函数名可以重复
 	Udf map[string]interface{}
+	//JsModules js模块库，key:模块名称，value:模块脚本内容
+	//脚本可以通过`require('模块名称')`方式引用，模块使用CommonJS规范，通过`module.exports`或者`exports`导出
+	JsModules map[string]string
+	//DisableJsBuiltins 是否禁用js内置函数库($encoding、$crypto、$uuid、$time)，默认不禁用
+	//函数列表参考：`js.Builtins`
+	DisableJsBuiltins bool
 	//Aspects AOP切面列表
 	Aspects []Aspect
+	//StateStore 跨消息的状态存储，组件、js和expr脚本可以通过它读写状态
+	//默认使用内存存储：`state.NewMemoryStore()`
+	StateStore StateStore
+	//HostFunctions js脚本通过`$ctx.call(functionName, msg)`调用的函数库
+	//默认使用`action.Functions`
+	HostFunctions FunctionsGetter
 }
 
 // RegisterUdf 注册自定义函数
@@ -77,6 +90,15 @@ func (c *Config) RegisterUdf(name string, value interface{}) {
 	c.Udf[name] = value
 }
 
+// RegisterJsModule 注册js模块
+// 脚本可以通过`require('模块名称')`方式引用该模块
+func (c *Config) RegisterJsModule(name string, source string) {
+	if c.JsModules == nil {
+		c.JsModules = make(map[string]string)
+	}
+	c.JsModules[name] = source
+}
+
 // GetNodeAspects 获取节点执行类型增强点切面列表
 func (c *Config) GetNodeAspects() ([]AroundAspect, []BeforeAspect, []AfterAspect) {
 
@@ -166,6 +188,7 @@ func NewConfig(opts ...Option) Config {
 		ScriptMaxExecutionTime: time.Millisecond * 2000,
 		Logger:                 DefaultLogger(),
 		Properties:             NewMetadata(),
+		StateStore:             state.NewMemoryStore(),
 	}
 
 	// Apply the options to the Config.
@@ -238,6 +261,22 @@ func WithLogger(logger Logger) Option {
 	}
 }
 
+// WithStateStore is an option that sets the state store of the Config.
+func WithStateStore(store StateStore) Option {
+	return func(c *Config) error {
+		c.StateStore = store
+		return nil
+	}
+}
+
+// WithHostFunctions is an option that sets the functions called by js scripts through `$ctx.call`.
+func WithHostFunctions(functions FunctionsGetter) Option {
+	return func(c *Config) error {
+		c.HostFunctions = functions
+		return nil
+	}
+}
+
 // WithAspects is an option that sets the aspects of the Config.
 func WithAspects(aspects ...Aspect) Option {
 	return func(c *Config) error {
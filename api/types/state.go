@@ -0,0 +1,99 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// 状态作用域
+const (
+	// StateScopeGlobal 所有规则链共享
+	StateScopeGlobal = "global"
+	// StateScopeChain 同一规则链内共享
+	StateScopeChain = "chain"
+	// StateScopeNode 只在当前节点内可见
+	StateScopeNode = "node"
+)
+
+// StateStore 跨消息的状态存储接口，例如：计数器、上一次的值、最近的状态等
+// 默认使用内存存储，可以通过 `types.WithStateStore` 替换成文件或者redis等实现
+type StateStore interface {
+	// Get 获取key的值，第二个返回值表示是否存在
+	Get(key string) (interface{}, bool, error)
+	// Set 设置key的值，ttl<=0表示永不过期
+	Set(key string, value interface{}, ttl time.Duration) error
+	// Incr 在key的值上增加delta并返回增加后的值，key不存在则从0开始
+	// ttl>0 刷新过期时间，ttl<=0 保持原过期时间
+	Incr(key string, delta float64, ttl time.Duration) (float64, error)
+	// Delete 删除key
+	Delete(key string) error
+}
+
+// ScopedStateStore 按作用域给key添加前缀的状态存储
+type ScopedStateStore struct {
+	Store  StateStore
+	Prefix string
+}
+
+func (s *ScopedStateStore) Get(key string) (interface{}, bool, error) {
+	return s.Store.Get(s.Prefix + key)
+}
+
+func (s *ScopedStateStore) Set(key string, value interface{}, ttl time.Duration) error {
+	return s.Store.Set(s.Prefix+key, value, ttl)
+}
+
+func (s *ScopedStateStore) Incr(key string, delta float64, ttl time.Duration) (float64, error) {
+	return s.Store.Incr(s.Prefix+key, delta, ttl)
+}
+
+func (s *ScopedStateStore) Delete(key string) error {
+	return s.Store.Delete(s.Prefix + key)
+}
+
+// ScopedState 获取当前上下文指定作用域的状态存储，node作用域使用当前节点的状态
+// global:key chain:规则链ID:key node:规则链ID:节点ID:key
+func ScopedState(ctx RuleContext, scope string) (StateStore, error) {
+	return NodeScopedState(ctx, scope, "")
+}
+
+// NodeScopedState 获取当前上下文指定作用域的状态存储，node作用域使用同一规则链nodeId节点的状态，nodeId为空则使用当前节点ID
+func NodeScopedState(ctx RuleContext, scope string, nodeId string) (StateStore, error) {
+	store := ctx.StateStore()
+	if store == nil {
+		return nil, fmt.Errorf("state store is not configured")
+	}
+	var chainId string
+	if chain := ctx.RuleChain(); chain != nil {
+		chainId = chain.GetNodeId().Id
+	}
+	switch scope {
+	case StateScopeGlobal:
+		return &ScopedStateStore{Store: store, Prefix: StateScopeGlobal + ":"}, nil
+	case "", StateScopeChain:
+		return &ScopedStateStore{Store: store, Prefix: StateScopeChain + ":" + chainId + ":"}, nil
+	case StateScopeNode:
+		if nodeId == "" {
+			nodeId = ctx.GetSelfId()
+		}
+		return &ScopedStateStore{Store: store, Prefix: StateScopeNode + ":" + chainId + ":" + nodeId + ":"}, nil
+	default:
+		return nil, fmt.Errorf("unsupported state scope: %s", scope)
+	}
+}
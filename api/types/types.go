@@ -41,6 +41,7 @@ const (
 	Js     = "Js"
 	Lua    = "Lua"
 	Python = "Python"
+	Expr   = "Expr"
 )
 
 // OnEndFunc 规则链分支执行完函数
@@ -193,6 +194,8 @@ type RuleContext interface {
 	ExecuteNode(chanCtx context.Context, nodeId string, msg RuleMsg, skipTellNext bool, onEnd OnEndFunc)
 	//DoOnEnd 触发 OnEnd 回调函数
 	DoOnEnd(msg RuleMsg, err error, relationType string)
+	//StateStore 获取状态存储，按作用域使用参考：`types.ScopedState`
+	StateStore() StateStore
 }
 
 // RuleContextOption 修改RuleContext选项的函数
@@ -242,6 +245,18 @@ type JsEngine interface {
 	Stop()
 }
 
+// JsContextEngine 支持脚本回调规则引擎的JavaScript脚本引擎
+type JsContextEngine interface {
+	JsEngine
+	//ExecuteWithContext 执行js脚本指定函数，并在脚本中注入`$ctx`宿主对象
+	ExecuteWithContext(ctx RuleContext, msg RuleMsg, functionName string, argumentList ...interface{}) (interface{}, error)
+}
+
+// FunctionsGetter 通过函数名获取注册的处理函数
+type FunctionsGetter interface {
+	Get(functionName string) (func(ctx RuleContext, msg RuleMsg), bool)
+}
+
 // Parser 规则链定义文件DSL解析器
 // 默认使用json方式，如果使用其他方式定义规则链，可以实现该接口
 // 然后通过该方式注册到规则引擎中：`rulego.NewConfig(WithParser(&MyParser{})`
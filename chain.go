@@ -53,6 +53,11 @@ type RuleChainCtx struct {
 	nodeCtxRoutes map[types.RuleNodeId][]types.NodeCtx
 	//通过入节点查询指定关系出节点列表缓存
 	relationCache map[RelationCache][]types.NodeCtx
+	//节点的入节点ID列表，按连接定义顺序，用于WaitForAll节点合并分支消息
+	inNodeIds map[types.RuleNodeId][]string
+	//等待合并的分支消息
+	joins  map[joinKey]*pendingJoin
+	joinMu sync.Mutex
 	//根上下文
 	rootRuleContext types.RuleContext
 	//子规则链池
@@ -72,6 +77,8 @@ func InitRuleChainCtx(config types.Config, ruleChainDef *RuleChain) (*RuleChainC
 		nodes:              make(map[types.RuleNodeId]types.NodeCtx),
 		nodeRoutes:         make(map[types.RuleNodeId][]types.RuleNodeRelation),
 		relationCache:      make(map[RelationCache][]types.NodeCtx),
+		inNodeIds:          make(map[types.RuleNodeId][]string),
+		joins:              make(map[joinKey]*pendingJoin),
 		componentsRegistry: config.ComponentsRegistry,
 		initialized:        true,
 	}
@@ -110,6 +117,7 @@ func InitRuleChainCtx(config types.Config, ruleChainDef *RuleChain) (*RuleChainC
 			nodeRelations = []types.RuleNodeRelation{ruleNodeRelation}
 		}
 		ruleChainCtx.nodeRoutes[inNodeId] = nodeRelations
+		ruleChainCtx.addInNodeId(outNodeId, item.FromId)
 	}
 	//加载子规则链
 	for _, item := range ruleChainDef.Metadata.RuleChainConnections {
@@ -142,6 +150,16 @@ func InitRuleChainCtx(config types.Config, ruleChainDef *RuleChain) (*RuleChainC
 	return ruleChainCtx, nil
 }
 
+// addInNodeId 记录节点的入节点，同一个入节点只记录一次
+func (rc *RuleChainCtx) addInNodeId(id types.RuleNodeId, inNodeId string) {
+	for _, item := range rc.inNodeIds[id] {
+		if item == inNodeId {
+			return
+		}
+	}
+	rc.inNodeIds[id] = append(rc.inNodeIds[id], inNodeId)
+}
+
 func (rc *RuleChainCtx) GetNodeById(id types.RuleNodeId) (types.NodeCtx, bool) {
 	rc.RLock()
 	defer rc.RUnlock()
@@ -241,6 +259,7 @@ func (rc *RuleChainCtx) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 }
 
 func (rc *RuleChainCtx) Destroy() {
+	rc.stopJoins()
 	rc.RLock()
 	defer rc.RUnlock()
 	for _, v := range rc.nodes {
@@ -305,6 +324,7 @@ func (rc *RuleChainCtx) Copy(newCtx *RuleChainCtx) {
 	rc.nodeIds = newCtx.nodeIds
 	rc.nodes = newCtx.nodes
 	rc.nodeRoutes = newCtx.nodeRoutes
+	rc.inNodeIds = newCtx.inNodeIds
 	rc.rootRuleContext = newCtx.rootRuleContext
 	rc.ruleChainPool = newCtx.ruleChainPool
 	rc.reloadAspects = newCtx.reloadAspects
@@ -0,0 +1,205 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "aggregate",
+//        "name": "合并",
+//        "debugMode": false,
+//        "configuration": {
+//          "timeout": 60000
+//        }
+//  }
+import (
+	"errors"
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AggregateTimeoutErr 等待拆分消息超时
+var AggregateTimeoutErr = errors.New("aggregate timeout")
+
+func init() {
+	Registry.Add(&AggregateNode{})
+}
+
+// AggregateNodeConfiguration 节点配置
+type AggregateNodeConfiguration struct {
+	//Timeout 等待同一关联ID所有拆分消息的超时时间，单位毫秒
+	Timeout int64
+	//MaxPending 最多同时等待的关联ID数量，超过后新关联ID的消息发送到`Failure`链
+	MaxPending int
+	//MaxParts 同一关联ID最多的拆分消息数量，拆分总数超过该值的消息发送到`Failure`链
+	MaxParts int
+}
+
+// AggregateNode 合并`split`节点拆分的消息，根据元数据中的关联ID、下标和总数，等待所有拆分消息到达后
+// 按下标顺序把消息体合并成JSON数组，通过`Success`链发送到下一个节点，输出消息使用第一条拆分消息的消息类型和元数据
+// 如果超时，则把已收到的消息合并(缺失的元素为null)，通过`Failure`链发送到下一个节点
+// 如果消息缺少拆分元数据，则发送到`Failure`链
+type AggregateNode struct {
+	//节点配置
+	Config AggregateNodeConfiguration
+	//等待合并的消息，key:关联ID
+	pending map[string]*aggregateGroup
+	mu      sync.Mutex
+}
+
+// aggregateGroup 同一关联ID的拆分消息
+type aggregateGroup struct {
+	parts    []*types.RuleMsg
+	received int
+	//最后一条消息的上下文，用于输出
+	ctx   types.RuleContext
+	timer *time.Timer
+}
+
+// Type 组件类型
+func (x *AggregateNode) Type() string {
+	return "aggregate"
+}
+
+func (x *AggregateNode) New() types.Node {
+	return &AggregateNode{Config: AggregateNodeConfiguration{
+		Timeout:    60000,
+		MaxPending: 10000,
+		MaxParts:   10000,
+	}}
+}
+
+// Init 初始化
+func (x *AggregateNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.Config)
+	if x.Config.Timeout <= 0 {
+		x.Config.Timeout = 60000
+	}
+	if x.Config.MaxPending <= 0 {
+		x.Config.MaxPending = 10000
+	}
+	if x.Config.MaxParts <= 0 {
+		x.Config.MaxParts = 10000
+	}
+	x.pending = make(map[string]*aggregateGroup)
+	return err
+}
+
+// OnMsg 处理消息
+func (x *AggregateNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	correlationId := msg.Metadata.GetValue(SplitCorrelationIdKey)
+	index, indexErr := strconv.Atoi(msg.Metadata.GetValue(SplitIndexKey))
+	total, totalErr := strconv.Atoi(msg.Metadata.GetValue(SplitTotalKey))
+	if correlationId == "" || indexErr != nil || totalErr != nil || total <= 0 || index < 0 || index >= total {
+		ctx.TellFailure(msg, errors.New("invalid split metadata"))
+		return
+	}
+	if total > x.Config.MaxParts {
+		ctx.TellFailure(msg, fmt.Errorf("max limit of split parts"))
+		return
+	}
+	x.mu.Lock()
+	group, ok := x.pending[correlationId]
+	if !ok {
+		if len(x.pending) >= x.Config.MaxPending {
+			x.mu.Unlock()
+			ctx.TellFailure(msg, fmt.Errorf("max limit of pending aggregations"))
+			return
+		}
+		group = &aggregateGroup{parts: make([]*types.RuleMsg, total)}
+		group.timer = time.AfterFunc(time.Duration(x.Config.Timeout)*time.Millisecond, func() {
+			x.mu.Lock()
+			if x.pending[correlationId] != group {
+				x.mu.Unlock()
+				return
+			}
+			delete(x.pending, correlationId)
+			x.mu.Unlock()
+			x.emit(group, AggregateTimeoutErr)
+		})
+		x.pending[correlationId] = group
+	} else if len(group.parts) != total {
+		x.mu.Unlock()
+		ctx.TellFailure(msg, errors.New("invalid split metadata"))
+		return
+	}
+	if group.parts[index] == nil {
+		group.received++
+	}
+	group.parts[index] = &msg
+	group.ctx = ctx
+	completed := group.received == total
+	if completed {
+		group.timer.Stop()
+		delete(x.pending, correlationId)
+	}
+	x.mu.Unlock()
+
+	if completed {
+		x.emit(group, nil)
+	}
+}
+
+// Destroy 销毁，丢弃等待合并的消息
+func (x *AggregateNode) Destroy() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, group := range x.pending {
+		group.timer.Stop()
+	}
+	x.pending = make(map[string]*aggregateGroup)
+}
+
+// emit 按下标顺序合并消息并输出，err不为空则通过`Failure`链输出
+func (x *AggregateNode) emit(group *aggregateGroup, err error) {
+	var list = make([]interface{}, len(group.parts))
+	var first *types.RuleMsg
+	for i, part := range group.parts {
+		if part == nil {
+			continue
+		}
+		if first == nil {
+			first = part
+		}
+		var data interface{} = part.Data
+		if part.DataType == types.JSON {
+			var dataMap interface{}
+			if jsonErr := json.Unmarshal([]byte(part.Data), &dataMap); jsonErr == nil {
+				data = dataMap
+			}
+		}
+		list[i] = data
+	}
+	metadata := first.Metadata.Copy()
+	delete(metadata, SplitIndexKey)
+	data, jsonErr := json.Marshal(list)
+	if jsonErr != nil {
+		group.ctx.TellFailure(*first, jsonErr)
+		return
+	}
+	msg := group.ctx.NewMsg(first.Type, metadata, string(data))
+	if err != nil {
+		group.ctx.TellFailure(msg, err)
+	} else {
+		group.ctx.TellSuccess(msg)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAggregateNode(t *testing.T) {
+	var targetNodeType = "aggregate"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &AggregateNode{}, types.Configuration{
+			"timeout":    int64(60000),
+			"maxPending": 10000,
+			"maxParts":   10000,
+		}, Registry)
+	})
+
+	t.Run("DefaultConfig", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"timeout":    0,
+			"maxPending": 0,
+			"maxParts":   0,
+		}, types.Configuration{
+			"timeout":    int64(60000),
+			"maxPending": 10000,
+			"maxParts":   10000,
+		}, Registry)
+	})
+
+	var newPart = func(correlationId string, index, total int, data string) test.Msg {
+		metaData := types.NewMetadata()
+		metaData.PutValue("productType", "test")
+		metaData.PutValue(SplitCorrelationIdKey, correlationId)
+		metaData.PutValue(SplitIndexKey, strconv.Itoa(index))
+		metaData.PutValue(SplitTotalKey, strconv.Itoa(total))
+		return test.Msg{MetaData: metaData, MsgType: "TEST", Data: data}
+	}
+
+	type result struct {
+		relationType string
+		msg          types.RuleMsg
+		err          error
+	}
+	var run = func(config types.Configuration, msgList []test.Msg) []result {
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		var mu sync.Mutex
+		var results []result
+		test.NodeOnMsg(t, node, msgList, func(msg types.RuleMsg, relationType string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, result{relationType: relationType, msg: msg, err: err})
+		})
+		mu.Lock()
+		defer mu.Unlock()
+		return results
+	}
+
+	t.Run("OnMsg", func(t *testing.T) {
+		results := run(types.Configuration{}, []test.Msg{
+			newPart("a", 2, 3, "3"),
+			newPart("b", 0, 1, `{"name":"b"}`),
+			newPart("a", 0, 3, `{"name":"aa"}`),
+			//重复的消息
+			newPart("a", 0, 3, `{"name":"aa"}`),
+			newPart("a", 1, 3, "bb"),
+		})
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, types.Success, results[0].relationType)
+		assert.Equal(t, `[{"name":"b"}]`, results[0].msg.Data)
+		assert.Equal(t, types.Success, results[1].relationType)
+		assert.Equal(t, `[{"name":"aa"},"bb",3]`, results[1].msg.Data)
+		assert.Equal(t, "TEST", results[1].msg.Type)
+		assert.Equal(t, "test", results[1].msg.Metadata.GetValue("productType"))
+		assert.Equal(t, "a", results[1].msg.Metadata.GetValue(SplitCorrelationIdKey))
+		assert.False(t, results[1].msg.Metadata.Has(SplitIndexKey))
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		msgList := []test.Msg{
+			newPart("a", 0, 3, `"aa"`),
+			newPart("a", 2, 3, `"cc"`),
+		}
+		msgList[1].AfterSleep = time.Millisecond * 300
+		results := run(types.Configuration{"timeout": 100}, msgList)
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, types.Failure, results[0].relationType)
+		assert.Equal(t, AggregateTimeoutErr, results[0].err)
+		assert.Equal(t, `["aa",null,"cc"]`, results[0].msg.Data)
+	})
+
+	t.Run("OnMsgFailure", func(t *testing.T) {
+		results := run(types.Configuration{"maxPending": 1}, []test.Msg{
+			{MetaData: types.NewMetadata(), Data: "aa"},
+			newPart("a", 3, 3, "aa"),
+			newPart("a", 0, 2, "aa"),
+			newPart("a", 0, 3, "aa"),
+			newPart("b", 0, 2, "bb"),
+		})
+		assert.Equal(t, 4, len(results))
+		for _, item := range results {
+			assert.Equal(t, types.Failure, item.relationType)
+		}
+	})
+
+	t.Run("MaxParts", func(t *testing.T) {
+		results := run(types.Configuration{"maxParts": 2}, []test.Msg{
+			newPart("a", 0, 3, "aa"),
+			newPart("b", 0, 2, `"bb"`),
+			newPart("b", 1, 2, `"cc"`),
+		})
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, types.Failure, results[0].relationType)
+		assert.Equal(t, "max limit of split parts", results[0].err.Error())
+		assert.Equal(t, types.Success, results[1].relationType)
+		assert.Equal(t, `["bb","cc"]`, results[1].msg.Data)
+	})
+}
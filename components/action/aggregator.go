@@ -0,0 +1,155 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"fmt"
+	"github.com/rulego/rulego/utils/num"
+	"strings"
+)
+
+// 聚合函数
+const (
+	AggregateCount   = "count"
+	AggregateSum     = "sum"
+	AggregateMin     = "min"
+	AggregateMax     = "max"
+	AggregateAvg     = "avg"
+	AggregateFirst   = "first"
+	AggregateLast    = "last"
+	AggregateCollect = "collect"
+)
+
+// aggregateFunctions 支持的聚合函数
+var aggregateFunctions = map[string]bool{
+	AggregateCount:   true,
+	AggregateSum:     true,
+	AggregateMin:     true,
+	AggregateMax:     true,
+	AggregateAvg:     true,
+	AggregateFirst:   true,
+	AggregateLast:    true,
+	AggregateCollect: true,
+}
+
+// parseAggregations 解析聚合函数列表，多个与`,`隔开，为空则使用默认值
+func parseAggregations(aggregations string, defaultValue string) ([]string, error) {
+	if strings.TrimSpace(aggregations) == "" {
+		aggregations = defaultValue
+	}
+	var result []string
+	for _, item := range strings.Split(aggregations, ",") {
+		if v := strings.TrimSpace(item); v != "" {
+			if !aggregateFunctions[v] {
+				return nil, fmt.Errorf("unsupported aggregation function:%s", v)
+			}
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
+// aggregator 聚合计算器，count、first、last、collect 统计所有值，sum、min、max、avg 只统计数值
+type aggregator struct {
+	collect  bool
+	count    int64
+	numCount int64
+	sum      float64
+	min      float64
+	max      float64
+	first    interface{}
+	last     interface{}
+	values   []interface{}
+}
+
+func newAggregator(aggregations []string) *aggregator {
+	agg := &aggregator{}
+	for _, item := range aggregations {
+		if item == AggregateCollect {
+			agg.collect = true
+		}
+	}
+	return agg
+}
+
+// add 添加一个值
+func (a *aggregator) add(value interface{}) {
+	if a.count == 0 {
+		a.first = value
+	}
+	a.count++
+	a.last = value
+	if a.collect {
+		a.values = append(a.values, value)
+	}
+	if v, err := num.ToFloat64(value); err == nil {
+		if a.numCount == 0 || v < a.min {
+			a.min = v
+		}
+		if a.numCount == 0 || v > a.max {
+			a.max = v
+		}
+		a.numCount++
+		a.sum += v
+	}
+}
+
+// size 已收集的值数量
+func (a *aggregator) size() int {
+	return len(a.values)
+}
+
+// result 计算结果，没有数值时min、max、avg为nil
+func (a *aggregator) result(aggregations []string) map[string]interface{} {
+	var result = make(map[string]interface{})
+	for _, item := range aggregations {
+		switch item {
+		case AggregateCount:
+			result[item] = a.count
+		case AggregateSum:
+			result[item] = a.sum
+		case AggregateMin:
+			result[item] = a.numValue(a.min)
+		case AggregateMax:
+			result[item] = a.numValue(a.max)
+		case AggregateAvg:
+			if a.numCount > 0 {
+				result[item] = a.sum / float64(a.numCount)
+			} else {
+				result[item] = nil
+			}
+		case AggregateFirst:
+			result[item] = a.first
+		case AggregateLast:
+			result[item] = a.last
+		case AggregateCollect:
+			if a.values == nil {
+				result[item] = []interface{}{}
+			} else {
+				result[item] = a.values
+			}
+		}
+	}
+	return result
+}
+
+func (a *aggregator) numValue(v float64) interface{} {
+	if a.numCount == 0 {
+		return nil
+	}
+	return v
+}
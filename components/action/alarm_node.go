@@ -0,0 +1,353 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "alarm",
+//        "name": "高温告警",
+//        "debugMode": false,
+//        "configuration": {
+//          "originator": "${deviceId}",
+//          "alarmType": "HighTemperature",
+//          "severity": "MAJOR",
+//          "condition": "msg.temperature > 50",
+//          "clearCondition": "msg.temperature < 40"
+//        }
+//  }
+import (
+	"fmt"
+	"github.com/expr-lang/expr/vm"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/expr"
+	"github.com/rulego/rulego/components/js"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 告警节点关系类型
+const (
+	//AlarmCreated 创建告警
+	AlarmCreated = "Created"
+	//AlarmUpdated 更新活动告警
+	AlarmUpdated = "Updated"
+	//AlarmCleared 清除告警
+	AlarmCleared = "Cleared"
+)
+
+// 告警状态
+const (
+	AlarmStatusActive  = "ACTIVE"
+	AlarmStatusCleared = "CLEARED"
+)
+
+// 注册节点
+func init() {
+	Registry.Add(&AlarmNode{})
+}
+
+// Alarm 告警
+type Alarm struct {
+	//Originator 告警发起者，例如设备ID
+	Originator string `json:"originator"`
+	//Type 告警类型
+	Type string `json:"type"`
+	//Severity 告警级别
+	Severity string `json:"severity"`
+	//Status 告警状态：ACTIVE、CLEARED
+	Status string `json:"status"`
+	//StartTs 创建时间，毫秒
+	StartTs int64 `json:"startTs"`
+	//UpdateTs 最后触发时间，毫秒
+	UpdateTs int64 `json:"updateTs"`
+	//EndTs 清除时间，毫秒
+	EndTs int64 `json:"endTs"`
+	//Count 告警期间条件满足的次数
+	Count int64 `json:"count"`
+}
+
+// AlarmNodeConfiguration 节点配置
+type AlarmNodeConfiguration struct {
+	//Originator 告警发起者，可以使用${metadataKey}方式从metadata获取
+	Originator string
+	//AlarmType 告警类型，可以使用${metadataKey}方式从metadata获取
+	AlarmType string
+	//Severity 告警级别，可以使用${metadataKey}方式从metadata获取，例如：CRITICAL、MAJOR、MINOR、WARNING
+	Severity string
+	//ScriptType 条件脚本类型：Expr(expr-lang表达式)、Js(js函数体，function(msg, metadata, msgType) {...}，返回bool)
+	ScriptType string
+	//Condition 创建或者更新告警条件
+	Condition string
+	//ClearCondition 清除告警条件，为空则不满足Condition时清除
+	ClearCondition string
+	//Scope 告警状态保存的作用域：global、chain、node，默认chain
+	Scope string
+}
+
+// AlarmNode 告警生命周期节点，按告警发起者和告警类型维护告警状态
+// 没有活动告警并且满足Condition，则创建告警，通过`Created`链发送
+// 有活动告警并且满足ClearCondition，则清除告警，通过`Cleared`链发送；否则如果满足Condition，则更新告警，通过`Updated`链发送
+// 发送的消息体为告警JSON，格式参考`Alarm`，告警状态没有变化则把原消息通过`Success`链发送，脚本执行失败则发送到`Failure`链
+// 告警状态保存在`types.Config.StateStore`，默认是内存存储，规则引擎重启后活动告警会丢失，
+// 需要重启后保留告警状态，需要通过`types.WithStateStore`配置持久化的状态存储
+type AlarmNode struct {
+	//节点配置
+	Config         AlarmNodeConfiguration
+	udf            map[string]interface{}
+	condition      *vm.Program
+	clearCondition *vm.Program
+	jsEngine       types.JsEngine
+	//js脚本是否使用`$ctx`宿主对象
+	withContext bool
+	//保证同一个告警读取和修改的原子性，不同告警互不阻塞
+	locks alarmLocks
+}
+
+// Type 组件类型
+func (x *AlarmNode) Type() string {
+	return "alarm"
+}
+
+func (x *AlarmNode) New() types.Node {
+	return &AlarmNode{Config: AlarmNodeConfiguration{
+		Originator: "${deviceId}",
+		AlarmType:  "General Alarm",
+		Severity:   "MAJOR",
+		ScriptType: types.Expr,
+		Condition:  "msg.temperature > 50",
+		Scope:      types.StateScopeChain,
+	}}
+}
+
+// Init 初始化
+func (x *AlarmNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.Config); err != nil {
+		return err
+	}
+	if strings.TrimSpace(x.Config.AlarmType) == "" {
+		return fmt.Errorf("alarmType is empty")
+	}
+	if strings.TrimSpace(x.Config.Condition) == "" {
+		return fmt.Errorf("condition is empty")
+	}
+	if err := checkStateScope(x.Config.Scope); err != nil {
+		return err
+	}
+	x.udf = expr.UdfEnv(ruleConfig)
+	var err error
+	switch x.Config.ScriptType {
+	case "", types.Expr:
+		if x.condition, err = expr.CompileAsBool(ruleConfig, x.Config.Condition); err != nil {
+			return err
+		}
+		if strings.TrimSpace(x.Config.ClearCondition) != "" {
+			x.clearCondition, err = expr.CompileAsBool(ruleConfig, x.Config.ClearCondition)
+		}
+	case types.Js:
+		jsScript, withContext := js.WrapFunction("Condition", "msg, metadata, msgType", x.Config.Condition)
+		if strings.TrimSpace(x.Config.ClearCondition) != "" {
+			clearScript, clearWithContext := js.WrapFunction("ClearCondition", "msg, metadata, msgType", x.Config.ClearCondition)
+			jsScript += "\n" + clearScript
+			withContext = withContext || clearWithContext
+		}
+		x.withContext = withContext
+		x.jsEngine, err = js.NewGojaJsEngine(ruleConfig, jsScript, nil)
+	default:
+		err = fmt.Errorf("unsupported script type: %s", x.Config.ScriptType)
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *AlarmNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	store, err := types.ScopedState(ctx, x.Config.Scope)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	var data interface{} = msg.Data
+	if msg.DataType == types.JSON {
+		var dataMap interface{}
+		if err := json.Unmarshal([]byte(msg.Data), &dataMap); err == nil {
+			data = dataMap
+		}
+	}
+	metadata := msg.Metadata.Values()
+	originator := str.SprintfDict(x.Config.Originator, metadata)
+	alarmType := str.SprintfDict(x.Config.AlarmType, metadata)
+	key := "alarm:" + alarmType + ":" + originator
+
+	//条件只依赖当前消息，在锁外执行，避免脚本执行阻塞同一个告警的其他消息
+	matched, err := x.evaluate(ctx, msg, data, x.condition, "Condition")
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	//没有配置清除条件则不满足Condition时清除
+	cleared := !matched
+	if x.hasClearCondition() {
+		if cleared, err = x.evaluate(ctx, msg, data, x.clearCondition, "ClearCondition"); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+	}
+
+	x.locks.lock(key)
+	defer x.locks.unlock(key)
+	alarm, err := x.load(store, key)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	now := time.Now().UnixMilli()
+	if alarm != nil && cleared {
+		alarm.Status = AlarmStatusCleared
+		alarm.EndTs = now
+		if err := store.Delete(key); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+		x.tell(ctx, msg, alarm, AlarmCleared)
+		return
+	}
+	if !matched {
+		ctx.TellSuccess(msg)
+		return
+	}
+	relationType := AlarmUpdated
+	if alarm == nil {
+		relationType = AlarmCreated
+		alarm = &Alarm{
+			Originator: originator,
+			Type:       alarmType,
+			Status:     AlarmStatusActive,
+			StartTs:    now,
+		}
+	}
+	alarm.Severity = str.SprintfDict(x.Config.Severity, metadata)
+	alarm.UpdateTs = now
+	alarm.Count++
+	if err := x.save(store, key, alarm); err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	x.tell(ctx, msg, alarm, relationType)
+}
+
+// Destroy 销毁
+func (x *AlarmNode) Destroy() {
+	if x.jsEngine != nil {
+		x.jsEngine.Stop()
+	}
+}
+
+// hasClearCondition 是否配置了清除条件
+func (x *AlarmNode) hasClearCondition() bool {
+	return strings.TrimSpace(x.Config.ClearCondition) != ""
+}
+
+// evaluate 执行条件脚本，program用于expr类型，functionName用于js类型
+func (x *AlarmNode) evaluate(ctx types.RuleContext, msg types.RuleMsg, data interface{}, program *vm.Program, functionName string) (bool, error) {
+	var out interface{}
+	var err error
+	if x.jsEngine != nil {
+		out, err = js.Execute(x.jsEngine, x.withContext, ctx, msg, functionName, data, msg.Metadata.Values(), msg.Type)
+	} else {
+		out, err = expr.Run(program, expr.NewEnvWithContext(ctx, x.udf, msg, data))
+	}
+	if err != nil {
+		return false, err
+	}
+	matched, _ := out.(bool)
+	return matched, nil
+}
+
+// load 从状态存储读取活动告警，不存在返回nil
+func (x *AlarmNode) load(store types.StateStore, key string) (*Alarm, error) {
+	value, ok, err := store.Get(key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	var alarm Alarm
+	if err := json.Unmarshal([]byte(str.ToString(value)), &alarm); err != nil {
+		return nil, err
+	}
+	return &alarm, nil
+}
+
+// save 告警以JSON字符串保存，保证不同状态存储实现读取结果一致
+func (x *AlarmNode) save(store types.StateStore, key string, alarm *Alarm) error {
+	value, err := json.Marshal(alarm)
+	if err != nil {
+		return err
+	}
+	return store.Set(key, string(value), 0)
+}
+
+func (x *AlarmNode) tell(ctx types.RuleContext, msg types.RuleMsg, alarm *Alarm, relationType string) {
+	value, err := json.Marshal(alarm)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.Data = string(value)
+	msg.DataType = types.JSON
+	ctx.TellNext(msg, relationType)
+}
+
+// alarmLocks 按告警加锁，没有使用者的锁会被删除
+type alarmLocks struct {
+	mu    sync.Mutex
+	locks map[string]*alarmLock
+}
+
+type alarmLock struct {
+	mu sync.Mutex
+	//等待或者持有该锁的数量
+	ref int
+}
+
+func (l *alarmLocks) lock(key string) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*alarmLock)
+	}
+	item, ok := l.locks[key]
+	if !ok {
+		item = &alarmLock{}
+		l.locks[key] = item
+	}
+	item.ref++
+	l.mu.Unlock()
+	item.mu.Lock()
+}
+
+func (l *alarmLocks) unlock(key string) {
+	l.mu.Lock()
+	item := l.locks[key]
+	item.ref--
+	if item.ref == 0 {
+		delete(l.locks, key)
+	}
+	l.mu.Unlock()
+	item.mu.Unlock()
+}
@@ -0,0 +1,232 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/state"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"github.com/rulego/rulego/utils/json"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestAlarmNode(t *testing.T) {
+	var targetNodeType = "alarm"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &AlarmNode{}, types.Configuration{
+			"originator": "${deviceId}",
+			"alarmType":  "General Alarm",
+			"severity":   "MAJOR",
+			"scriptType": types.Expr,
+			"condition":  "msg.temperature > 50",
+			"scope":      types.StateScopeChain,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"alarmType":      "HighTemperature",
+			"severity":       "${level}",
+			"scriptType":     types.Js,
+			"condition":      "return msg.temperature > 50;",
+			"clearCondition": "return msg.temperature < 40;",
+		}, types.Configuration{
+			"originator":     "${deviceId}",
+			"alarmType":      "HighTemperature",
+			"severity":       "${level}",
+			"scriptType":     types.Js,
+			"condition":      "return msg.temperature > 50;",
+			"clearCondition": "return msg.temperature < 40;",
+			"scope":          types.StateScopeChain,
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"alarmType": ""}, Registry)
+		assert.Equal(t, "alarmType is empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"condition": " "}, Registry)
+		assert.Equal(t, "condition is empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"scriptType": "Lua"}, Registry)
+		assert.Equal(t, "unsupported script type: Lua", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"scope": "unknown"}, Registry)
+		assert.Equal(t, "unsupported state scope: unknown", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"condition": "msg.temperature >"}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	var testLifecycle = func(t *testing.T, configuration types.Configuration) {
+		node, err := test.CreateAndInitNode(targetNodeType, configuration, Registry)
+		assert.Nil(t, err)
+		config := types.NewConfig()
+		var relations []string
+		var alarms []Alarm
+		ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string, err error) {
+			relations = append(relations, relationType)
+			var alarm Alarm
+			if relationType != types.Success && relationType != types.Failure {
+				_ = json.Unmarshal([]byte(msg.Data), &alarm)
+			}
+			alarms = append(alarms, alarm)
+		})
+		metadata := types.NewMetadata()
+		metadata.PutValue("deviceId", "aa")
+		metadata.PutValue("level", "MINOR")
+		for _, data := range []string{
+			"{\"temperature\":45}",
+			"{\"temperature\":51}",
+			"{\"temperature\":55}",
+			"{\"temperature\":45}",
+			"{\"temperature\":35}",
+			"{\"temperature\":35}",
+		} {
+			node.OnMsg(ctx, ctx.NewMsg("TELEMETRY", metadata, data))
+		}
+		assert.Equal(t, []string{types.Success, AlarmCreated, AlarmUpdated, types.Success, AlarmCleared, types.Success}, relations)
+		created := alarms[1]
+		assert.Equal(t, "aa", created.Originator)
+		assert.Equal(t, "HighTemperature", created.Type)
+		assert.Equal(t, "MINOR", created.Severity)
+		assert.Equal(t, AlarmStatusActive, created.Status)
+		assert.Equal(t, int64(1), created.Count)
+		assert.True(t, created.StartTs > 0)
+		assert.Equal(t, int64(2), alarms[2].Count)
+		assert.Equal(t, created.StartTs, alarms[2].StartTs)
+		cleared := alarms[4]
+		assert.Equal(t, AlarmStatusCleared, cleared.Status)
+		assert.Equal(t, int64(2), cleared.Count)
+		assert.True(t, cleared.EndTs >= cleared.StartTs)
+	}
+
+	t.Run("ExprLifecycle", func(t *testing.T) {
+		testLifecycle(t, types.Configuration{
+			"alarmType":      "HighTemperature",
+			"severity":       "${level}",
+			"condition":      "msg.temperature > 50",
+			"clearCondition": "msg.temperature < 40",
+		})
+	})
+
+	t.Run("JsLifecycle", func(t *testing.T) {
+		testLifecycle(t, types.Configuration{
+			"alarmType":      "HighTemperature",
+			"severity":       "${level}",
+			"scriptType":     types.Js,
+			"condition":      "return msg.temperature > 50;",
+			"clearCondition": "return msg.temperature < 40;",
+		})
+	})
+
+	t.Run("ClearWhenConditionNotHold", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"condition": "msg.temperature > 50",
+		}, Registry)
+		assert.Nil(t, err)
+		var relations []string
+		metadata := types.NewMetadata()
+		metadata.PutValue("deviceId", "aa")
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: metadata, Data: "{\"temperature\":51}"},
+			{MetaData: metadata, Data: "{\"temperature\":50}"},
+			//脚本执行失败
+			{MetaData: metadata, Data: "{\"temperature\":\"aa\"}"},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			relations = append(relations, relationType)
+		})
+		assert.Equal(t, []string{AlarmCreated, AlarmCleared, types.Failure}, relations)
+	})
+
+	t.Run("Restart", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "alarm.json")
+		configuration := types.Configuration{
+			"alarmType": "HighTemperature",
+			"condition": "msg.temperature > 50",
+		}
+		var relations []string
+		callback := func(msg types.RuleMsg, relationType string, err error) {
+			relations = append(relations, relationType)
+		}
+		metadata := types.NewMetadata()
+		metadata.PutValue("deviceId", "aa")
+
+		store, err := state.NewFileStore(path)
+		assert.Nil(t, err)
+		node, err := test.CreateAndInitNode(targetNodeType, configuration, Registry)
+		assert.Nil(t, err)
+		ctx := test.NewRuleContext(types.NewConfig(types.WithStateStore(store)), callback)
+		node.OnMsg(ctx, ctx.NewMsg("TELEMETRY", metadata, "{\"temperature\":51}"))
+		node.Destroy()
+
+		//重启后继续更新原告警
+		store, err = state.NewFileStore(path)
+		assert.Nil(t, err)
+		node, err = test.CreateAndInitNode(targetNodeType, configuration, Registry)
+		assert.Nil(t, err)
+		var alarm Alarm
+		ctx = test.NewRuleContext(types.NewConfig(types.WithStateStore(store)), func(msg types.RuleMsg, relationType string, err error) {
+			callback(msg, relationType, err)
+			_ = json.Unmarshal([]byte(msg.Data), &alarm)
+		})
+		node.OnMsg(ctx, ctx.NewMsg("TELEMETRY", metadata, "{\"temperature\":52}"))
+		assert.Equal(t, []string{AlarmCreated, AlarmUpdated}, relations)
+		assert.Equal(t, int64(2), alarm.Count)
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"condition": "msg.temperature > 50",
+		}, Registry)
+		assert.Nil(t, err)
+		var mu sync.Mutex
+		var created = make(map[string]int)
+		var maxCount = make(map[string]int64)
+		ctx := test.NewRuleContext(types.NewConfig(), func(msg types.RuleMsg, relationType string, err error) {
+			var alarm Alarm
+			_ = json.Unmarshal([]byte(msg.Data), &alarm)
+			mu.Lock()
+			defer mu.Unlock()
+			if relationType == AlarmCreated {
+				created[alarm.Originator]++
+			}
+			if alarm.Count > maxCount[alarm.Originator] {
+				maxCount[alarm.Originator] = alarm.Count
+			}
+		})
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				metadata := types.NewMetadata()
+				metadata.PutValue("deviceId", "device"+strconv.Itoa(i%4))
+				node.OnMsg(ctx, ctx.NewMsg("TELEMETRY", metadata, "{\"temperature\":51}"))
+			}(i)
+		}
+		wg.Wait()
+		//同一个告警只创建一次，计数不丢失
+		assert.Equal(t, 4, len(created))
+		for i := 0; i < 4; i++ {
+			originator := "device" + strconv.Itoa(i)
+			assert.Equal(t, 1, created[originator])
+			assert.Equal(t, int64(25), maxCount[originator])
+		}
+	})
+}
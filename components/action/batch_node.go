@@ -0,0 +1,214 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "batch",
+//        "name": "批量",
+//        "debugMode": false,
+//        "configuration": {
+//          "batchSize": 100,
+//          "maxBytes": 1048576,
+//          "maxWait": 1000,
+//          "metadataMode": "first"
+//        }
+//  }
+import (
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"sync"
+	"time"
+)
+
+// 批量消息元数据合并方式
+const (
+	//BatchMetadataFirst 使用第一条消息的元数据
+	BatchMetadataFirst = "first"
+	//BatchMetadataMerged 合并所有消息的元数据，相同的key后面的消息覆盖前面的消息
+	BatchMetadataMerged = "merged"
+)
+
+// 注册节点
+func init() {
+	Registry.Add(&BatchNode{})
+}
+
+// BatchNodeConfiguration 节点配置
+type BatchNodeConfiguration struct {
+	//BatchSize 每批最大消息数量
+	BatchSize int
+	//MaxBytes 每批消息体最大字节数，0代表不限制
+	MaxBytes int
+	//MaxWait 第一条消息进入后最长等待时间，单位毫秒，0代表不限制
+	MaxWait int64
+	//MetadataMode 元数据合并方式：first(使用第一条消息的元数据)、merged(合并所有消息的元数据)
+	MetadataMode string
+}
+
+// BatchNode 把多条消息合并成一条JSON数组消息，通过`Success`链发送到下一个节点
+// 满足以下任一条件输出一批：消息数量达到BatchSize、消息体字节数达到MaxBytes、等待时间达到MaxWait
+// JSON类型的消息体以对象的方式放入数组，其他类型以字符串方式放入数组，输出消息使用第一条消息的消息类型
+// 节点销毁时(例如规则链重新加载)，剩余的消息会立即输出
+type BatchNode struct {
+	//节点配置
+	Config BatchNodeConfiguration
+	//当前批次的消息
+	msgs []types.RuleMsg
+	//当前批次消息体字节数
+	bytes int
+	//当前批次最后一条消息的上下文，用于输出
+	ctx types.RuleContext
+	//当前批次序号，用于判断定时器是否过期
+	seq   int64
+	timer *time.Timer
+	mu    sync.Mutex
+}
+
+// Type 组件类型
+func (x *BatchNode) Type() string {
+	return "batch"
+}
+
+func (x *BatchNode) New() types.Node {
+	return &BatchNode{Config: BatchNodeConfiguration{
+		BatchSize:    100,
+		MaxWait:      1000,
+		MetadataMode: BatchMetadataFirst,
+	}}
+}
+
+// Init 初始化
+func (x *BatchNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	if x.Config.BatchSize <= 0 {
+		x.Config.BatchSize = 100
+	}
+	switch x.Config.MetadataMode {
+	case "":
+		x.Config.MetadataMode = BatchMetadataFirst
+	case BatchMetadataFirst, BatchMetadataMerged:
+	default:
+		return fmt.Errorf("unsupported metadata mode:%s", x.Config.MetadataMode)
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *BatchNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var batches [][]types.RuleMsg
+	var ctxList []types.RuleContext
+	x.mu.Lock()
+	//加入该消息会超过字节数限制，先输出当前批次
+	if x.Config.MaxBytes > 0 && len(x.msgs) > 0 && x.bytes+len(msg.Data) > x.Config.MaxBytes {
+		batchCtx, batch := x.take()
+		batches = append(batches, batch)
+		ctxList = append(ctxList, batchCtx)
+	}
+	x.msgs = append(x.msgs, msg)
+	x.bytes += len(msg.Data)
+	x.ctx = ctx
+	if len(x.msgs) >= x.Config.BatchSize || (x.Config.MaxBytes > 0 && x.bytes >= x.Config.MaxBytes) {
+		batchCtx, batch := x.take()
+		batches = append(batches, batch)
+		ctxList = append(ctxList, batchCtx)
+	} else if len(x.msgs) == 1 && x.Config.MaxWait > 0 {
+		seq := x.seq
+		x.timer = time.AfterFunc(time.Duration(x.Config.MaxWait)*time.Millisecond, func() {
+			x.flush(seq)
+		})
+	}
+	x.mu.Unlock()
+
+	for i, batch := range batches {
+		x.emit(ctxList[i], batch)
+	}
+}
+
+// Destroy 销毁，输出剩余的消息
+func (x *BatchNode) Destroy() {
+	x.mu.Lock()
+	ctx, batch := x.take()
+	x.mu.Unlock()
+	if len(batch) > 0 && ctx != nil {
+		x.emit(ctx, batch)
+	}
+}
+
+// flush 等待超时，输出指定批次
+func (x *BatchNode) flush(seq int64) {
+	x.mu.Lock()
+	if seq != x.seq || len(x.msgs) == 0 {
+		x.mu.Unlock()
+		return
+	}
+	ctx, batch := x.take()
+	x.mu.Unlock()
+	x.emit(ctx, batch)
+}
+
+// take 取出当前批次，并开始新的批次，调用方需要加锁
+func (x *BatchNode) take() (types.RuleContext, []types.RuleMsg) {
+	ctx, batch := x.ctx, x.msgs
+	if x.timer != nil {
+		x.timer.Stop()
+		x.timer = nil
+	}
+	x.msgs = nil
+	x.bytes = 0
+	x.ctx = nil
+	x.seq++
+	return ctx, batch
+}
+
+// emit 输出批量消息
+func (x *BatchNode) emit(ctx types.RuleContext, batch []types.RuleMsg) {
+	var list = make([]interface{}, 0, len(batch))
+	for _, item := range batch {
+		var data interface{} = item.Data
+		if item.DataType == types.JSON {
+			var dataMap interface{}
+			if err := json.Unmarshal([]byte(item.Data), &dataMap); err == nil {
+				data = dataMap
+			}
+		}
+		list = append(list, data)
+	}
+	var metadata types.Metadata
+	if x.Config.MetadataMode == BatchMetadataMerged {
+		metadata = types.NewMetadata()
+		for _, item := range batch {
+			for k, v := range item.Metadata.Values() {
+				metadata.PutValue(k, v)
+			}
+		}
+	} else {
+		metadata = batch[0].Metadata.Copy()
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		ctx.TellFailure(batch[len(batch)-1], err)
+		return
+	}
+	ctx.TellSuccess(ctx.NewMsg(batch[0].Type, metadata, string(data)))
+}
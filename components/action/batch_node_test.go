@@ -0,0 +1,141 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatchNode(t *testing.T) {
+	var targetNodeType = "batch"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &BatchNode{}, types.Configuration{
+			"batchSize":    100,
+			"maxWait":      int64(1000),
+			"metadataMode": BatchMetadataFirst,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"batchSize":    10,
+			"maxBytes":     1024,
+			"metadataMode": BatchMetadataMerged,
+		}, types.Configuration{
+			"batchSize":    10,
+			"maxBytes":     1024,
+			"metadataMode": BatchMetadataMerged,
+		}, Registry)
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"metadataMode": "aa",
+		}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("DefaultConfig", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"batchSize":    0,
+			"metadataMode": "",
+		}, types.Configuration{
+			"batchSize":    100,
+			"metadataMode": BatchMetadataFirst,
+		}, Registry)
+	})
+
+	var newCollector = func() (*[]types.RuleMsg, func(msg types.RuleMsg, relationType string, err error)) {
+		var mu sync.Mutex
+		var result []types.RuleMsg
+		return &result, func(msg types.RuleMsg, relationType string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if relationType == types.Success {
+				result = append(result, msg)
+			}
+		}
+	}
+
+	t.Run("BatchSize", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"batchSize": 2,
+			"maxWait":   0,
+		}, Registry)
+		assert.Nil(t, err)
+		var msgList []test.Msg
+		for _, data := range []string{`{"a":1}`, `{"a":2}`, `{"a":3}`, `{"a":4}`, `{"a":5}`} {
+			msgList = append(msgList, test.Msg{MetaData: types.NewMetadata(), MsgType: "TELEMETRY", Data: data})
+		}
+		result, callback := newCollector()
+		//销毁时输出剩余的消息
+		test.NodeOnMsg(t, node, msgList, callback)
+		assert.Equal(t, 3, len(*result))
+		assert.Equal(t, `[{"a":1},{"a":2}]`, (*result)[0].Data)
+		assert.Equal(t, `[{"a":3},{"a":4}]`, (*result)[1].Data)
+		assert.Equal(t, `[{"a":5}]`, (*result)[2].Data)
+		assert.Equal(t, "TELEMETRY", (*result)[0].Type)
+		assert.Equal(t, types.JSON, (*result)[0].DataType)
+	})
+
+	t.Run("MaxBytes", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"maxBytes": 10,
+		}, Registry)
+		assert.Nil(t, err)
+		msgList := []test.Msg{
+			{MetaData: types.NewMetadata(), DataType: types.TEXT, Data: "aaaa"},
+			{MetaData: types.NewMetadata(), DataType: types.TEXT, Data: "bbbb"},
+			{MetaData: types.NewMetadata(), DataType: types.TEXT, Data: "cccc"},
+			{MetaData: types.NewMetadata(), DataType: types.TEXT, Data: "dddddddddddd"},
+		}
+		result, callback := newCollector()
+		test.NodeOnMsg(t, node, msgList, callback)
+		assert.Equal(t, 3, len(*result))
+		assert.Equal(t, `["aaaa","bbbb"]`, (*result)[0].Data)
+		assert.Equal(t, `["cccc"]`, (*result)[1].Data)
+		assert.Equal(t, `["dddddddddddd"]`, (*result)[2].Data)
+	})
+
+	t.Run("MaxWait", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"maxWait":      100,
+			"metadataMode": BatchMetadataMerged,
+		}, Registry)
+		assert.Nil(t, err)
+		metaData1 := types.NewMetadata()
+		metaData1.PutValue("a", "1")
+		metaData1.PutValue("b", "1")
+		metaData2 := types.NewMetadata()
+		metaData2.PutValue("b", "2")
+		msgList := []test.Msg{
+			{MetaData: metaData1, Data: `{"a":1}`},
+			{MetaData: metaData2, Data: `{"a":2}`, AfterSleep: time.Millisecond * 300},
+			{MetaData: metaData2, Data: `{"a":3}`, AfterSleep: time.Millisecond * 300},
+		}
+		result, callback := newCollector()
+		test.NodeOnMsg(t, node, msgList, callback)
+		assert.Equal(t, 2, len(*result))
+		assert.Equal(t, `[{"a":1},{"a":2}]`, (*result)[0].Data)
+		assert.Equal(t, "1", (*result)[0].Metadata.GetValue("a"))
+		assert.Equal(t, "2", (*result)[0].Metadata.GetValue("b"))
+		assert.Equal(t, `[{"a":3}]`, (*result)[1].Data)
+	})
+}
@@ -0,0 +1,413 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：A事件之后5分钟内出现B事件，并且中间没有出现C事件
+//{
+//        "id": "s1",
+//        "type": "cep",
+//        "name": "复杂事件检测",
+//        "debugMode": false,
+//        "configuration": {
+//          "key": "${deviceId}",
+//          "steps": [
+//            {"name": "A", "expr": "msg.event == 'A'"},
+//            {"name": "C", "expr": "msg.event == 'C'", "not": true},
+//            {"name": "B", "expr": "msg.event == 'B'", "within": 300000}
+//          ]
+//        }
+//  }
+import (
+	"fmt"
+	"github.com/expr-lang/expr/vm"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/expr"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CEP节点关系类型
+const (
+	//CepMatched 模式匹配成功
+	CepMatched = "Matched"
+	//CepTimeout 模式匹配超时
+	CepTimeout = "Timeout"
+)
+
+// 注册节点
+func init() {
+	Registry.Add(&CepNode{})
+}
+
+// CepStep 模式步骤
+type CepStep struct {
+	//Name 步骤名称，为空则使用step+序号
+	Name string
+	//Expr 步骤条件，expr-lang表达式，可以使用msg、metadata、msgType、dataType变量
+	Expr string
+	//Times 需要匹配的次数，默认1，not步骤无效
+	Times int
+	//Not 否定步骤，上一个步骤和下一个步骤之间如果出现该事件，则丢弃当前部分匹配
+	//如果是最后的步骤，则在within时间内没有出现该事件才算匹配成功
+	Not bool
+	//Within 距离上一个步骤匹配的最长时间，单位毫秒，<=0不限制
+	Within int64
+}
+
+// CepNodeConfiguration 节点配置
+type CepNodeConfiguration struct {
+	//Key 分区键，每个分区独立匹配，可以使用${metadataKey}方式从metadata获取，为空则所有消息在同一个分区
+	Key string
+	//Steps 模式步骤，按顺序匹配
+	Steps []CepStep
+	//Within 整个模式从第一个事件开始的最长时间，单位毫秒，<=0不限制
+	Within int64
+	//MaxKeys 最大分区数量，超过后新分区的消息发送到`Failure`链
+	MaxKeys int
+}
+
+// CepNode 复杂事件检测节点，按分区检测消息序列是否满足配置的模式
+// 匹配成功则把匹配到的事件列表通过`Matched`链发送到下一个节点，超时则把已匹配的事件列表通过`Timeout`链发送到下一个节点
+// 结果消息格式：{"key":"分区键","startTime":第一个事件时间,"endTime":最后事件时间,"events":[{"step":"步骤名称","ts":事件时间,"msgType":"消息类型","msg":消息体}]}
+// 输入消息被节点消费，不会发送到下一个节点；表达式执行失败则把输入消息发送到`Failure`链
+type CepNode struct {
+	//节点配置
+	Config   CepNodeConfiguration
+	udf      map[string]interface{}
+	programs []*vm.Program
+	//部分匹配，key:分区键
+	partials map[string]*cepPartial
+	mu       sync.Mutex
+}
+
+// cepEvent 已匹配的事件
+type cepEvent struct {
+	step string
+	ts   int64
+	msg  types.RuleMsg
+}
+
+// cepPartial 分区的部分匹配
+type cepPartial struct {
+	ctx    types.RuleContext
+	events []cepEvent
+	//pos 当前需要匹配的步骤，等于步骤数量表示只剩最后的否定步骤
+	pos int
+	//negStart 当前生效的否定步骤开始位置，[negStart,pos)都是否定步骤
+	negStart int
+	//count 当前步骤已经匹配的次数
+	count     int
+	startTime int64
+	lastTime  int64
+	//gen 定时器版本，每次重新计时增加，用于忽略过时的定时器
+	gen   int
+	timer *time.Timer
+}
+
+// cepResult 等待发送的结果
+type cepResult struct {
+	ctx          types.RuleContext
+	msg          types.RuleMsg
+	relationType string
+	err          error
+}
+
+func (r cepResult) tell() {
+	if r.err != nil {
+		r.ctx.TellFailure(r.msg, r.err)
+	} else {
+		r.ctx.TellNext(r.msg, r.relationType)
+	}
+}
+
+// Type 组件类型
+func (x *CepNode) Type() string {
+	return "cep"
+}
+
+func (x *CepNode) New() types.Node {
+	return &CepNode{Config: CepNodeConfiguration{
+		MaxKeys: 10000,
+	}}
+}
+
+// Init 初始化
+func (x *CepNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.Config); err != nil {
+		return err
+	}
+	if x.Config.MaxKeys <= 0 {
+		x.Config.MaxKeys = 10000
+	}
+	steps := x.Config.Steps
+	if len(steps) == 0 {
+		return fmt.Errorf("steps is empty")
+	}
+	if steps[0].Not {
+		return fmt.Errorf("the first step can not be a not step")
+	}
+	x.programs = make([]*vm.Program, len(steps))
+	for i := range steps {
+		if strings.TrimSpace(steps[i].Expr) == "" {
+			return fmt.Errorf("steps[%d] expr is empty", i)
+		}
+		if steps[i].Name == "" {
+			steps[i].Name = fmt.Sprintf("step%d", i+1)
+		}
+		if steps[i].Times <= 0 {
+			steps[i].Times = 1
+		}
+		program, err := expr.CompileAsBool(ruleConfig, steps[i].Expr)
+		if err != nil {
+			return fmt.Errorf("steps[%d] %w", i, err)
+		}
+		x.programs[i] = program
+	}
+	//最后的否定步骤需要时间限制，否则永远无法判断是否匹配
+	if last := x.trailingNotStart(); last < len(steps) && steps[last].Within <= 0 && x.Config.Within <= 0 {
+		return fmt.Errorf("the trailing not step requires within")
+	}
+	x.udf = expr.UdfEnv(ruleConfig)
+	x.partials = make(map[string]*cepPartial)
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *CepNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var data interface{} = msg.Data
+	if msg.DataType == types.JSON {
+		var dataMap interface{}
+		if err := json.Unmarshal([]byte(msg.Data), &dataMap); err == nil {
+			data = dataMap
+		}
+	}
+	env := expr.NewEnvWithContext(ctx, x.udf, msg, data)
+	key := str.SprintfDict(x.Config.Key, msg.Metadata.Values())
+	now := time.Now().UnixMilli()
+
+	x.mu.Lock()
+	results := x.process(ctx, msg, env, key, now)
+	x.mu.Unlock()
+
+	for _, r := range results {
+		r.tell()
+	}
+}
+
+// Destroy 销毁，丢弃部分匹配
+func (x *CepNode) Destroy() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, p := range x.partials {
+		if p.timer != nil {
+			p.timer.Stop()
+		}
+	}
+	x.partials = make(map[string]*cepPartial)
+}
+
+// process 使用消息推进分区的匹配状态，返回需要发送的结果，调用方需持有锁
+func (x *CepNode) process(ctx types.RuleContext, msg types.RuleMsg, env map[string]interface{}, key string, now int64) []cepResult {
+	var results []cepResult
+	p := x.partials[key]
+	//已经超时，先结束旧的部分匹配
+	if p != nil && x.deadline(p) > 0 && now >= x.deadline(p) {
+		results = append(results, x.expire(key, p))
+		p = nil
+	}
+	if p != nil {
+		for i := p.negStart; i < p.pos; i++ {
+			matched, err := x.match(i, env)
+			if err != nil {
+				return append(results, cepResult{ctx: ctx, msg: msg, err: err})
+			}
+			if matched {
+				x.discard(key, p)
+				p = nil
+				break
+			}
+		}
+	}
+	if p != nil {
+		if p.pos < len(x.Config.Steps) {
+			matched, err := x.match(p.pos, env)
+			if err != nil {
+				return append(results, cepResult{ctx: ctx, msg: msg, err: err})
+			}
+			if matched {
+				p.ctx = ctx
+				if r, ok := x.advance(key, p, msg, now); ok {
+					results = append(results, r)
+				}
+			}
+		}
+		return results
+	}
+	matched, err := x.match(0, env)
+	if err != nil {
+		return append(results, cepResult{ctx: ctx, msg: msg, err: err})
+	}
+	if !matched {
+		return results
+	}
+	if len(x.partials) >= x.Config.MaxKeys {
+		return append(results, cepResult{ctx: ctx, msg: msg, err: fmt.Errorf("max limit of cep keys")})
+	}
+	p = &cepPartial{ctx: ctx, startTime: now}
+	x.partials[key] = p
+	if r, ok := x.advance(key, p, msg, now); ok {
+		results = append(results, r)
+	}
+	return results
+}
+
+// match 判断消息是否满足步骤条件
+func (x *CepNode) match(step int, env map[string]interface{}) (bool, error) {
+	out, err := expr.Run(x.programs[step], env)
+	if err != nil {
+		return false, err
+	}
+	matched, _ := out.(bool)
+	return matched, nil
+}
+
+// advance 记录当前步骤匹配的事件，步骤完成则推进到下一个步骤，模式完成则返回匹配结果
+func (x *CepNode) advance(key string, p *cepPartial, msg types.RuleMsg, now int64) (cepResult, bool) {
+	steps := x.Config.Steps
+	p.events = append(p.events, cepEvent{step: steps[p.pos].Name, ts: now, msg: msg})
+	p.lastTime = now
+	p.count++
+	if p.count >= steps[p.pos].Times {
+		next := p.pos + 1
+		p.negStart = next
+		for next < len(steps) && steps[next].Not {
+			next++
+		}
+		p.pos = next
+		p.count = 0
+	}
+	if p.pos == len(steps) && p.negStart == len(steps) {
+		x.discard(key, p)
+		return x.result(key, p, CepMatched), true
+	}
+	x.schedule(key, p)
+	return cepResult{}, false
+}
+
+// deadline 部分匹配的截止时间，0表示不限制
+func (x *CepNode) deadline(p *cepPartial) int64 {
+	var deadline int64
+	if x.Config.Within > 0 {
+		deadline = p.startTime + x.Config.Within
+	}
+	var within int64
+	if p.pos < len(x.Config.Steps) {
+		within = x.Config.Steps[p.pos].Within
+	} else if p.negStart < len(x.Config.Steps) {
+		within = x.Config.Steps[p.negStart].Within
+	}
+	if within > 0 && (deadline == 0 || p.lastTime+within < deadline) {
+		deadline = p.lastTime + within
+	}
+	return deadline
+}
+
+// schedule 按截止时间重新计时
+func (x *CepNode) schedule(key string, p *cepPartial) {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.gen++
+	deadline := x.deadline(p)
+	if deadline == 0 {
+		return
+	}
+	gen := p.gen
+	p.timer = time.AfterFunc(time.Duration(deadline-time.Now().UnixMilli())*time.Millisecond, func() {
+		x.mu.Lock()
+		if x.partials[key] != p || p.gen != gen {
+			x.mu.Unlock()
+			return
+		}
+		r := x.expire(key, p)
+		x.mu.Unlock()
+		r.tell()
+	})
+}
+
+// expire 部分匹配到达截止时间，如果只剩最后的否定步骤则匹配成功，否则超时
+func (x *CepNode) expire(key string, p *cepPartial) cepResult {
+	x.discard(key, p)
+	if p.pos == len(x.Config.Steps) {
+		return x.result(key, p, CepMatched)
+	}
+	return x.result(key, p, CepTimeout)
+}
+
+// discard 删除部分匹配
+func (x *CepNode) discard(key string, p *cepPartial) {
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.gen++
+	delete(x.partials, key)
+}
+
+// result 把已匹配的事件转换成结果消息
+func (x *CepNode) result(key string, p *cepPartial, relationType string) cepResult {
+	events := make([]map[string]interface{}, 0, len(p.events))
+	for _, e := range p.events {
+		var data interface{} = e.msg.Data
+		if e.msg.DataType == types.JSON {
+			var dataMap interface{}
+			if err := json.Unmarshal([]byte(e.msg.Data), &dataMap); err == nil {
+				data = dataMap
+			}
+		}
+		events = append(events, map[string]interface{}{
+			"step":           e.step,
+			"ts":             e.ts,
+			types.MsgTypeKey: e.msg.Type,
+			types.MsgKey:     data,
+		})
+	}
+	last := p.events[len(p.events)-1].msg
+	data, err := json.Marshal(map[string]interface{}{
+		"key":       key,
+		"startTime": p.startTime,
+		"endTime":   p.lastTime,
+		"events":    events,
+	})
+	if err != nil {
+		return cepResult{ctx: p.ctx, msg: last, err: err}
+	}
+	return cepResult{ctx: p.ctx, msg: p.ctx.NewMsg(last.Type, last.Metadata.Copy(), string(data)), relationType: relationType}
+}
+
+// trailingNotStart 最后连续否定步骤的开始位置，没有则等于步骤数量
+func (x *CepNode) trailingNotStart() int {
+	i := len(x.Config.Steps)
+	for i > 0 && x.Config.Steps[i-1].Not {
+		i--
+	}
+	return i
+}
@@ -0,0 +1,217 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"github.com/rulego/rulego/utils/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cepResults 收集cep节点输出，格式：relationType:key:step1,step2
+type cepResults struct {
+	mu    sync.Mutex
+	items []string
+}
+
+func (r *cepResults) add(msg types.RuleMsg, relationType string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.items = append(r.items, relationType+":"+err.Error())
+		return
+	}
+	var result struct {
+		Key    string
+		Events []struct {
+			Step string
+		}
+	}
+	_ = json.Unmarshal([]byte(msg.Data), &result)
+	var steps []string
+	for _, e := range result.Events {
+		steps = append(steps, e.Step)
+	}
+	r.items = append(r.items, relationType+":"+result.Key+":"+strings.Join(steps, ","))
+}
+
+func (r *cepResults) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.items
+}
+
+func TestCepNode(t *testing.T) {
+	var targetNodeType = "cep"
+
+	var newMetadata = func(deviceId string) types.Metadata {
+		metaData := types.NewMetadata()
+		metaData.PutValue("deviceId", deviceId)
+		return metaData
+	}
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &CepNode{}, types.Configuration{
+			"maxKeys": 10000,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key": "${deviceId}",
+			"steps": []interface{}{
+				map[string]interface{}{"expr": "msg.event == 'A'"},
+				map[string]interface{}{"name": "B", "expr": "msg.event == 'B'", "times": 2, "within": 1000},
+			},
+			"within": 5000,
+		}, Registry)
+		assert.Nil(t, err)
+		cepNode := node.(*CepNode)
+		assert.Equal(t, []CepStep{
+			{Name: "step1", Expr: "msg.event == 'A'", Times: 1},
+			{Name: "B", Expr: "msg.event == 'B'", Times: 2, Within: 1000},
+		}, cepNode.Config.Steps)
+		assert.Equal(t, int64(5000), cepNode.Config.Within)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		var testcases = []struct {
+			steps []interface{}
+			err   string
+		}{
+			{steps: nil, err: "steps is empty"},
+			{steps: []interface{}{map[string]interface{}{"expr": "msg.event == 'A'", "not": true}}, err: "the first step can not be a not step"},
+			{steps: []interface{}{map[string]interface{}{"expr": " "}}, err: "steps[0] expr is empty"},
+			{steps: []interface{}{
+				map[string]interface{}{"expr": "msg.event == 'A'"},
+				map[string]interface{}{"expr": "msg.event == 'B'", "not": true},
+			}, err: "the trailing not step requires within"},
+		}
+		for _, item := range testcases {
+			_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"steps": item.steps}, Registry)
+			assert.NotNil(t, err)
+			assert.Equal(t, item.err, err.Error())
+		}
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"steps": []interface{}{map[string]interface{}{"expr": "msg.event =="}},
+		}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("FollowedByWithoutInBetween", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key": "${deviceId}",
+			"steps": []interface{}{
+				map[string]interface{}{"name": "A", "expr": "msg.event == 'A'"},
+				map[string]interface{}{"name": "C", "expr": "msg.event == 'C'", "not": true},
+				map[string]interface{}{"name": "B", "expr": "msg.event == 'B'", "within": 100},
+			},
+		}, Registry)
+		assert.Nil(t, err)
+		results := &cepResults{}
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: newMetadata("aa"), Data: "{\"event\":\"A\"}"},
+			{MetaData: newMetadata("bb"), Data: "{\"event\":\"A\"}"},
+			//aa中间出现C，丢弃
+			{MetaData: newMetadata("aa"), Data: "{\"event\":\"C\"}"},
+			{MetaData: newMetadata("aa"), Data: "{\"event\":\"B\"}"},
+			{MetaData: newMetadata("bb"), Data: "{\"event\":\"X\"}"},
+			{MetaData: newMetadata("bb"), Data: "{\"event\":\"B\"}"},
+			//超时
+			{MetaData: newMetadata("aa"), Data: "{\"event\":\"A\"}", AfterSleep: time.Millisecond * 200},
+		}, results.add)
+		assert.Equal(t, []string{"Matched:bb:A,B", "Timeout:aa:A"}, results.get())
+	})
+
+	t.Run("RuntimeErr", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"steps": []interface{}{
+				map[string]interface{}{"expr": "int(msg.event) > 1"},
+			},
+		}, Registry)
+		assert.Nil(t, err)
+		results := &cepResults{}
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: newMetadata("aa"), Data: "{\"event\":\"A\"}"},
+		}, results.add)
+		items := results.get()
+		assert.Equal(t, 1, len(items))
+		assert.True(t, strings.HasPrefix(items[0], types.Failure))
+	})
+
+	t.Run("Times", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key": "${deviceId}",
+			"steps": []interface{}{
+				map[string]interface{}{"name": "high", "expr": "msg.temperature > 50", "times": 3},
+			},
+			"within": 1000,
+		}, Registry)
+		assert.Nil(t, err)
+		results := &cepResults{}
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: newMetadata("aa"), Data: "{\"temperature\":51}"},
+			{MetaData: newMetadata("aa"), Data: "{\"temperature\":20}"},
+			{MetaData: newMetadata("aa"), Data: "{\"temperature\":52}"},
+			{MetaData: newMetadata("aa"), Data: "{\"temperature\":53}"},
+			{MetaData: newMetadata("aa"), Data: "{\"temperature\":54}"},
+		}, results.add)
+		assert.Equal(t, []string{"Matched:aa:high,high,high"}, results.get())
+	})
+
+	t.Run("Absence", func(t *testing.T) {
+		//A之后100毫秒内没有出现B
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key": "${deviceId}",
+			"steps": []interface{}{
+				map[string]interface{}{"name": "A", "expr": "msg.event == 'A'"},
+				map[string]interface{}{"name": "B", "expr": "msg.event == 'B'", "not": true, "within": 100},
+			},
+		}, Registry)
+		assert.Nil(t, err)
+		results := &cepResults{}
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: newMetadata("aa"), Data: "{\"event\":\"A\"}"},
+			{MetaData: newMetadata("bb"), Data: "{\"event\":\"A\"}"},
+			{MetaData: newMetadata("bb"), Data: "{\"event\":\"B\"}", AfterSleep: time.Millisecond * 200},
+		}, results.add)
+		assert.Equal(t, []string{"Matched:aa:A"}, results.get())
+	})
+
+	t.Run("MaxKeys", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key": "${deviceId}",
+			"steps": []interface{}{
+				map[string]interface{}{"expr": "msg.event == 'A'"},
+				map[string]interface{}{"expr": "msg.event == 'B'"},
+			},
+			"maxKeys": 1,
+		}, Registry)
+		assert.Nil(t, err)
+		results := &cepResults{}
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: newMetadata("aa"), Data: "{\"event\":\"A\"}"},
+			{MetaData: newMetadata("bb"), Data: "{\"event\":\"A\"}"},
+		}, results.add)
+		assert.Equal(t, []string{"Failure:max limit of cep keys"}, results.get())
+	})
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "debounce",
+//        "name": "防抖",
+//        "debugMode": false,
+//        "configuration": {
+//          "key": "${deviceId}",
+//          "wait": 1000
+//        }
+//  }
+import (
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+	"sync"
+	"time"
+)
+
+// 注册节点
+func init() {
+	Registry.Add(&DebounceNode{})
+}
+
+// DebounceNodeConfiguration 节点配置
+type DebounceNodeConfiguration struct {
+	//Key 分组键，每个分组独立防抖，可以使用${metadataKey}方式从metadata获取，为空则所有消息在同一个分组
+	Key string
+	//Wait 静默时间，单位毫秒，分组在该时间内没有新消息，则输出最后一条消息
+	Wait int64
+	//MaxKeys 最大分组数量，超过后新分组的消息发送到`Failure`链
+	MaxKeys int
+}
+
+// DebounceNode 防抖节点，每个分组收到消息后等待静默时间，如果期间收到新消息则重新计时
+// 静默时间结束后，把分组最后一条消息通过`Success`链发送到下一个节点，被覆盖的消息丢弃
+type DebounceNode struct {
+	//节点配置
+	Config DebounceNodeConfiguration
+	//等待输出的消息，key:分组键
+	pending map[string]*pendingMsg
+	mu      sync.Mutex
+}
+
+// pendingMsg 等待输出的消息
+type pendingMsg struct {
+	ctx   types.RuleContext
+	msg   types.RuleMsg
+	timer *time.Timer
+}
+
+// Type 组件类型
+func (x *DebounceNode) Type() string {
+	return "debounce"
+}
+
+func (x *DebounceNode) New() types.Node {
+	return &DebounceNode{Config: DebounceNodeConfiguration{
+		Wait:    1000,
+		MaxKeys: 10000,
+	}}
+}
+
+// Init 初始化
+func (x *DebounceNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.Config)
+	if x.Config.Wait <= 0 {
+		x.Config.Wait = 1000
+	}
+	if x.Config.MaxKeys <= 0 {
+		x.Config.MaxKeys = 10000
+	}
+	x.pending = make(map[string]*pendingMsg)
+	return err
+}
+
+// OnMsg 处理消息
+func (x *DebounceNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	key := str.SprintfDict(x.Config.Key, msg.Metadata.Values())
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if old, ok := x.pending[key]; ok {
+		old.timer.Stop()
+	} else if len(x.pending) >= x.Config.MaxKeys {
+		ctx.TellFailure(msg, fmt.Errorf("max limit of debounce keys"))
+		return
+	}
+	p := &pendingMsg{ctx: ctx, msg: msg}
+	p.timer = time.AfterFunc(time.Duration(x.Config.Wait)*time.Millisecond, func() {
+		x.mu.Lock()
+		//已经被新消息覆盖
+		if x.pending[key] != p {
+			x.mu.Unlock()
+			return
+		}
+		delete(x.pending, key)
+		x.mu.Unlock()
+		p.ctx.TellSuccess(p.msg)
+	})
+	x.pending[key] = p
+}
+
+// Destroy 销毁，丢弃等待输出的消息
+func (x *DebounceNode) Destroy() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, p := range x.pending {
+		p.timer.Stop()
+	}
+	x.pending = make(map[string]*pendingMsg)
+}
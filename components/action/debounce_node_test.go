@@ -0,0 +1,93 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDebounceNode(t *testing.T) {
+	var targetNodeType = "debounce"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &DebounceNode{}, types.Configuration{
+			"wait":    int64(1000),
+			"maxKeys": 10000,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"key":  "${deviceId}",
+			"wait": 100,
+		}, types.Configuration{
+			"key":  "${deviceId}",
+			"wait": int64(100),
+		}, Registry)
+	})
+
+	t.Run("DefaultConfig", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"wait":    0,
+			"maxKeys": 0,
+		}, types.Configuration{
+			"wait":    int64(1000),
+			"maxKeys": 10000,
+		}, Registry)
+	})
+
+	t.Run("OnMsg", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key":     "${deviceId}",
+			"wait":    100,
+			"maxKeys": 2,
+		}, Registry)
+		assert.Nil(t, err)
+		var newMetadata = func(deviceId string) types.Metadata {
+			metaData := types.NewMetadata()
+			metaData.PutValue("deviceId", deviceId)
+			return metaData
+		}
+		msgList := []test.Msg{
+			{MetaData: newMetadata("aa"), Data: "aa1", AfterSleep: time.Millisecond * 50},
+			{MetaData: newMetadata("aa"), Data: "aa2"},
+			{MetaData: newMetadata("bb"), Data: "bb1"},
+			//超过分组数量限制
+			{MetaData: newMetadata("cc"), Data: "cc1", AfterSleep: time.Millisecond * 300},
+			{MetaData: newMetadata("aa"), Data: "aa3", AfterSleep: time.Millisecond * 300},
+		}
+		var mu sync.Mutex
+		var result []string
+		test.NodeOnMsg(t, node, msgList, func(msg types.RuleMsg, relationType string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			result = append(result, relationType+":"+msg.Data)
+		})
+		mu.Lock()
+		defer mu.Unlock()
+		//第一批输出顺序不确定
+		sort.Strings(result[1:3])
+		assert.Equal(t, "Failure:cc1,Success:aa2,Success:bb1,Success:aa3", strings.Join(result, ","))
+	})
+}
@@ -0,0 +1,272 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "dedup",
+//        "name": "去重",
+//        "debugMode": false,
+//        "configuration": {
+//          "key": "${deviceId}",
+//          "fields": "ts,temperature",
+//          "ttl": 60000,
+//          "routeDuplicate": true
+//        }
+//  }
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DedupDuplicate 重复消息关系
+const DedupDuplicate = "Duplicate"
+
+// DedupStores 去重存储注册器，可以注册持久化存储，多个规则引擎实例之间共享去重状态
+var DedupStores = &DedupStoreRegistry{}
+
+// 注册节点
+func init() {
+	Registry.Add(&DedupNode{})
+}
+
+// DedupStore 去重存储
+type DedupStore interface {
+	//SetIfAbsent 如果key不存在或者已经过期，则保存key并返回true，否则返回false
+	SetIfAbsent(key string, ttl time.Duration) (bool, error)
+}
+
+// DedupStoreRegistry 去重存储注册器
+type DedupStoreRegistry struct {
+	stores map[string]DedupStore
+	sync.RWMutex
+}
+
+// Register 注册存储
+func (x *DedupStoreRegistry) Register(name string, store DedupStore) {
+	x.Lock()
+	defer x.Unlock()
+	if x.stores == nil {
+		x.stores = make(map[string]DedupStore)
+	}
+	x.stores[name] = store
+}
+
+// UnRegister 删除存储
+func (x *DedupStoreRegistry) UnRegister(name string) {
+	x.Lock()
+	defer x.Unlock()
+	if x.stores != nil {
+		delete(x.stores, name)
+	}
+}
+
+// Get 获取存储
+func (x *DedupStoreRegistry) Get(name string) (DedupStore, bool) {
+	x.RLock()
+	defer x.RUnlock()
+	if x.stores == nil {
+		return nil, false
+	}
+	store, ok := x.stores[name]
+	return store, ok
+}
+
+// DedupNodeConfiguration 节点配置
+type DedupNodeConfiguration struct {
+	//Key 去重键，可以使用${metadataKey}方式从metadata获取
+	Key string
+	//Fields 参与去重的消息体字段，多个与`,`隔开，支持嵌套字段，例如：ts,data.temperature
+	Fields string
+	//HashPayload 是否使用整个消息体的哈希值参与去重，如果Key和Fields都为空，则默认使用消息体的哈希值
+	HashPayload bool
+	//Ttl 去重时间窗口，单位毫秒，该时间内相同key的消息认为是重复消息
+	Ttl int64
+	//MaxSize 内存存储最多保存的key数量，超过后淘汰最久未使用的key
+	MaxSize int
+	//RouteDuplicate 是否把重复消息发送到`Duplicate`链，false：丢弃重复消息
+	RouteDuplicate bool
+	//Store 使用`DedupStores`注册的存储名称，为空则使用内存存储
+	//保存到存储的去重键会添加`规则链ID:节点ID:`前缀，不同节点之间互不影响
+	Store string
+}
+
+// DedupNode 消息去重节点，TTL时间内相同key的消息只通过`Success`链发送一次
+// 重复消息丢弃，或者RouteDuplicate=true时发送到`Duplicate`链
+// 如果无法生成去重键或者存储出错，则发送到`Failure`链
+type DedupNode struct {
+	//节点配置
+	Config DedupNodeConfiguration
+	fields []string
+	store  DedupStore
+}
+
+// Type 组件类型
+func (x *DedupNode) Type() string {
+	return "dedup"
+}
+
+func (x *DedupNode) New() types.Node {
+	return &DedupNode{Config: DedupNodeConfiguration{
+		Ttl:     60000,
+		MaxSize: 10000,
+	}}
+}
+
+// Init 初始化
+func (x *DedupNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	if x.Config.Ttl <= 0 {
+		x.Config.Ttl = 60000
+	}
+	if x.Config.MaxSize <= 0 {
+		x.Config.MaxSize = 10000
+	}
+	x.fields = nil
+	for _, item := range strings.Split(x.Config.Fields, ",") {
+		if v := strings.TrimSpace(item); v != "" {
+			x.fields = append(x.fields, v)
+		}
+	}
+	if x.Config.Key == "" && len(x.fields) == 0 {
+		x.Config.HashPayload = true
+	}
+	if x.Config.Store != "" {
+		store, ok := DedupStores.Get(x.Config.Store)
+		if !ok {
+			return fmt.Errorf("dedup store=%s not found", x.Config.Store)
+		}
+		x.store = store
+	} else {
+		x.store = newLruStore(x.Config.MaxSize)
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *DedupNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	key, err := x.dedupKey(msg)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	ok, err := x.store.SetIfAbsent(dedupNamespace(ctx)+key, time.Duration(x.Config.Ttl)*time.Millisecond)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else if ok {
+		ctx.TellSuccess(msg)
+	} else if x.Config.RouteDuplicate {
+		ctx.TellNext(msg, DedupDuplicate)
+	}
+}
+
+// Destroy 销毁
+func (x *DedupNode) Destroy() {
+}
+
+// dedupNamespace 去重键前缀：规则链ID:节点ID:，避免共享存储中不同规则链、节点的去重键互相影响
+func dedupNamespace(ctx types.RuleContext) string {
+	var chainId string
+	if chain := ctx.RuleChain(); chain != nil {
+		chainId = chain.GetNodeId().Id
+	}
+	return chainId + ":" + ctx.GetSelfId() + ":"
+}
+
+// dedupKey 生成去重键
+func (x *DedupNode) dedupKey(msg types.RuleMsg) (string, error) {
+	var parts []string
+	if x.Config.Key != "" {
+		parts = append(parts, str.SprintfDict(x.Config.Key, msg.Metadata.Values()))
+	}
+	if len(x.fields) > 0 {
+		var data interface{}
+		if err := json.Unmarshal([]byte(msg.Data), &data); err != nil {
+			return "", fmt.Errorf("msg data is not json:%s", err.Error())
+		}
+		for _, field := range x.fields {
+			v, err := str.ToStringMaybeErr(maps.Get(data, field))
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, v)
+		}
+	}
+	if x.Config.HashPayload {
+		sum := sha256.Sum256([]byte(msg.Data))
+		parts = append(parts, hex.EncodeToString(sum[:]))
+	}
+	return strings.Join(parts, "|"), nil
+}
+
+// lruStore 基于LRU淘汰的内存去重存储
+type lruStore struct {
+	maxSize int
+	items   map[string]*list.Element
+	//最近使用的在队首
+	ll *list.List
+	mu sync.Mutex
+}
+
+type lruEntry struct {
+	key      string
+	expireAt time.Time
+}
+
+func newLruStore(maxSize int) *lruStore {
+	return &lruStore{
+		maxSize: maxSize,
+		items:   make(map[string]*list.Element),
+		ll:      list.New(),
+	}
+}
+
+func (s *lruStore) SetIfAbsent(key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if e, ok := s.items[key]; ok {
+		entry := e.Value.(*lruEntry)
+		if now.Before(entry.expireAt) {
+			s.ll.MoveToFront(e)
+			return false, nil
+		}
+		entry.expireAt = now.Add(ttl)
+		s.ll.MoveToFront(e)
+		return true, nil
+	}
+	s.items[key] = s.ll.PushFront(&lruEntry{key: key, expireAt: now.Add(ttl)})
+	for s.ll.Len() > s.maxSize {
+		oldest := s.ll.Back()
+		s.ll.Remove(oldest)
+		delete(s.items, oldest.Value.(*lruEntry).key)
+	}
+	return true, nil
+}
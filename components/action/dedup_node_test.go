@@ -0,0 +1,178 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// errDedupStore 总是返回错误的存储
+type errDedupStore struct {
+}
+
+func (s *errDedupStore) SetIfAbsent(key string, ttl time.Duration) (bool, error) {
+	return false, errors.New("store error")
+}
+
+// selfIdContext 指定节点ID的上下文
+type selfIdContext struct {
+	types.RuleContext
+	selfId string
+}
+
+func (c *selfIdContext) GetSelfId() string {
+	return c.selfId
+}
+
+func TestDedupNode(t *testing.T) {
+	var targetNodeType = "dedup"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &DedupNode{}, types.Configuration{
+			"ttl":     int64(60000),
+			"maxSize": 10000,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"key":            "${deviceId}",
+			"ttl":            1000,
+			"routeDuplicate": true,
+		}, types.Configuration{
+			"key":            "${deviceId}",
+			"ttl":            int64(1000),
+			"hashPayload":    false,
+			"routeDuplicate": true,
+		}, Registry)
+
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"store": "notFound",
+		}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("DefaultConfig", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"ttl":     0,
+			"maxSize": -1,
+		}, types.Configuration{
+			"ttl":         int64(60000),
+			"maxSize":     10000,
+			"hashPayload": true,
+		}, Registry)
+	})
+
+	var run = func(config types.Configuration, msgList []test.Msg) string {
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		var relationTypes []string
+		test.NodeOnMsg(t, node, msgList, func(msg types.RuleMsg, relationType string, err error) {
+			relationTypes = append(relationTypes, relationType)
+		})
+		return strings.Join(relationTypes, ",")
+	}
+
+	metaData1 := types.NewMetadata()
+	metaData1.PutValue("deviceId", "aa")
+	metaData2 := types.NewMetadata()
+	metaData2.PutValue("deviceId", "bb")
+
+	t.Run("OnMsg", func(t *testing.T) {
+		//使用消息体哈希值去重，丢弃重复消息
+		assert.Equal(t, "Success,Success", run(types.Configuration{}, []test.Msg{
+			{MetaData: metaData1, Data: `{"temperature":20}`},
+			{MetaData: metaData1, Data: `{"temperature":20}`},
+			{MetaData: metaData1, Data: `{"temperature":21}`},
+		}))
+		//使用元数据和字段去重
+		assert.Equal(t, "Success,Duplicate,Success,Success", run(types.Configuration{
+			"key":            "${deviceId}",
+			"fields":         "ts",
+			"routeDuplicate": true,
+		}, []test.Msg{
+			{MetaData: metaData1, Data: `{"ts":1,"temperature":20}`},
+			{MetaData: metaData1, Data: `{"ts":1,"temperature":21}`},
+			{MetaData: metaData2, Data: `{"ts":1,"temperature":20}`},
+			{MetaData: metaData1, Data: `{"ts":2,"temperature":20}`},
+		}))
+		//过期后重新通过
+		assert.Equal(t, "Success,Duplicate,Success", run(types.Configuration{
+			"key":            "${deviceId}",
+			"ttl":            100,
+			"routeDuplicate": true,
+		}, []test.Msg{
+			{MetaData: metaData1, Data: `{}`},
+			{MetaData: metaData1, Data: `{}`, AfterSleep: time.Millisecond * 150},
+			{MetaData: metaData1, Data: `{}`},
+		}))
+		//超过最大数量，淘汰最久未使用的key
+		assert.Equal(t, "Success,Success,Success", run(types.Configuration{
+			"key":            "${deviceId}",
+			"maxSize":        1,
+			"routeDuplicate": true,
+		}, []test.Msg{
+			{MetaData: metaData1, Data: `{}`},
+			{MetaData: metaData2, Data: `{}`},
+			{MetaData: metaData1, Data: `{}`},
+		}))
+		//消息体不是json
+		assert.Equal(t, "Failure", run(types.Configuration{
+			"fields": "ts",
+		}, []test.Msg{
+			{MetaData: metaData1, DataType: types.TEXT, Data: `aa`},
+		}))
+	})
+
+	t.Run("Store", func(t *testing.T) {
+		DedupStores.Register("errStore", &errDedupStore{})
+		defer DedupStores.UnRegister("errStore")
+		assert.Equal(t, "Failure", run(types.Configuration{
+			"store": "errStore",
+		}, []test.Msg{
+			{MetaData: metaData1, Data: `{}`},
+		}))
+	})
+
+	t.Run("SharedStore", func(t *testing.T) {
+		DedupStores.Register("sharedStore", newLruStore(100))
+		defer DedupStores.UnRegister("sharedStore")
+		configuration := types.Configuration{"key": "${deviceId}", "store": "sharedStore"}
+		node1, err := test.CreateAndInitNode(targetNodeType, configuration, Registry)
+		assert.Nil(t, err)
+		node2, err := test.CreateAndInitNode(targetNodeType, configuration, Registry)
+		assert.Nil(t, err)
+
+		var count int
+		ctx := test.NewRuleContext(types.NewConfig(), func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Success, relationType)
+			count++
+		})
+		msg := types.NewMsg(0, "TEST", types.JSON, metaData1, `{}`)
+		//相同节点共享存储中的去重键，不同节点互不影响
+		node1.OnMsg(&selfIdContext{RuleContext: ctx, selfId: "node1"}, msg)
+		node1.OnMsg(&selfIdContext{RuleContext: ctx, selfId: "node1"}, msg)
+		node2.OnMsg(&selfIdContext{RuleContext: ctx, selfId: "node2"}, msg)
+		assert.Equal(t, 2, count)
+	})
+}
@@ -0,0 +1,407 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：按设备和日期归档消息
+//{
+//	"id": "s2",
+//	"type": "file",
+//	"name": "归档",
+//	"debugMode": false,
+//		"configuration": {
+//			"operation": "append",
+//			"baseDir": "./data",
+//			"path": "${deviceId}/${date:yyyy-MM-dd}.log",
+//			"maxSize": 10485760,
+//			"compress": true
+//	}
+//}
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/fs"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+	"github.com/rulego/rulego/utils/times"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 文件操作
+const (
+	//FileWrite 覆盖写入
+	FileWrite = "write"
+	//FileAppend 追加写入一行
+	FileAppend = "append"
+	//FileRead 读取整个文件
+	FileRead = "read"
+	//FileReadLines 按行读取，转换成JSON字符串数组
+	FileReadLines = "readLines"
+)
+
+const (
+	//DefaultFileMaxReadSize 默认读取文件的最大字节数
+	DefaultFileMaxReadSize = 10 * 1024 * 1024
+	//FilePathMetadataKey 处理的文件相对路径保存到元数据的key
+	FilePathMetadataKey = "filePath"
+	//rotateTimeLayout 轮转文件名的时间格式
+	rotateTimeLayout = "yyyyMMddHHmmss"
+	//maxRotateTimes 记录文件首次写入时间的最大数量，超过则清理过期记录
+	maxRotateTimes = 1000
+)
+
+// datePattern 路径中的日期占位符，例如：${date:yyyy-MM-dd}
+var datePattern = regexp.MustCompile(`\$\{date:([^}]+)}`)
+
+func init() {
+	Registry.Add(&FileNode{})
+}
+
+// FileNodeConfiguration 节点配置
+type FileNodeConfiguration struct {
+	//Operation 操作：write(覆盖写入)、append(追加一行)、read(读取整个文件)、readLines(按行读取)
+	Operation string
+	//BaseDir 根目录，所有文件必须在该目录下
+	BaseDir string
+	//Path 相对BaseDir的文件路径，可以使用 ${metadataKey} 替换元数据，${date:yyyy-MM-dd} 替换当前日期
+	Path string
+	//MaxSize 追加写入时文件超过该字节数则轮转，<=0表示不按大小轮转
+	MaxSize int64
+	//RotateInterval 追加写入时文件首次写入超过该秒数则轮转，<=0表示不按时间轮转
+	RotateInterval int64
+	//Compress 是否使用gzip压缩轮转后的文件
+	Compress bool
+	//MaxReadSize 读取文件的最大字节数，默认10M
+	MaxReadSize int64
+}
+
+// FileNode 本地文件读写节点，文件路径限制在BaseDir目录下，文件本身不能是符号链接，目录的符号链接不能指向BaseDir之外
+// 写入时目录不存在会自动创建，追加写入的内容后添加换行符，
+// 轮转后的文件名为：原文件名.yyyyMMddHHmmss，压缩后添加.gz后缀
+// 读取的内容替换消息体，read读取的内容如果是JSON则dataType为JSON，否则为TEXT；readLines的dataType为JSON
+// 处理的文件相对路径保存到元数据filePath
+// 成功通过`Success`链发送到下一个节点，失败则发送到`Failure`链
+type FileNode struct {
+	//节点配置
+	Config     FileNodeConfiguration
+	ruleConfig types.Config
+	//baseDir 根目录的绝对路径
+	baseDir string
+	//locker 保证写入和轮转串行执行
+	locker sync.Mutex
+	//openTimes 文件首次写入时间，用于按时间轮转
+	openTimes map[string]time.Time
+}
+
+// Type 组件类型
+func (x *FileNode) Type() string {
+	return "file"
+}
+
+func (x *FileNode) New() types.Node {
+	return &FileNode{Config: FileNodeConfiguration{
+		Operation:   FileAppend,
+		BaseDir:     "./data",
+		MaxReadSize: DefaultFileMaxReadSize,
+	}}
+}
+
+// Init 初始化
+func (x *FileNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.Config); err != nil {
+		return err
+	}
+	switch x.Config.Operation {
+	case FileWrite, FileAppend, FileRead, FileReadLines:
+	default:
+		return fmt.Errorf("unsupported operation: %s", x.Config.Operation)
+	}
+	if strings.TrimSpace(x.Config.BaseDir) == "" {
+		return errors.New("baseDir is empty")
+	}
+	if strings.TrimSpace(x.Config.Path) == "" {
+		return errors.New("path is empty")
+	}
+	if x.Config.MaxReadSize <= 0 {
+		x.Config.MaxReadSize = DefaultFileMaxReadSize
+	}
+	if err := os.MkdirAll(x.Config.BaseDir, 0755); err != nil {
+		return err
+	}
+	baseDir, err := filepath.Abs(x.Config.BaseDir)
+	if err != nil {
+		return err
+	}
+	if x.baseDir, err = filepath.EvalSymlinks(baseDir); err != nil {
+		return err
+	}
+	x.openTimes = make(map[string]time.Time)
+	x.ruleConfig = ruleConfig
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *FileNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	relPath, fullPath, err := x.resolvePath(msg.Metadata.Values())
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	switch x.Config.Operation {
+	case FileRead, FileReadLines:
+		err = x.read(&msg, fullPath)
+	default:
+		err = x.write(fullPath, msg.Data)
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.Metadata.PutValue(FilePathMetadataKey, relPath)
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *FileNode) Destroy() {
+}
+
+// resolvePath 替换路径变量，返回相对路径和绝对路径，路径不能超出根目录
+func (x *FileNode) resolvePath(metadata map[string]string) (string, string, error) {
+	now := time.Now()
+	path := str.SprintfDict(x.Config.Path, metadata)
+	path = datePattern.ReplaceAllStringFunc(path, func(s string) string {
+		return times.Format(now, datePattern.FindStringSubmatch(s)[1])
+	})
+	if filepath.IsAbs(path) {
+		return "", "", fmt.Errorf("path must be relative: %s", path)
+	}
+	fullPath := filepath.Join(x.baseDir, path)
+	if !x.inBaseDir(fullPath) || fullPath == x.baseDir {
+		return "", "", fmt.Errorf("path is outside of baseDir: %s", path)
+	}
+	//文件本身不能是符号链接，否则读写会跟随链接到根目录外
+	if info, err := os.Lstat(fullPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return "", "", fmt.Errorf("path is a symlink: %s", path)
+	}
+	//目录可能还不存在，检查已存在的最长上级目录解析符号链接后的真实路径，失效的符号链接也视为存在
+	dir := filepath.Dir(fullPath)
+	for dir != x.baseDir {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	if realDir, err := filepath.EvalSymlinks(dir); err != nil || !x.inBaseDir(realDir) {
+		return "", "", fmt.Errorf("path is outside of baseDir: %s", path)
+	}
+	relPath, _ := filepath.Rel(x.baseDir, fullPath)
+	return filepath.ToSlash(relPath), fullPath, nil
+}
+
+// inBaseDir 路径是否在根目录下
+func (x *FileNode) inBaseDir(path string) bool {
+	rel, err := filepath.Rel(x.baseDir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// read 读取文件内容
+func (x *FileNode) read(msg *types.RuleMsg, fullPath string) error {
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", filepath.Base(fullPath))
+	}
+	if info.Size() > x.Config.MaxReadSize {
+		return fmt.Errorf("max limit of read size is %d", x.Config.MaxReadSize)
+	}
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, x.Config.MaxReadSize))
+	if err != nil {
+		return err
+	}
+	if x.Config.Operation == FileReadLines {
+		lines := make([]string, 0)
+		scanner := bufio.NewScanner(strings.NewReader(string(data)))
+		scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+		for scanner.Scan() {
+			lines = append(lines, strings.TrimSuffix(scanner.Text(), "\r"))
+		}
+		if err = scanner.Err(); err != nil {
+			return err
+		}
+		if data, err = json.Marshal(lines); err != nil {
+			return err
+		}
+		msg.Data = string(data)
+		msg.DataType = types.JSON
+		return nil
+	}
+	msg.Data = string(data)
+	var v interface{}
+	if json.Unmarshal(data, &v) == nil {
+		msg.DataType = types.JSON
+	} else {
+		msg.DataType = types.TEXT
+	}
+	return nil
+}
+
+// write 写入文件，轮转后的文件在释放锁后压缩，避免压缩阻塞其他消息写入
+// 压缩失败只记录日志，不影响已经写入的消息，避免重试导致重复写入
+func (x *FileNode) write(fullPath string, data string) error {
+	rotated, err := x.writeFile(fullPath, data)
+	if rotated != "" && x.Config.Compress {
+		if compressErr := gzipFile(rotated); compressErr != nil && x.ruleConfig.Logger != nil {
+			x.ruleConfig.Logger.Printf("file node compress %s error:%s", rotated, compressErr.Error())
+		}
+	}
+	return err
+}
+
+// writeFile 写入文件，追加写入前检查是否需要轮转，返回轮转后的文件路径，没有轮转返回空
+func (x *FileNode) writeFile(fullPath string, data string) (string, error) {
+	x.locker.Lock()
+	defer x.locker.Unlock()
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", err
+	}
+	if x.Config.Operation == FileWrite {
+		return "", os.WriteFile(fullPath, []byte(data), 0644)
+	}
+	line := data + "\n"
+	rotated, err := x.rotateIfNeeded(fullPath, int64(len(line)))
+	if err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(fullPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return rotated, err
+	}
+	_, err = f.WriteString(line)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return rotated, err
+}
+
+// rotateIfNeeded 写入size字节后超过MaxSize或者首次写入时间超过RotateInterval则轮转，返回轮转后的文件路径
+func (x *FileNode) rotateIfNeeded(fullPath string, size int64) (string, error) {
+	now := time.Now()
+	info, err := os.Stat(fullPath)
+	if os.IsNotExist(err) {
+		x.recordOpenTime(fullPath, now)
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	rotate := x.Config.MaxSize > 0 && info.Size() > 0 && info.Size()+size > x.Config.MaxSize
+	if x.Config.RotateInterval > 0 {
+		openTime, ok := x.openTimes[fullPath]
+		if !ok {
+			//重启后以文件修改时间为准
+			openTime = info.ModTime()
+			x.recordOpenTime(fullPath, openTime)
+		}
+		if now.Sub(openTime) >= time.Duration(x.Config.RotateInterval)*time.Second {
+			rotate = true
+		}
+	}
+	if !rotate || info.Size() == 0 {
+		return "", nil
+	}
+	target, err := x.rotate(fullPath, now)
+	if err != nil {
+		return "", err
+	}
+	x.recordOpenTime(fullPath, now)
+	return target, nil
+}
+
+// recordOpenTime 记录文件首次写入时间，记录过多时清理已经过期的记录
+func (x *FileNode) recordOpenTime(fullPath string, t time.Time) {
+	if x.Config.RotateInterval <= 0 {
+		return
+	}
+	if len(x.openTimes) >= maxRotateTimes {
+		interval := time.Duration(x.Config.RotateInterval) * time.Second
+		for k, v := range x.openTimes {
+			if time.Since(v) >= interval {
+				delete(x.openTimes, k)
+			}
+		}
+	}
+	x.openTimes[fullPath] = t
+}
+
+// rotate 重命名当前文件，返回重命名后的文件路径
+func (x *FileNode) rotate(fullPath string, now time.Time) (string, error) {
+	suffix := ""
+	if x.Config.Compress {
+		suffix = ".gz"
+	}
+	base := fullPath + "." + times.Format(now, rotateTimeLayout)
+	target := base
+	for i := 1; fs.IsExist(target) || fs.IsExist(target+suffix); i++ {
+		target = fmt.Sprintf("%s-%d", base, i)
+	}
+	if err := os.Rename(fullPath, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// gzipFile 压缩文件为.gz并删除原文件
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(dst)
+	_, err = io.Copy(writer, src)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path + ".gz")
+		return err
+	}
+	_ = src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,275 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"compress/gzip"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"github.com/rulego/rulego/utils/times"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fileOnMsg 发送消息，返回最后一条消息的处理结果
+func fileOnMsg(t *testing.T, node types.Node, metaData types.Metadata, data ...string) (types.RuleMsg, string, error) {
+	var result types.RuleMsg
+	var relation string
+	var resultErr error
+	var msgList []test.Msg
+	for _, item := range data {
+		msgList = append(msgList, test.Msg{MetaData: metaData.Copy(), Data: item})
+	}
+	test.NodeOnMsg(t, node, msgList, func(msg types.RuleMsg, relationType string, err error) {
+		result, relation, resultErr = msg, relationType, err
+	})
+	return result, relation, resultErr
+}
+
+func TestFileNode(t *testing.T) {
+	var targetNodeType = "file"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &FileNode{}, types.Configuration{
+			"operation":   FileAppend,
+			"baseDir":     "./data",
+			"maxReadSize": int64(DefaultFileMaxReadSize),
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		baseDir := t.TempDir()
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"operation":      FileAppend,
+			"baseDir":        baseDir,
+			"path":           "${deviceId}.log",
+			"maxSize":        1024,
+			"rotateInterval": 60,
+			"compress":       true,
+		}, types.Configuration{
+			"operation":      FileAppend,
+			"baseDir":        baseDir,
+			"path":           "${deviceId}.log",
+			"maxSize":        int64(1024),
+			"rotateInterval": int64(60),
+			"compress":       true,
+		}, Registry)
+
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"operation": "delete", "baseDir": baseDir, "path": "a.log"}, Registry)
+		assert.Equal(t, "unsupported operation: delete", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"baseDir": " ", "path": "a.log"}, Registry)
+		assert.Equal(t, "baseDir is empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"baseDir": baseDir}, Registry)
+		assert.Equal(t, "path is empty", err.Error())
+	})
+
+	t.Run("WriteAndRead", func(t *testing.T) {
+		baseDir := t.TempDir()
+		metaData := types.NewMetadata()
+		metaData.PutValue("deviceId", "aa")
+
+		appendNode, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"baseDir": baseDir,
+			"path":    "${deviceId}/${date:yyyy-MM-dd}.log",
+		}, Registry)
+		assert.Nil(t, err)
+		expectedPath := "aa/" + times.Format(time.Now(), "yyyy-MM-dd") + ".log"
+		msg, relationType, err := fileOnMsg(t, appendNode, metaData, `{"temp":1}`, `{"temp":2}`)
+		assert.Equal(t, types.Success, relationType)
+		assert.Nil(t, err)
+		assert.Equal(t, expectedPath, msg.Metadata.GetValue(FilePathMetadataKey))
+		assert.Equal(t, `{"temp":2}`, msg.Data)
+		content, err := os.ReadFile(filepath.Join(baseDir, expectedPath))
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"temp\":1}\n{\"temp\":2}\n", string(content))
+
+		readLinesNode, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"operation": FileReadLines,
+			"baseDir":   baseDir,
+			"path":      "${deviceId}/${date:yyyy-MM-dd}.log",
+		}, Registry)
+		assert.Nil(t, err)
+		msg, relationType, _ = fileOnMsg(t, readLinesNode, metaData, "")
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, types.JSON, msg.DataType)
+		assert.Equal(t, `["{\"temp\":1}","{\"temp\":2}"]`, msg.Data)
+
+		writeNode, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"operation": FileWrite,
+			"baseDir":   baseDir,
+			"path":      "config/${deviceId}.json",
+		}, Registry)
+		assert.Nil(t, err)
+		_, relationType, _ = fileOnMsg(t, writeNode, metaData, `{"v":1}`, `{"v":2}`)
+		assert.Equal(t, types.Success, relationType)
+
+		readNode, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"operation": FileRead,
+			"baseDir":   baseDir,
+			"path":      "config/${deviceId}.json",
+		}, Registry)
+		assert.Nil(t, err)
+		msg, relationType, _ = fileOnMsg(t, readNode, metaData, "")
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, types.JSON, msg.DataType)
+		assert.Equal(t, `{"v":2}`, msg.Data)
+
+		//文件不存在、超过最大读取字节数
+		msg, relationType, err = fileOnMsg(t, readNode, types.NewMetadata(), "aa")
+		assert.Equal(t, types.Failure, relationType)
+		assert.NotNil(t, err)
+		assert.Equal(t, "aa", msg.Data)
+		limitNode, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"operation":   FileRead,
+			"baseDir":     baseDir,
+			"path":        "config/${deviceId}.json",
+			"maxReadSize": 3,
+		}, Registry)
+		assert.Nil(t, err)
+		_, relationType, err = fileOnMsg(t, limitNode, metaData, "")
+		assert.Equal(t, types.Failure, relationType)
+		assert.Equal(t, "max limit of read size is 3", err.Error())
+	})
+
+	t.Run("BaseDir", func(t *testing.T) {
+		root := t.TempDir()
+		baseDir := filepath.Join(root, "base")
+		outsideDir := filepath.Join(root, "outside")
+		assert.Nil(t, os.MkdirAll(outsideDir, 0755))
+		assert.Nil(t, os.WriteFile(filepath.Join(outsideDir, "secret.txt"), []byte("secret"), 0644))
+
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"operation": FileRead,
+			"baseDir":   baseDir,
+			"path":      "${file}",
+		}, Registry)
+		assert.Nil(t, err)
+		assert.Nil(t, os.Symlink(outsideDir, filepath.Join(baseDir, "link")))
+
+		for _, path := range []string{"../outside/secret.txt", "a/../../outside/secret.txt", outsideDir + "/secret.txt", "link/secret.txt", "."} {
+			metaData := types.NewMetadata()
+			metaData.PutValue("file", path)
+			_, relationType, err := fileOnMsg(t, node, metaData, "")
+			assert.Equal(t, types.Failure, relationType)
+			assert.NotNil(t, err)
+		}
+		metaData := types.NewMetadata()
+		metaData.PutValue("file", "../outside/secret.txt")
+		_, _, err = fileOnMsg(t, node, metaData, "")
+		assert.Equal(t, "path is outside of baseDir: ../outside/secret.txt", err.Error())
+	})
+
+	t.Run("Symlink", func(t *testing.T) {
+		root := t.TempDir()
+		baseDir := filepath.Join(root, "base")
+		outsideDir := filepath.Join(root, "outside")
+		secretFile := filepath.Join(outsideDir, "secret.txt")
+		assert.Nil(t, os.MkdirAll(baseDir, 0755))
+		assert.Nil(t, os.MkdirAll(outsideDir, 0755))
+		assert.Nil(t, os.WriteFile(secretFile, []byte("secret"), 0644))
+		//文件本身是指向根目录外的符号链接
+		assert.Nil(t, os.Symlink(secretFile, filepath.Join(baseDir, "ref.txt")))
+		//目录是指向根目录外的符号链接，下级目录还不存在
+		assert.Nil(t, os.Symlink(outsideDir, filepath.Join(baseDir, "link")))
+		//失效的符号链接
+		assert.Nil(t, os.Symlink(filepath.Join(outsideDir, "notFound"), filepath.Join(baseDir, "dangling")))
+
+		for _, operation := range []string{FileRead, FileWrite, FileAppend} {
+			node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+				"operation": operation,
+				"baseDir":   baseDir,
+				"path":      "${file}",
+			}, Registry)
+			assert.Nil(t, err)
+			for _, path := range []string{"ref.txt", "link/new/x.log", "dangling/x.log"} {
+				metaData := types.NewMetadata()
+				metaData.PutValue("file", path)
+				_, relationType, err := fileOnMsg(t, node, metaData, "hacked")
+				assert.Equal(t, types.Failure, relationType)
+				assert.NotNil(t, err)
+			}
+		}
+		content, err := os.ReadFile(secretFile)
+		assert.Nil(t, err)
+		assert.Equal(t, "secret", string(content))
+		assert.False(t, fileExists(filepath.Join(outsideDir, "new")))
+		assert.False(t, fileExists(filepath.Join(outsideDir, "notFound")))
+	})
+
+	t.Run("RotateBySize", func(t *testing.T) {
+		baseDir := t.TempDir()
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"baseDir":  baseDir,
+			"path":     "app.log",
+			"maxSize":  10,
+			"compress": true,
+		}, Registry)
+		assert.Nil(t, err)
+		_, relationType, _ := fileOnMsg(t, node, types.NewMetadata(), "aaaa", "bbbb", "cccc", "dddd")
+		assert.Equal(t, types.Success, relationType)
+
+		content, err := os.ReadFile(filepath.Join(baseDir, "app.log"))
+		assert.Nil(t, err)
+		assert.Equal(t, "cccc\ndddd\n", string(content))
+		rotated, _ := filepath.Glob(filepath.Join(baseDir, "app.log.*.gz"))
+		assert.Equal(t, 1, len(rotated))
+		f, err := os.Open(rotated[0])
+		assert.Nil(t, err)
+		defer f.Close()
+		reader, err := gzip.NewReader(f)
+		assert.Nil(t, err)
+		content, err = io.ReadAll(reader)
+		assert.Nil(t, err)
+		assert.Equal(t, "aaaa\nbbbb\n", string(content))
+	})
+
+	t.Run("RotateByTime", func(t *testing.T) {
+		baseDir := t.TempDir()
+		logFile := filepath.Join(baseDir, "app.log")
+		assert.Nil(t, os.WriteFile(logFile, []byte("old\n"), 0644))
+		old := time.Now().Add(-time.Hour)
+		assert.Nil(t, os.Chtimes(logFile, old, old))
+
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"baseDir":        baseDir,
+			"path":           "app.log",
+			"rotateInterval": 60,
+		}, Registry)
+		assert.Nil(t, err)
+		_, relationType, _ := fileOnMsg(t, node, types.NewMetadata(), "new1", "new2")
+		assert.Equal(t, types.Success, relationType)
+
+		content, err := os.ReadFile(logFile)
+		assert.Nil(t, err)
+		assert.Equal(t, "new1\nnew2\n", string(content))
+		rotated, _ := filepath.Glob(filepath.Join(baseDir, "app.log.*"))
+		assert.Equal(t, 1, len(rotated))
+		assert.False(t, strings.HasSuffix(rotated[0], ".gz"))
+		content, _ = os.ReadFile(rotated[0])
+		assert.Equal(t, "old\n", string(content))
+	})
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
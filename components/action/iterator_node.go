@@ -39,7 +39,8 @@ type IteratorNodeConfiguration struct {
 	// function ItemFilter(item,index,metadata)
 	// item: 当前遍历的item
 	// index: 如果是数组，则表示当前遍历的index，如果是map，则表示当前遍历的key
-	JsScript string
+	// 可以使用内置函数库，参考：`js.Builtins`
+	JsScript string `desc:"item过滤函数体：function ItemFilter(item, index, metadata) {...}，返回bool。内置函数库：$encoding、$crypto、$uuid、$time"`
 }
 
 // IteratorNode 遍历msg或者msg中指定字段item值到下一个节点，遍历字段值必须是`数组`或者`{key:value}`类型
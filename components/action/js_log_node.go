@@ -51,7 +51,8 @@ type LogNodeConfiguration struct {
 	//完整脚本函数：
 	//"function ToString(msg, metadata, msgType) { ${JsScript} }"
 	//脚本返回值string
-	JsScript string
+	//可以使用内置函数库，参考：`js.Builtins`
+	JsScript string `desc:"日志格式化函数体：function ToString(msg, metadata, msgType) {...}，返回string。内置函数库：$encoding、$crypto、$uuid、$time"`
 }
 
 // LogNode 使用JS脚本将传入消息转换为字符串，并将最终值记录到日志文件中
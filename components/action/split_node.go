@@ -0,0 +1,127 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "split",
+//        "name": "拆分",
+//        "debugMode": false,
+//        "configuration": {
+//          "fieldName": "items"
+//        }
+//  }
+import (
+	"errors"
+	"github.com/gofrs/uuid/v5"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+	"strconv"
+	"strings"
+)
+
+// 拆分消息元数据key，aggregate节点根据这些信息合并消息
+const (
+	//SplitCorrelationIdKey 关联ID，同一条消息拆分出来的消息关联ID相同
+	SplitCorrelationIdKey = "splitCorrelationId"
+	//SplitIndexKey 拆分消息在数组中的下标
+	SplitIndexKey = "splitIndex"
+	//SplitTotalKey 拆分消息总数
+	SplitTotalKey = "splitTotal"
+)
+
+func init() {
+	Registry.Add(&SplitNode{})
+}
+
+// SplitNodeConfiguration 节点配置
+type SplitNodeConfiguration struct {
+	// 拆分字段名称，如果空，拆分整个msg，支持嵌套方式获取msg字段值，例如items.value、items
+	FieldName string
+}
+
+// SplitNode 把msg或者msg中指定字段的数组拆分成多条消息，每个元素通过`Success`链发送到下一个节点
+// 每条消息的元数据增加关联ID(splitCorrelationId)、下标(splitIndex)和总数(splitTotal)，可以使用`aggregate`节点按顺序合并处理结果
+// 如果找不到指定字段、值不是数组或者数组为空，则把原始msg发送到`Failure`链
+type SplitNode struct {
+	//节点配置
+	Config SplitNodeConfiguration
+}
+
+// Type 组件类型
+func (x *SplitNode) Type() string {
+	return "split"
+}
+
+func (x *SplitNode) New() types.Node {
+	return &SplitNode{Config: SplitNodeConfiguration{}}
+}
+
+// Init 初始化
+func (x *SplitNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.Config)
+	x.Config.FieldName = strings.TrimSpace(x.Config.FieldName)
+	return err
+}
+
+// OnMsg 处理消息
+func (x *SplitNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var data interface{} = msg.Data
+	if msg.DataType == types.JSON {
+		var dataMap interface{}
+		if err := json.Unmarshal([]byte(msg.Data), &dataMap); err == nil {
+			data = dataMap
+		}
+	}
+	if x.Config.FieldName != "" {
+		data = maps.Get(data, x.Config.FieldName)
+		if data == nil {
+			ctx.TellFailure(msg, errors.New("field="+x.Config.FieldName+" not found"))
+			return
+		}
+	}
+	arrayValue, ok := data.([]interface{})
+	if !ok {
+		ctx.TellFailure(msg, errors.New("value is not array type"))
+		return
+	}
+	if len(arrayValue) == 0 {
+		ctx.TellFailure(msg, errors.New("array is empty"))
+		return
+	}
+	correlationId, err := uuid.NewV4()
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	total := strconv.Itoa(len(arrayValue))
+	for index, item := range arrayValue {
+		itemMsg := msg.Copy()
+		itemMsg.Data = str.ToString(item)
+		itemMsg.Metadata.PutValue(SplitCorrelationIdKey, correlationId.String())
+		itemMsg.Metadata.PutValue(SplitIndexKey, strconv.Itoa(index))
+		itemMsg.Metadata.PutValue(SplitTotalKey, total)
+		ctx.TellSuccess(itemMsg)
+	}
+}
+
+// Destroy 销毁
+func (x *SplitNode) Destroy() {
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"testing"
+)
+
+func TestSplitNode(t *testing.T) {
+	var targetNodeType = "split"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &SplitNode{}, types.Configuration{
+			"fieldName": "",
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"fieldName": " items ",
+		}, types.Configuration{
+			"fieldName": "items",
+		}, Registry)
+	})
+
+	t.Run("OnMsg", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"fieldName": "items",
+		}, Registry)
+		assert.Nil(t, err)
+		metaData := types.NewMetadata()
+		metaData.PutValue("productType", "test")
+		msgList := []test.Msg{
+			{MetaData: metaData, MsgType: "TEST", Data: `{"items":[{"name":"aa"},"bb",3]}`},
+		}
+		var result []types.RuleMsg
+		test.NodeOnMsg(t, node, msgList, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Success, relationType)
+			result = append(result, msg)
+		})
+		assert.Equal(t, 3, len(result))
+		assert.Equal(t, `{"name":"aa"}`, result[0].Data)
+		assert.Equal(t, "bb", result[1].Data)
+		assert.Equal(t, "3", result[2].Data)
+		for i, item := range result {
+			assert.Equal(t, "test", item.Metadata.GetValue("productType"))
+			assert.Equal(t, result[0].Metadata.GetValue(SplitCorrelationIdKey), item.Metadata.GetValue(SplitCorrelationIdKey))
+			assert.Equal(t, string(rune('0'+i)), item.Metadata.GetValue(SplitIndexKey))
+			assert.Equal(t, "3", item.Metadata.GetValue(SplitTotalKey))
+		}
+		assert.True(t, result[0].Metadata.GetValue(SplitCorrelationIdKey) != "")
+		//不修改原始消息元数据
+		assert.False(t, metaData.Has(SplitIndexKey))
+	})
+
+	t.Run("OnMsgFailure", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"fieldName": "items",
+		}, Registry)
+		assert.Nil(t, err)
+		msgList := []test.Msg{
+			{MetaData: types.NewMetadata(), Data: `{"name":"aa"}`},
+			{MetaData: types.NewMetadata(), Data: `{"items":"aa"}`},
+			{MetaData: types.NewMetadata(), Data: `{"items":[]}`},
+		}
+		var count = 0
+		test.NodeOnMsg(t, node, msgList, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Failure, relationType)
+			count++
+		})
+		assert.Equal(t, 3, count)
+	})
+}
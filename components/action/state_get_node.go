@@ -0,0 +1,130 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "stateGet",
+//        "name": "读取状态",
+//        "debugMode": false,
+//        "configuration": {
+//          "key": "${deviceId}_last",
+//          "scope": "chain",
+//          "metadataKey": "lastValue"
+//        }
+//  }
+import (
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+)
+
+// DefaultStateMetadataKey 状态值保存到metadata的默认key
+const DefaultStateMetadataKey = "stateValue"
+
+// 注册节点
+func init() {
+	Registry.Add(&StateGetNode{})
+}
+
+// StateGetNodeConfiguration 节点配置
+type StateGetNodeConfiguration struct {
+	//Key 状态key，可以使用${metadataKey}方式从metadata获取
+	Key string
+	//Scope 作用域：global(所有规则链共享)、chain(规则链内共享)、node(节点内共享)，默认chain
+	Scope string
+	//NodeId scope为node时读写的节点ID，为空则使用当前节点ID，用于读写同一规则链其他节点的状态
+	NodeId string
+	//MetadataKey 读取的值保存到metadata的key
+	MetadataKey string
+	//DefaultValue 状态不存在时使用的值
+	DefaultValue string
+}
+
+// StateGetNode 从规则引擎状态存储读取状态，结果保存到metadata，然后通过`Success`链发送到下一个节点
+// 状态存储通过`types.Config.StateStore`配置，读取失败则发送到`Failure`链
+type StateGetNode struct {
+	//节点配置
+	Config StateGetNodeConfiguration
+}
+
+// Type 组件类型
+func (x *StateGetNode) Type() string {
+	return "stateGet"
+}
+
+func (x *StateGetNode) New() types.Node {
+	return &StateGetNode{Config: StateGetNodeConfiguration{
+		Scope:       types.StateScopeChain,
+		MetadataKey: DefaultStateMetadataKey,
+	}}
+}
+
+// Init 初始化
+func (x *StateGetNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.Config); err != nil {
+		return err
+	}
+	if x.Config.MetadataKey == "" {
+		x.Config.MetadataKey = DefaultStateMetadataKey
+	}
+	return checkStateConfig(x.Config.Key, x.Config.Scope)
+}
+
+// OnMsg 处理消息
+func (x *StateGetNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	store, err := types.NodeScopedState(ctx, x.Config.Scope, x.Config.NodeId)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	value, ok, err := store.Get(str.SprintfDict(x.Config.Key, msg.Metadata.Values()))
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	if ok {
+		msg.Metadata.PutValue(x.Config.MetadataKey, str.ToString(value))
+	} else {
+		msg.Metadata.PutValue(x.Config.MetadataKey, x.Config.DefaultValue)
+	}
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *StateGetNode) Destroy() {
+}
+
+// checkStateConfig 检查状态节点key和作用域配置
+func checkStateConfig(key, scope string) error {
+	if key == "" {
+		return fmt.Errorf("key is empty")
+	}
+	return checkStateScope(scope)
+}
+
+// checkStateScope 检查状态作用域配置
+func checkStateScope(scope string) error {
+	switch scope {
+	case "", types.StateScopeGlobal, types.StateScopeChain, types.StateScopeNode:
+		return nil
+	default:
+		return fmt.Errorf("unsupported state scope: %s", scope)
+	}
+}
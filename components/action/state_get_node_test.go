@@ -0,0 +1,132 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"testing"
+)
+
+func TestStateGetNode(t *testing.T) {
+	var targetNodeType = "stateGet"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &StateGetNode{}, types.Configuration{
+			"scope":       types.StateScopeChain,
+			"metadataKey": DefaultStateMetadataKey,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"key":          "${deviceId}_last",
+			"scope":        "global",
+			"metadataKey":  "last",
+			"defaultValue": "0",
+		}, types.Configuration{
+			"key":          "${deviceId}_last",
+			"scope":        "global",
+			"metadataKey":  "last",
+			"defaultValue": "0",
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{}, Registry)
+		assert.Equal(t, "key is empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key":   "a",
+			"scope": "unknown",
+		}, Registry)
+		assert.Equal(t, "unsupported state scope: unknown", err.Error())
+	})
+
+	t.Run("OnMsg", func(t *testing.T) {
+		setNode, err := test.CreateAndInitNode("stateSet", types.Configuration{
+			"key": "${deviceId}_last",
+		}, Registry)
+		assert.Nil(t, err)
+		getNode, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key":          "${deviceId}_last",
+			"metadataKey":  "last",
+			"defaultValue": "none",
+		}, Registry)
+		assert.Nil(t, err)
+		globalGetNode, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key":   "${deviceId}_last",
+			"scope": "global",
+		}, Registry)
+		assert.Nil(t, err)
+
+		var results []string
+		config := types.NewConfig()
+		ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string, err error) {
+			results = append(results, relationType+":"+msg.Metadata.GetValue("last")+":"+msg.Metadata.GetValue(DefaultStateMetadataKey))
+		})
+		metadata := types.NewMetadata()
+		metadata.PutValue("deviceId", "aa")
+
+		getNode.OnMsg(ctx, ctx.NewMsg("TEST", metadata.Copy(), "{\"temperature\":41}"))
+		setNode.OnMsg(ctx, ctx.NewMsg("TEST", metadata.Copy(), "{\"temperature\":41}"))
+		getNode.OnMsg(ctx, ctx.NewMsg("TEST", metadata.Copy(), "{\"temperature\":42}"))
+		//不同作用域
+		globalGetNode.OnMsg(ctx, ctx.NewMsg("TEST", metadata.Copy(), "{\"temperature\":42}"))
+		assert.Equal(t, []string{"Success:none:", "Success::", "Success:{\"temperature\":41}:", "Success::"}, results)
+
+		//没有配置状态存储
+		config.StateStore = nil
+		ctx = test.NewRuleContext(config, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Failure, relationType)
+			assert.NotNil(t, err)
+		})
+		getNode.OnMsg(ctx, ctx.NewMsg("TEST", metadata.Copy(), "{}"))
+	})
+
+	t.Run("NodeScope", func(t *testing.T) {
+		setNode, err := test.CreateAndInitNode("stateSet", types.Configuration{
+			"key":   "last",
+			"scope": types.StateScopeNode,
+			"value": "41",
+		}, Registry)
+		assert.Nil(t, err)
+		//读取setNode节点的状态
+		getNode, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key":    "last",
+			"scope":  types.StateScopeNode,
+			"nodeId": "s1",
+		}, Registry)
+		assert.Nil(t, err)
+		//读取当前节点的状态
+		selfGetNode, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key":          "last",
+			"scope":        types.StateScopeNode,
+			"defaultValue": "none",
+		}, Registry)
+		assert.Nil(t, err)
+
+		var results []string
+		ctx := test.NewRuleContext(types.NewConfig(), func(msg types.RuleMsg, relationType string, err error) {
+			results = append(results, relationType+":"+msg.Metadata.GetValue(DefaultStateMetadataKey))
+		})
+		setNode.OnMsg(&selfIdContext{RuleContext: ctx, selfId: "s1"}, ctx.NewMsg("TEST", types.NewMetadata(), "{}"))
+		getNode.OnMsg(&selfIdContext{RuleContext: ctx, selfId: "s2"}, ctx.NewMsg("TEST", types.NewMetadata(), "{}"))
+		selfGetNode.OnMsg(&selfIdContext{RuleContext: ctx, selfId: "s3"}, ctx.NewMsg("TEST", types.NewMetadata(), "{}"))
+		assert.Equal(t, []string{"Success:", "Success:41", "Success:none"}, results)
+	})
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "stateIncr",
+//        "name": "计数",
+//        "debugMode": false,
+//        "configuration": {
+//          "key": "${deviceId}_overheat",
+//          "scope": "chain",
+//          "delta": 1,
+//          "ttl": 60000,
+//          "metadataKey": "overheatCount"
+//        }
+//  }
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+	"time"
+)
+
+// 注册节点
+func init() {
+	Registry.Add(&StateIncrNode{})
+}
+
+// StateIncrNodeConfiguration 节点配置
+type StateIncrNodeConfiguration struct {
+	//Key 状态key，可以使用${metadataKey}方式从metadata获取
+	Key string
+	//Scope 作用域：global(所有规则链共享)、chain(规则链内共享)、node(节点内共享)，默认chain
+	Scope string
+	//NodeId scope为node时读写的节点ID，为空则使用当前节点ID，用于读写同一规则链其他节点的状态
+	NodeId string
+	//Delta 增量，可以是负数
+	Delta float64
+	//Ttl 过期时间，单位毫秒，>0每次增加都会刷新过期时间，<=0保持原过期时间
+	Ttl int64
+	//MetadataKey 增加后的值保存到metadata的key
+	MetadataKey string
+}
+
+// StateIncrNode 对规则引擎状态存储的数值进行增加，key不存在则从0开始
+// 增加后的值保存到metadata，然后通过`Success`链发送到下一个节点，原值不是数字则发送到`Failure`链
+type StateIncrNode struct {
+	//节点配置
+	Config StateIncrNodeConfiguration
+}
+
+// Type 组件类型
+func (x *StateIncrNode) Type() string {
+	return "stateIncr"
+}
+
+func (x *StateIncrNode) New() types.Node {
+	return &StateIncrNode{Config: StateIncrNodeConfiguration{
+		Scope:       types.StateScopeChain,
+		Delta:       1,
+		MetadataKey: DefaultStateMetadataKey,
+	}}
+}
+
+// Init 初始化
+func (x *StateIncrNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.Config); err != nil {
+		return err
+	}
+	if x.Config.MetadataKey == "" {
+		x.Config.MetadataKey = DefaultStateMetadataKey
+	}
+	return checkStateConfig(x.Config.Key, x.Config.Scope)
+}
+
+// OnMsg 处理消息
+func (x *StateIncrNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	store, err := types.NodeScopedState(ctx, x.Config.Scope, x.Config.NodeId)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	key := str.SprintfDict(x.Config.Key, msg.Metadata.Values())
+	value, err := store.Incr(key, x.Config.Delta, time.Duration(x.Config.Ttl)*time.Millisecond)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.Metadata.PutValue(x.Config.MetadataKey, str.ToString(value))
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *StateIncrNode) Destroy() {
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"testing"
+)
+
+func TestStateIncrNode(t *testing.T) {
+	var targetNodeType = "stateIncr"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &StateIncrNode{}, types.Configuration{
+			"scope":       types.StateScopeChain,
+			"delta":       float64(1),
+			"metadataKey": DefaultStateMetadataKey,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"key":         "${deviceId}_count",
+			"delta":       -0.5,
+			"ttl":         1000,
+			"metadataKey": "",
+		}, types.Configuration{
+			"key":         "${deviceId}_count",
+			"scope":       types.StateScopeChain,
+			"delta":       -0.5,
+			"ttl":         int64(1000),
+			"metadataKey": DefaultStateMetadataKey,
+		}, Registry)
+	})
+
+	t.Run("OnMsg", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key":         "${deviceId}_count",
+			"scope":       "node",
+			"metadataKey": "count",
+		}, Registry)
+		assert.Nil(t, err)
+		var newMetadata = func(deviceId string) types.Metadata {
+			metaData := types.NewMetadata()
+			metaData.PutValue("deviceId", deviceId)
+			return metaData
+		}
+		var results []string
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: newMetadata("aa"), Data: "{}"},
+			{MetaData: newMetadata("aa"), Data: "{}"},
+			{MetaData: newMetadata("bb"), Data: "{}"},
+			{MetaData: newMetadata("aa"), Data: "{}"},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			results = append(results, relationType+":"+msg.Metadata.GetValue("count"))
+		})
+		assert.Equal(t, []string{"Success:1", "Success:2", "Success:1", "Success:3"}, results)
+	})
+
+	t.Run("NotNumber", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key": "name",
+		}, Registry)
+		assert.Nil(t, err)
+		config := types.NewConfig()
+		_ = config.StateStore.Set("chain::name", "aa", 0)
+		ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Failure, relationType)
+			assert.NotNil(t, err)
+		})
+		node.OnMsg(ctx, ctx.NewMsg("TEST", types.NewMetadata(), "{}"))
+	})
+}
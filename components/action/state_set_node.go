@@ -0,0 +1,116 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "stateSet",
+//        "name": "保存状态",
+//        "debugMode": false,
+//        "configuration": {
+//          "key": "${deviceId}_last",
+//          "scope": "chain",
+//          "value": "${temperature}",
+//          "ttl": 60000
+//        }
+//  }
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+	"time"
+)
+
+// 注册节点
+func init() {
+	Registry.Add(&StateSetNode{})
+}
+
+// StateSetNodeConfiguration 节点配置
+type StateSetNodeConfiguration struct {
+	//Key 状态key，可以使用${metadataKey}方式从metadata获取
+	Key string
+	//Scope 作用域：global(所有规则链共享)、chain(规则链内共享)、node(节点内共享)，默认chain
+	Scope string
+	//NodeId scope为node时读写的节点ID，为空则使用当前节点ID，用于读写同一规则链其他节点的状态
+	NodeId string
+	//Value 保存的值，可以使用${metadataKey}方式从metadata获取
+	//为空则保存消息体，JSON类型的消息体保存解析后的值
+	Value string
+	//Ttl 过期时间，单位毫秒，<=0表示永不过期
+	Ttl int64
+}
+
+// StateSetNode 把值保存到规则引擎状态存储，然后把原消息通过`Success`链发送到下一个节点
+// 保存失败则发送到`Failure`链
+type StateSetNode struct {
+	//节点配置
+	Config StateSetNodeConfiguration
+}
+
+// Type 组件类型
+func (x *StateSetNode) Type() string {
+	return "stateSet"
+}
+
+func (x *StateSetNode) New() types.Node {
+	return &StateSetNode{Config: StateSetNodeConfiguration{
+		Scope: types.StateScopeChain,
+	}}
+}
+
+// Init 初始化
+func (x *StateSetNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.Config); err != nil {
+		return err
+	}
+	return checkStateConfig(x.Config.Key, x.Config.Scope)
+}
+
+// OnMsg 处理消息
+func (x *StateSetNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	store, err := types.NodeScopedState(ctx, x.Config.Scope, x.Config.NodeId)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	metadata := msg.Metadata.Values()
+	var value interface{}
+	if x.Config.Value != "" {
+		value = str.SprintfDict(x.Config.Value, metadata)
+	} else {
+		value = msg.Data
+		if msg.DataType == types.JSON {
+			var dataMap interface{}
+			if err := json.Unmarshal([]byte(msg.Data), &dataMap); err == nil {
+				value = dataMap
+			}
+		}
+	}
+	key := str.SprintfDict(x.Config.Key, metadata)
+	if err := store.Set(key, value, time.Duration(x.Config.Ttl)*time.Millisecond); err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *StateSetNode) Destroy() {
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"testing"
+	"time"
+)
+
+func TestStateSetNode(t *testing.T) {
+	var targetNodeType = "stateSet"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &StateSetNode{}, types.Configuration{
+			"scope": types.StateScopeChain,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"key":   "${deviceId}_last",
+			"scope": "node",
+			"value": "${temperature}",
+			"ttl":   1000,
+		}, types.Configuration{
+			"key":   "${deviceId}_last",
+			"scope": "node",
+			"value": "${temperature}",
+			"ttl":   int64(1000),
+		}, Registry)
+	})
+
+	t.Run("OnMsg", func(t *testing.T) {
+		valueNode, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key":   "${deviceId}_temperature",
+			"value": "${temperature}",
+			"ttl":   50,
+		}, Registry)
+		assert.Nil(t, err)
+		dataNode, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key":   "${deviceId}_data",
+			"scope": "global",
+		}, Registry)
+		assert.Nil(t, err)
+
+		config := types.NewConfig()
+		var count int
+		ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string, err error) {
+			count++
+			assert.Equal(t, types.Success, relationType)
+		})
+		metadata := types.NewMetadata()
+		metadata.PutValue("deviceId", "aa")
+		metadata.PutValue("temperature", "41")
+		valueNode.OnMsg(ctx, ctx.NewMsg("TEST", metadata, "{\"temperature\":41}"))
+		dataNode.OnMsg(ctx, ctx.NewMsg("TEST", metadata, "{\"temperature\":41}"))
+		assert.Equal(t, 2, count)
+
+		value, ok, _ := config.StateStore.Get("chain::aa_temperature")
+		assert.True(t, ok)
+		assert.Equal(t, "41", value)
+		value, ok, _ = config.StateStore.Get("global:aa_data")
+		assert.True(t, ok)
+		assert.Equal(t, map[string]interface{}{"temperature": float64(41)}, value)
+
+		time.Sleep(time.Millisecond * 80)
+		_, ok, _ = config.StateStore.Get("chain::aa_temperature")
+		assert.False(t, ok)
+	})
+}
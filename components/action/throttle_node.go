@@ -0,0 +1,170 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "throttle",
+//        "name": "节流",
+//        "debugMode": false,
+//        "configuration": {
+//          "key": "${deviceId}",
+//          "interval": 1000,
+//          "leading": true,
+//          "trailing": false
+//        }
+//  }
+import (
+	"errors"
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+	"sync"
+	"time"
+)
+
+// 注册节点
+func init() {
+	Registry.Add(&ThrottleNode{})
+}
+
+// ThrottleNodeConfiguration 节点配置
+type ThrottleNodeConfiguration struct {
+	//Key 分组键，每个分组独立节流，可以使用${metadataKey}方式从metadata获取，为空则所有消息在同一个分组
+	Key string
+	//Interval 节流间隔，单位毫秒，每个分组每个间隔最多输出一条消息
+	Interval int64
+	//Leading 是否在间隔开始时输出第一条消息
+	Leading bool
+	//Trailing 是否在间隔结束时输出间隔内最后一条消息
+	Trailing bool
+	//MaxKeys 最大分组数量，超过后新分组的消息发送到`Failure`链
+	MaxKeys int
+}
+
+// ThrottleNode 节流节点，每个分组每个间隔最多通过`Success`链输出一条消息，其他消息丢弃
+// Leading=true，间隔内第一条消息立即输出
+// Trailing=true，间隔结束时输出间隔内最后一条未输出的消息，并开始新的间隔
+type ThrottleNode struct {
+	//节点配置
+	Config ThrottleNodeConfiguration
+	//处于节流间隔的分组，key:分组键
+	throttled map[string]*throttleState
+	mu        sync.Mutex
+}
+
+// throttleState 分组节流状态
+type throttleState struct {
+	timer *time.Timer
+	//间隔结束时输出的消息
+	trailing *pendingMsg
+}
+
+// Type 组件类型
+func (x *ThrottleNode) Type() string {
+	return "throttle"
+}
+
+func (x *ThrottleNode) New() types.Node {
+	return &ThrottleNode{Config: ThrottleNodeConfiguration{
+		Interval: 1000,
+		Leading:  true,
+		MaxKeys:  10000,
+	}}
+}
+
+// Init 初始化
+func (x *ThrottleNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	if !x.Config.Leading && !x.Config.Trailing {
+		return errors.New("leading or trailing must be true")
+	}
+	if x.Config.Interval <= 0 {
+		x.Config.Interval = 1000
+	}
+	if x.Config.MaxKeys <= 0 {
+		x.Config.MaxKeys = 10000
+	}
+	x.throttled = make(map[string]*throttleState)
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *ThrottleNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	key := str.SprintfDict(x.Config.Key, msg.Metadata.Values())
+	x.mu.Lock()
+	if state, ok := x.throttled[key]; ok {
+		if x.Config.Trailing {
+			state.trailing = &pendingMsg{ctx: ctx, msg: msg}
+		}
+		x.mu.Unlock()
+		return
+	}
+	if len(x.throttled) >= x.Config.MaxKeys {
+		x.mu.Unlock()
+		ctx.TellFailure(msg, fmt.Errorf("max limit of throttle keys"))
+		return
+	}
+	state := &throttleState{}
+	if !x.Config.Leading {
+		state.trailing = &pendingMsg{ctx: ctx, msg: msg}
+	}
+	x.startInterval(key, state)
+	x.throttled[key] = state
+	x.mu.Unlock()
+
+	if x.Config.Leading {
+		ctx.TellSuccess(msg)
+	}
+}
+
+// Destroy 销毁
+func (x *ThrottleNode) Destroy() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, state := range x.throttled {
+		state.timer.Stop()
+	}
+	x.throttled = make(map[string]*throttleState)
+}
+
+// startInterval 开始节流间隔，间隔结束时如果有trailing消息，则输出该消息并开始新的间隔，否则结束节流
+func (x *ThrottleNode) startInterval(key string, state *throttleState) {
+	state.timer = time.AfterFunc(time.Duration(x.Config.Interval)*time.Millisecond, func() {
+		x.mu.Lock()
+		if x.throttled[key] != state {
+			x.mu.Unlock()
+			return
+		}
+		trailing := state.trailing
+		if trailing == nil {
+			delete(x.throttled, key)
+		} else {
+			state.trailing = nil
+			x.startInterval(key, state)
+		}
+		x.mu.Unlock()
+		if trailing != nil {
+			trailing.ctx.TellSuccess(trailing.msg)
+		}
+	})
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThrottleNode(t *testing.T) {
+	var targetNodeType = "throttle"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &ThrottleNode{}, types.Configuration{
+			"interval": int64(1000),
+			"leading":  true,
+			"trailing": false,
+			"maxKeys":  10000,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"key":      "${deviceId}",
+			"interval": 100,
+			"leading":  false,
+			"trailing": true,
+		}, types.Configuration{
+			"key":      "${deviceId}",
+			"interval": int64(100),
+			"leading":  false,
+			"trailing": true,
+		}, Registry)
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"leading":  false,
+			"trailing": false,
+		}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("DefaultConfig", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"interval": 0,
+			"maxKeys":  0,
+		}, types.Configuration{
+			"interval": int64(1000),
+			"maxKeys":  10000,
+		}, Registry)
+	})
+
+	var run = func(config types.Configuration, msgList []test.Msg) string {
+		node, err := test.CreateAndInitNode(targetNodeType, config, Registry)
+		assert.Nil(t, err)
+		var mu sync.Mutex
+		var result []string
+		test.NodeOnMsg(t, node, msgList, func(msg types.RuleMsg, relationType string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			result = append(result, relationType+":"+msg.Data)
+		})
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Join(result, ",")
+	}
+	metaData1 := types.NewMetadata()
+	metaData1.PutValue("deviceId", "aa")
+	metaData2 := types.NewMetadata()
+	metaData2.PutValue("deviceId", "bb")
+
+	t.Run("Leading", func(t *testing.T) {
+		assert.Equal(t, "Success:1,Success:b1,Failure:c1,Success:4", run(types.Configuration{
+			"key":      "${deviceId}",
+			"interval": 200,
+			"maxKeys":  2,
+		}, []test.Msg{
+			{MetaData: metaData1, Data: "1"},
+			{MetaData: metaData1, Data: "2"},
+			{MetaData: metaData2, Data: "b1"},
+			{MetaData: types.NewMetadata(), Data: "c1"},
+			{MetaData: metaData1, Data: "3", AfterSleep: time.Millisecond * 300},
+			{MetaData: metaData1, Data: "4"},
+		}))
+	})
+
+	t.Run("Trailing", func(t *testing.T) {
+		assert.Equal(t, "Success:1,Success:3", run(types.Configuration{
+			"interval": 100,
+			"trailing": true,
+		}, []test.Msg{
+			{MetaData: metaData1, Data: "1"},
+			{MetaData: metaData1, Data: "2"},
+			{MetaData: metaData1, Data: "3", AfterSleep: time.Millisecond * 300},
+		}))
+		assert.Equal(t, "Success:2", run(types.Configuration{
+			"interval": 100,
+			"leading":  false,
+			"trailing": true,
+		}, []test.Msg{
+			{MetaData: metaData1, Data: "1"},
+			{MetaData: metaData1, Data: "2", AfterSleep: time.Millisecond * 300},
+		}))
+	})
+}
@@ -0,0 +1,387 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "window",
+//        "name": "窗口聚合",
+//        "debugMode": false,
+//        "configuration": {
+//          "type": "tumbling",
+//          "size": 60000,
+//          "key": "${deviceId}",
+//          "field": "temperature",
+//          "aggregations": "count,avg,max"
+//        }
+//  }
+import (
+	"errors"
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/num"
+	"github.com/rulego/rulego/utils/str"
+	"sync"
+	"time"
+)
+
+// 窗口类型
+const (
+	//WindowTypeTumbling 滚动窗口，窗口之间不重叠
+	WindowTypeTumbling = "tumbling"
+	//WindowTypeSliding 滑动窗口，每隔slide时间创建一个size大小的窗口，窗口之间可能重叠
+	WindowTypeSliding = "sliding"
+	//WindowTypeSession 会话窗口，超过gap时间没收到新消息则关闭窗口
+	WindowTypeSession = "session"
+)
+
+// WindowLateMsgErr 消息所属的窗口已经关闭
+var WindowLateMsgErr = errors.New("late message, the window has been closed")
+
+// 注册节点
+func init() {
+	Registry.Add(&WindowNode{})
+}
+
+// WindowNodeConfiguration 节点配置
+type WindowNodeConfiguration struct {
+	//Type 窗口类型：tumbling(滚动窗口)、sliding(滑动窗口)、session(会话窗口)
+	Type string
+	//Size 窗口大小，单位毫秒，tumbling和sliding窗口有效
+	Size int64
+	//Slide 滑动步长，单位毫秒，sliding窗口有效，默认等于Size
+	Slide int64
+	//Gap 会话超时时间，单位毫秒，session窗口有效
+	Gap int64
+	//Key 分组键，每个分组独立计算窗口，可以使用${metadataKey}方式从metadata获取，为空则所有消息在同一个分组
+	Key string
+	//Field 聚合的字段，支持嵌套字段，例如：temperature或者data.temperature，为空则使用整个消息体
+	Field string
+	//TimeField 事件时间字段，值为毫秒时间戳，为空则使用消息时间戳
+	TimeField string
+	//Aggregations 聚合函数，多个与`,`隔开，支持：count、sum、min、max、avg、first、last、collect
+	Aggregations string
+	//AllowedLateness 窗口结束后继续等待迟到消息的时间，单位毫秒
+	//超过该时间，窗口关闭并输出聚合结果，之后到达的消息发送到`Failure`链
+	AllowedLateness int64
+	//MaxKeys 最大分组数量，超过后新分组的消息发送到`Failure`链
+	MaxKeys int
+	//MaxCollectSize 每个窗口collect函数最多收集的值数量，超过后消息发送到`Failure`链
+	MaxCollectSize int
+}
+
+// WindowNode 窗口聚合节点，按分组把消息划分到时间窗口，对指定字段进行聚合计算
+// 窗口关闭时，聚合结果通过`Success`链发送到下一个节点，消息格式：
+//
+//	{
+//	  "key": "分组键",
+//	  "windowStart": 窗口开始毫秒时间戳,
+//	  "windowEnd": 窗口结束毫秒时间戳,
+//	  "count": 10,
+//	  "avg": 25.5
+//	}
+//
+// 聚合结果消息使用窗口内最后一条消息的消息类型和元数据
+// 进入窗口的消息不会流转到下一个节点，迟到或者超过内存限制的消息发送到`Failure`链
+type WindowNode struct {
+	//节点配置
+	Config       WindowNodeConfiguration
+	aggregations []string
+	//分组窗口，key:分组键
+	groups map[string]*windowGroup
+	mu     sync.Mutex
+}
+
+// windowGroup 分组的所有打开的窗口
+type windowGroup struct {
+	//tumbling和sliding窗口，key:窗口开始时间
+	windows map[int64]*window
+	//session窗口
+	session *window
+}
+
+func (g *windowGroup) isEmpty() bool {
+	return len(g.windows) == 0 && g.session == nil
+}
+
+// window 打开的窗口
+type window struct {
+	key   string
+	start int64
+	end   int64
+	agg   *aggregator
+	timer *time.Timer
+	//窗口内最后一条消息和上下文，用于输出聚合结果
+	ctx types.RuleContext
+	msg types.RuleMsg
+}
+
+// Type 组件类型
+func (x *WindowNode) Type() string {
+	return "window"
+}
+
+func (x *WindowNode) New() types.Node {
+	return &WindowNode{Config: WindowNodeConfiguration{
+		Type:           WindowTypeTumbling,
+		Size:           60000,
+		Gap:            60000,
+		Aggregations:   "count,sum,min,max,avg",
+		MaxKeys:        10000,
+		MaxCollectSize: 1000,
+	}}
+}
+
+// Init 初始化
+func (x *WindowNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	switch x.Config.Type {
+	case "":
+		x.Config.Type = WindowTypeTumbling
+	case WindowTypeTumbling, WindowTypeSliding, WindowTypeSession:
+	default:
+		return fmt.Errorf("unsupported window type:%s", x.Config.Type)
+	}
+	if x.Config.Type == WindowTypeSession {
+		if x.Config.Gap <= 0 {
+			return errors.New("gap must be greater than 0")
+		}
+	} else if x.Config.Size <= 0 {
+		return errors.New("size must be greater than 0")
+	}
+	if x.Config.Slide <= 0 || x.Config.Type == WindowTypeTumbling {
+		x.Config.Slide = x.Config.Size
+	}
+	if x.Config.MaxKeys <= 0 {
+		x.Config.MaxKeys = 10000
+	}
+	if x.Config.MaxCollectSize <= 0 {
+		x.Config.MaxCollectSize = 1000
+	}
+	if x.aggregations, err = parseAggregations(x.Config.Aggregations, "count,sum,min,max,avg"); err != nil {
+		return err
+	}
+	x.groups = make(map[string]*windowGroup)
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *WindowNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var data interface{} = msg.Data
+	if msg.DataType == types.JSON {
+		var dataMap interface{}
+		if err := json.Unmarshal([]byte(msg.Data), &dataMap); err == nil {
+			data = dataMap
+		}
+	}
+	value := data
+	if x.Config.Field != "" {
+		value = maps.Get(data, x.Config.Field)
+	}
+	ts := msg.Ts
+	if x.Config.TimeField != "" {
+		v, err := num.ToFloat64(maps.Get(data, x.Config.TimeField))
+		if err != nil {
+			ctx.TellFailure(msg, fmt.Errorf("invalid time field %s:%s", x.Config.TimeField, err.Error()))
+			return
+		}
+		ts = int64(v)
+	}
+	key := str.SprintfDict(x.Config.Key, msg.Metadata.Values())
+
+	var err error
+	var closed *window
+	x.mu.Lock()
+	group, ok := x.groups[key]
+	if !ok {
+		if len(x.groups) >= x.Config.MaxKeys {
+			x.mu.Unlock()
+			ctx.TellFailure(msg, fmt.Errorf("max limit of window keys"))
+			return
+		}
+		group = &windowGroup{windows: make(map[int64]*window)}
+	}
+	if x.Config.Type == WindowTypeSession {
+		closed, err = x.addToSession(ctx, msg, group, key, ts, value)
+	} else {
+		err = x.addToWindows(ctx, msg, group, key, ts, value)
+	}
+	if !group.isEmpty() {
+		x.groups[key] = group
+	} else {
+		delete(x.groups, key)
+	}
+	x.mu.Unlock()
+
+	if closed != nil {
+		x.emit(closed)
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	}
+}
+
+// Destroy 销毁，未关闭的窗口会被丢弃
+func (x *WindowNode) Destroy() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, group := range x.groups {
+		for _, w := range group.windows {
+			w.timer.Stop()
+		}
+		if group.session != nil {
+			group.session.timer.Stop()
+		}
+	}
+	x.groups = make(map[string]*windowGroup)
+}
+
+// addToWindows 把值添加到所属的tumbling或者sliding窗口
+func (x *WindowNode) addToWindows(ctx types.RuleContext, msg types.RuleMsg, group *windowGroup, key string, ts int64, value interface{}) error {
+	now := time.Now().UnixMilli()
+	var starts []int64
+	for start := ts - floorMod(ts, x.Config.Slide); start > ts-x.Config.Size; start -= x.Config.Slide {
+		//忽略已经关闭的窗口
+		if start+x.Config.Size+x.Config.AllowedLateness > now {
+			starts = append(starts, start)
+		}
+	}
+	if len(starts) == 0 {
+		return WindowLateMsgErr
+	}
+	for _, start := range starts {
+		if w, ok := group.windows[start]; ok && w.agg.collect && w.agg.size() >= x.Config.MaxCollectSize {
+			return fmt.Errorf("max limit of window collect size")
+		}
+	}
+	for _, start := range starts {
+		w, ok := group.windows[start]
+		if !ok {
+			w = &window{key: key, start: start, end: start + x.Config.Size, agg: newAggregator(x.aggregations)}
+			w.timer = time.AfterFunc(x.closeDelay(w.end, now), x.closeFunc(key, w))
+			group.windows[start] = w
+		}
+		w.agg.add(value)
+		w.ctx = ctx
+		w.msg = msg
+	}
+	return nil
+}
+
+// addToSession 把值添加到会话窗口，如果消息超过会话超时时间，则返回需要关闭的会话窗口
+func (x *WindowNode) addToSession(ctx types.RuleContext, msg types.RuleMsg, group *windowGroup, key string, ts int64, value interface{}) (*window, error) {
+	now := time.Now().UnixMilli()
+	if ts+x.Config.Gap+x.Config.AllowedLateness <= now {
+		return nil, WindowLateMsgErr
+	}
+	var closed *window
+	w := group.session
+	if w != nil {
+		if ts < w.start-x.Config.Gap {
+			return nil, WindowLateMsgErr
+		}
+		if ts >= w.end {
+			//超过会话超时时间，关闭当前会话
+			w.timer.Stop()
+			closed = w
+			w = nil
+		} else if w.agg.collect && w.agg.size() >= x.Config.MaxCollectSize {
+			return nil, fmt.Errorf("max limit of window collect size")
+		}
+	}
+	if w == nil {
+		w = &window{key: key, start: ts, end: ts + x.Config.Gap, agg: newAggregator(x.aggregations)}
+		group.session = w
+	} else {
+		w.timer.Stop()
+		if ts < w.start {
+			w.start = ts
+		}
+		if ts+x.Config.Gap > w.end {
+			w.end = ts + x.Config.Gap
+		}
+	}
+	w.timer = time.AfterFunc(x.closeDelay(w.end, now), x.closeFunc(key, w))
+	w.agg.add(value)
+	w.ctx = ctx
+	w.msg = msg
+	return closed, nil
+}
+
+// closeDelay 距离窗口关闭的时间
+func (x *WindowNode) closeDelay(end, now int64) time.Duration {
+	delay := end + x.Config.AllowedLateness - now
+	if delay < 0 {
+		delay = 0
+	}
+	return time.Duration(delay) * time.Millisecond
+}
+
+// closeFunc 窗口定时关闭
+func (x *WindowNode) closeFunc(key string, w *window) func() {
+	return func() {
+		x.mu.Lock()
+		group, ok := x.groups[key]
+		if !ok {
+			x.mu.Unlock()
+			return
+		}
+		if group.session == w {
+			group.session = nil
+		} else if group.windows[w.start] == w {
+			delete(group.windows, w.start)
+		} else {
+			//窗口已经关闭
+			x.mu.Unlock()
+			return
+		}
+		if group.isEmpty() {
+			delete(x.groups, key)
+		}
+		x.mu.Unlock()
+		x.emit(w)
+	}
+}
+
+// emit 输出窗口聚合结果
+func (x *WindowNode) emit(w *window) {
+	result := w.agg.result(x.aggregations)
+	result["key"] = w.key
+	result["windowStart"] = w.start
+	result["windowEnd"] = w.end
+	data, err := json.Marshal(result)
+	if err != nil {
+		w.ctx.TellFailure(w.msg, err)
+		return
+	}
+	w.ctx.TellSuccess(w.ctx.NewMsg(w.msg.Type, w.msg.Metadata.Copy(), string(data)))
+}
+
+// floorMod 向下取模，结果总是非负数
+func floorMod(v, m int64) int64 {
+	r := v % m
+	if r < 0 {
+		r += m
+	}
+	return r
+}
@@ -0,0 +1,255 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"github.com/rulego/rulego/utils/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+// windowResults 收集节点输出结果
+type windowResults struct {
+	mu       sync.Mutex
+	success  []map[string]interface{}
+	failures []error
+}
+
+func (r *windowResults) callback(msg types.RuleMsg, relationType string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if relationType == types.Success {
+		var result map[string]interface{}
+		_ = json.Unmarshal([]byte(msg.Data), &result)
+		r.success = append(r.success, result)
+	} else {
+		r.failures = append(r.failures, err)
+	}
+}
+
+// find 查找指定窗口开始时间的结果
+func (r *windowResults) find(windowStart int64) map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, item := range r.success {
+		if int64(item["windowStart"].(float64)) == windowStart {
+			return item
+		}
+	}
+	return nil
+}
+
+func TestWindowNode(t *testing.T) {
+	var targetNodeType = "window"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &WindowNode{}, types.Configuration{
+			"type":           WindowTypeTumbling,
+			"size":           int64(60000),
+			"aggregations":   "count,sum,min,max,avg",
+			"maxKeys":        10000,
+			"maxCollectSize": 1000,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"type":         WindowTypeSliding,
+			"size":         1000,
+			"slide":        500,
+			"key":          "${deviceId}",
+			"aggregations": "avg,collect",
+		}, types.Configuration{
+			"type":         WindowTypeSliding,
+			"size":         int64(1000),
+			"slide":        int64(500),
+			"key":          "${deviceId}",
+			"aggregations": "avg,collect",
+		}, Registry)
+
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"type": "aa",
+		}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"size": -1,
+		}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"aggregations": "count,median",
+		}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("DefaultConfig", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"size":           1000,
+			"maxKeys":        -1,
+			"maxCollectSize": 0,
+		}, types.Configuration{
+			"type":           WindowTypeTumbling,
+			"slide":          int64(1000),
+			"maxKeys":        10000,
+			"maxCollectSize": 1000,
+		}, Registry)
+	})
+
+	t.Run("Tumbling", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"size":            100,
+			"allowedLateness": 300,
+			"key":             "${deviceId}",
+			"field":           "temperature",
+			"timeField":       "ts",
+			"aggregations":    "count,sum,min,max,avg,first,last,collect",
+		}, Registry)
+		assert.Nil(t, err)
+
+		now := time.Now().UnixMilli()
+		start := now - now%100
+		metaData := types.NewMetadata()
+		metaData.PutValue("deviceId", "aa")
+		newMsg := func(ts int64, temperature interface{}) test.Msg {
+			return test.Msg{
+				MetaData: metaData,
+				MsgType:  "TELEMETRY",
+				Data:     fmt.Sprintf(`{"ts":%d,"temperature":%v}`, ts, temperature),
+			}
+		}
+		results := &windowResults{}
+		msgList := []test.Msg{
+			newMsg(start+1, 20),
+			newMsg(start+2, 30),
+			newMsg(start+3, `"aa"`),
+			newMsg(start+50, 10),
+			//迟到的消息
+			newMsg(start-1000, 10),
+		}
+		msgList[len(msgList)-1].AfterSleep = time.Millisecond * 600
+		test.NodeOnMsg(t, node, msgList, results.callback)
+
+		assert.Equal(t, 1, len(results.failures))
+		assert.Equal(t, WindowLateMsgErr, results.failures[0])
+		assert.Equal(t, 1, len(results.success))
+		result := results.find(start)
+		assert.NotNil(t, result)
+		assert.Equal(t, "aa", result["key"])
+		assert.Equal(t, float64(start+100), result["windowEnd"])
+		assert.Equal(t, float64(4), result["count"])
+		assert.Equal(t, float64(60), result["sum"])
+		assert.Equal(t, float64(10), result["min"])
+		assert.Equal(t, float64(30), result["max"])
+		assert.Equal(t, float64(20), result["avg"])
+		assert.Equal(t, float64(20), result["first"])
+		assert.Equal(t, float64(10), result["last"])
+		assert.Equal(t, 4, len(result["collect"].([]interface{})))
+	})
+
+	t.Run("Sliding", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"type":            WindowTypeSliding,
+			"size":            100,
+			"slide":           50,
+			"allowedLateness": 300,
+			"field":           "temperature",
+			"timeField":       "ts",
+			"aggregations":    "count,sum",
+		}, Registry)
+		assert.Nil(t, err)
+
+		now := time.Now().UnixMilli()
+		start := now - now%50
+		results := &windowResults{}
+		msgList := []test.Msg{
+			{MetaData: types.NewMetadata(), Data: fmt.Sprintf(`{"ts":%d,"temperature":1}`, start+10)},
+			{MetaData: types.NewMetadata(), Data: fmt.Sprintf(`{"ts":%d,"temperature":2}`, start+60), AfterSleep: time.Millisecond * 700},
+		}
+		test.NodeOnMsg(t, node, msgList, results.callback)
+
+		assert.Equal(t, 0, len(results.failures))
+		assert.Equal(t, 3, len(results.success))
+		assert.Equal(t, float64(1), results.find(start - 50)["sum"])
+		assert.Equal(t, float64(3), results.find(start)["sum"])
+		assert.Equal(t, float64(2), results.find(start)["count"])
+		assert.Equal(t, float64(2), results.find(start + 50)["sum"])
+	})
+
+	t.Run("Session", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"type":         WindowTypeSession,
+			"gap":          100,
+			"timeField":    "ts",
+			"aggregations": "count,collect",
+		}, Registry)
+		assert.Nil(t, err)
+
+		now := time.Now().UnixMilli()
+		results := &windowResults{}
+		msgList := []test.Msg{
+			{MetaData: types.NewMetadata(), Data: fmt.Sprintf(`{"ts":%d}`, now)},
+			{MetaData: types.NewMetadata(), Data: fmt.Sprintf(`{"ts":%d}`, now+50)},
+			//超过会话超时时间，关闭前一个会话
+			{MetaData: types.NewMetadata(), Data: fmt.Sprintf(`{"ts":%d}`, now+200), AfterSleep: time.Millisecond * 500},
+		}
+		test.NodeOnMsg(t, node, msgList, results.callback)
+
+		assert.Equal(t, 0, len(results.failures))
+		assert.Equal(t, 2, len(results.success))
+		assert.Equal(t, float64(2), results.find(now)["count"])
+		assert.Equal(t, float64(now+150), results.find(now)["windowEnd"])
+		assert.Equal(t, float64(1), results.find(now + 200)["count"])
+	})
+
+	t.Run("MaxLimit", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"size":            100,
+			"allowedLateness": 300,
+			"timeField":       "ts",
+			"key":             "${deviceId}",
+			"aggregations":    "collect",
+			"maxKeys":         1,
+			"maxCollectSize":  2,
+		}, Registry)
+		assert.Nil(t, err)
+
+		metaData1 := types.NewMetadata()
+		metaData1.PutValue("deviceId", "aa")
+		metaData2 := types.NewMetadata()
+		metaData2.PutValue("deviceId", "bb")
+		now := time.Now().UnixMilli()
+		data := fmt.Sprintf(`{"ts":%d}`, now)
+		results := &windowResults{}
+		msgList := []test.Msg{
+			{MetaData: metaData1, Data: data},
+			{MetaData: metaData1, Data: data},
+			//超过collect限制
+			{MetaData: metaData1, Data: data},
+			//超过分组数量限制
+			{MetaData: metaData2, Data: data},
+			{MetaData: metaData1, Data: `{"ts":"aa"}`, AfterSleep: time.Millisecond * 500},
+		}
+		test.NodeOnMsg(t, node, msgList, results.callback)
+		assert.Equal(t, 3, len(results.failures))
+		assert.Equal(t, 1, len(results.success))
+		assert.Equal(t, 2, len(results.find(now - now%100)["collect"].([]interface{})))
+	})
+}
@@ -0,0 +1,265 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package expr
+
+import (
+	"fmt"
+	"github.com/expr-lang/expr"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/num"
+	"github.com/rulego/rulego/utils/str"
+	"github.com/rulego/rulego/utils/times"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Builtins 表达式内置函数库，作为expr-lang自带函数(upper、trim、split、abs、round、toJSON等)的补充
+//
+// json：jsonParse(str) jsonStringify(value)
+// 正则：regexMatch(pattern,str) regexFind(pattern,str) regexFindAll(pattern,str) regexReplace(pattern,str,replacement)
+// 时间，layout 使用 yyyy-MM-dd HH:mm:ss.SSS 风格或者go时间格式：
+// nowMilli() 当前毫秒时间戳；parseTime(str,layout) 返回毫秒时间戳；formatTime(ms,layout)
+// 数学：pow(x,y) sqrt(x) log(x) log10(x) toNumber(value)
+// 字符串：toString(value) substring(str,start,end) padLeft(str,length,pad) padRight(str,length,pad) format(format,args...)
+var Builtins = map[string]func(params ...interface{}) (interface{}, error){
+	"jsonParse": func(params ...interface{}) (interface{}, error) {
+		if err := checkArgs("jsonParse", params, 1); err != nil {
+			return nil, err
+		}
+		var v interface{}
+		err := json.Unmarshal([]byte(str.ToString(params[0])), &v)
+		return v, err
+	},
+	"jsonStringify": func(params ...interface{}) (interface{}, error) {
+		if err := checkArgs("jsonStringify", params, 1); err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(params[0])
+		return string(b), err
+	},
+	"regexMatch": func(params ...interface{}) (interface{}, error) {
+		re, s, err := regexArgs("regexMatch", params, 2)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(s), nil
+	},
+	"regexFind": func(params ...interface{}) (interface{}, error) {
+		re, s, err := regexArgs("regexFind", params, 2)
+		if err != nil {
+			return nil, err
+		}
+		return re.FindString(s), nil
+	},
+	"regexFindAll": func(params ...interface{}) (interface{}, error) {
+		re, s, err := regexArgs("regexFindAll", params, 2)
+		if err != nil {
+			return nil, err
+		}
+		var result []interface{}
+		for _, item := range re.FindAllString(s, -1) {
+			result = append(result, item)
+		}
+		return result, nil
+	},
+	"regexReplace": func(params ...interface{}) (interface{}, error) {
+		re, s, err := regexArgs("regexReplace", params, 3)
+		if err != nil {
+			return nil, err
+		}
+		return re.ReplaceAllString(s, str.ToString(params[2])), nil
+	},
+	"nowMilli": func(params ...interface{}) (interface{}, error) {
+		return time.Now().UnixMilli(), nil
+	},
+	"parseTime": func(params ...interface{}) (interface{}, error) {
+		if err := checkArgs("parseTime", params, 2); err != nil {
+			return nil, err
+		}
+		t, err := times.Parse(str.ToString(params[1]), str.ToString(params[0]))
+		if err != nil {
+			return nil, err
+		}
+		return t.UnixMilli(), nil
+	},
+	"formatTime": func(params ...interface{}) (interface{}, error) {
+		if err := checkArgs("formatTime", params, 2); err != nil {
+			return nil, err
+		}
+		var t time.Time
+		if v, ok := params[0].(time.Time); ok {
+			t = v
+		} else if ms, err := num.ToFloat64(params[0]); err == nil {
+			t = times.FromUnixMilli(int64(ms))
+		} else {
+			return nil, err
+		}
+		return times.Format(t, str.ToString(params[1])), nil
+	},
+	"pow": func(params ...interface{}) (interface{}, error) {
+		values, err := floatArgs("pow", params, 2)
+		if err != nil {
+			return nil, err
+		}
+		return math.Pow(values[0], values[1]), nil
+	},
+	"sqrt":  mathFunc("sqrt", math.Sqrt),
+	"log":   mathFunc("log", math.Log),
+	"log10": mathFunc("log10", math.Log10),
+	"toNumber": func(params ...interface{}) (interface{}, error) {
+		if err := checkArgs("toNumber", params, 1); err != nil {
+			return nil, err
+		}
+		return num.ToFloat64(params[0])
+	},
+	"toString": func(params ...interface{}) (interface{}, error) {
+		if err := checkArgs("toString", params, 1); err != nil {
+			return nil, err
+		}
+		return str.ToStringMaybeErr(params[0])
+	},
+	"substring": func(params ...interface{}) (interface{}, error) {
+		if len(params) != 2 && len(params) != 3 {
+			return nil, fmt.Errorf("substring: invalid number of arguments (expected 2 or 3, got %d)", len(params))
+		}
+		runes := []rune(str.ToString(params[0]))
+		start, err := num.ToFloat64(params[1])
+		if err != nil {
+			return nil, err
+		}
+		end := float64(len(runes))
+		if len(params) == 3 {
+			if end, err = num.ToFloat64(params[2]); err != nil {
+				return nil, err
+			}
+		}
+		s, e := clamp(int(start), len(runes)), clamp(int(end), len(runes))
+		if s > e {
+			return "", nil
+		}
+		return string(runes[s:e]), nil
+	},
+	"padLeft":  padFunc("padLeft", true),
+	"padRight": padFunc("padRight", false),
+	"format": func(params ...interface{}) (interface{}, error) {
+		if len(params) == 0 {
+			return nil, fmt.Errorf("format: invalid number of arguments (expected at least 1, got 0)")
+		}
+		return fmt.Sprintf(str.ToString(params[0]), params[1:]...), nil
+	},
+}
+
+// builtinOptions 内置函数编译选项
+var builtinOptions []expr.Option
+
+// regexCache 已编译的正则表达式缓存
+var regexCache = newLruCache(maxRegexCacheSize)
+
+func init() {
+	for name, f := range Builtins {
+		builtinOptions = append(builtinOptions, expr.Function(name, f))
+	}
+}
+
+func checkArgs(name string, params []interface{}, n int) error {
+	if len(params) != n {
+		return fmt.Errorf("%s: invalid number of arguments (expected %d, got %d)", name, n, len(params))
+	}
+	return nil
+}
+
+func regexArgs(name string, params []interface{}, n int) (*regexp.Regexp, string, error) {
+	if err := checkArgs(name, params, n); err != nil {
+		return nil, "", err
+	}
+	pattern := str.ToString(params[0])
+	if v, ok := regexCache.Load(pattern); ok {
+		return v.(*regexp.Regexp), str.ToString(params[1]), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, "", err
+	}
+	regexCache.Store(pattern, re)
+	return re, str.ToString(params[1]), nil
+}
+
+func floatArgs(name string, params []interface{}, n int) ([]float64, error) {
+	if err := checkArgs(name, params, n); err != nil {
+		return nil, err
+	}
+	var values = make([]float64, n)
+	for i, item := range params {
+		v, err := num.ToFloat64(item)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func mathFunc(name string, f func(float64) float64) func(params ...interface{}) (interface{}, error) {
+	return func(params ...interface{}) (interface{}, error) {
+		values, err := floatArgs(name, params, 1)
+		if err != nil {
+			return nil, err
+		}
+		return f(values[0]), nil
+	}
+}
+
+func padFunc(name string, left bool) func(params ...interface{}) (interface{}, error) {
+	return func(params ...interface{}) (interface{}, error) {
+		if err := checkArgs(name, params, 3); err != nil {
+			return nil, err
+		}
+		s := str.ToString(params[0])
+		length, err := num.ToFloat64(params[1])
+		if err != nil {
+			return nil, err
+		}
+		pad := str.ToString(params[2])
+		if pad == "" {
+			return s, nil
+		}
+		var builder strings.Builder
+		for n := int(length) - len([]rune(s)); n > 0; n -= len([]rune(pad)) {
+			builder.WriteString(pad)
+		}
+		padding := []rune(builder.String())
+		if over := len(padding) + len([]rune(s)) - int(length); over > 0 {
+			padding = padding[:len(padding)-over]
+		}
+		if left {
+			return string(padding) + s, nil
+		}
+		return s + string(padding), nil
+	}
+}
+
+func clamp(v, max int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package expr
+
+import (
+	"container/list"
+	"sync"
+)
+
+// 缓存最大数量
+const (
+	//maxProgramCacheSize 已编译表达式最大缓存数量
+	maxProgramCacheSize = 1024
+	//maxRegexCacheSize 已编译正则表达式最大缓存数量，正则表达式可能来自消息，需要限制数量
+	maxRegexCacheSize = 256
+)
+
+// lruCache 基于LRU淘汰的并发安全缓存
+type lruCache struct {
+	maxSize int
+	items   map[string]*list.Element
+	//最近使用的在队首
+	ll *list.List
+	mu sync.Mutex
+}
+
+type lruCacheEntry struct {
+	key   string
+	value interface{}
+}
+
+func newLruCache(maxSize int) *lruCache {
+	return &lruCache{
+		maxSize: maxSize,
+		items:   make(map[string]*list.Element),
+		ll:      list.New(),
+	}
+}
+
+// Load 获取缓存的值
+func (c *lruCache) Load(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*lruCacheEntry).value, true
+	}
+	return nil, false
+}
+
+// Store 缓存值，超过最大数量淘汰最久没有使用的值
+func (c *lruCache) Store(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		e.Value.(*lruCacheEntry).value = value
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruCacheEntry{key: key, value: value})
+	for c.ll.Len() > c.maxSize {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruCacheEntry).key)
+	}
+}
+
+// Len 缓存数量
+func (c *lruCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
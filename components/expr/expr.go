@@ -0,0 +1,156 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package expr expr-lang 表达式引擎封装
+// 提供内置函数库，以及调用`Config.Udf`注册的go函数能力，编译结果按表达式和环境变量类型缓存，超过最大数量按LRU淘汰
+package expr
+
+import (
+	"errors"
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/rulego/rulego/api/types"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var errStateUnavailable = errors.New("state store is not available")
+
+// programCache 已编译的表达式缓存，key：表达式+注册的go函数签名
+var programCache = newLruCache(maxProgramCacheSize)
+
+// Compile 编译表达式，环境变量包括：msg、metadata、msgType、dataType、`Config.Udf`注册的go函数
+// 同一个表达式，如果注册的go函数签名一致，则复用已编译的结果
+func Compile(config types.Config, expression string) (*vm.Program, error) {
+	return compile(config, expression, false)
+}
+
+// CompileAsBool 编译表达式，表达式结果必须是bool类型
+func CompileAsBool(config types.Config, expression string) (*vm.Program, error) {
+	return compile(config, expression, true)
+}
+
+func compile(config types.Config, expression string, asBool bool) (*vm.Program, error) {
+	//消息相关变量运行时才确定类型，编译时只声明函数
+	env := UdfEnv(config)
+	cacheKey := envSignature(env) + "|" + expression
+	if asBool {
+		cacheKey = "bool|" + cacheKey
+	}
+	if v, ok := programCache.Load(cacheKey); ok {
+		return v.(*vm.Program), nil
+	}
+	opts := append([]expr.Option{expr.Env(env), expr.AllowUndefinedVariables()}, builtinOptions...)
+	if asBool {
+		opts = append(opts, expr.AsBool())
+	}
+	program, err := expr.Compile(expression, opts...)
+	if err != nil {
+		return nil, err
+	}
+	programCache.Store(cacheKey, program)
+	return program, nil
+}
+
+// NewEnv 创建表达式执行环境变量
+// udf 通过`UdfEnv`获取的函数，应该在节点初始化时获取并复用
+// data 消息体，如果消息是JSON类型，应该传入解析后的值
+func NewEnv(udf map[string]interface{}, msg types.RuleMsg, data interface{}) map[string]interface{} {
+	env := make(map[string]interface{}, len(udf)+4)
+	for k, v := range udf {
+		env[k] = v
+	}
+	env[types.MsgKey] = data
+	env[types.MetadataKey] = msg.Metadata
+	env[types.MsgTypeKey] = msg.Type
+	env[types.DataTypeKey] = msg.DataType
+	return env
+}
+
+// NewEnvWithContext 创建表达式执行环境变量，并注入当前上下文规则链作用域的状态存储函数：
+// stateGet(key)、stateSet(key, value)、stateIncr(key, delta)
+func NewEnvWithContext(ctx types.RuleContext, udf map[string]interface{}, msg types.RuleMsg, data interface{}) map[string]interface{} {
+	env := NewEnv(udf, msg, data)
+	if ctx == nil {
+		return env
+	}
+	store, err := types.ScopedState(ctx, types.StateScopeChain)
+	if err != nil {
+		return env
+	}
+	for k, v := range stateFunctions(store) {
+		env[k] = v
+	}
+	return env
+}
+
+// stateFunctions 状态存储函数，store为nil时调用返回错误
+func stateFunctions(store types.StateStore) map[string]interface{} {
+	return map[string]interface{}{
+		"stateGet": func(key string) (interface{}, error) {
+			if store == nil {
+				return nil, errStateUnavailable
+			}
+			value, _, err := store.Get(key)
+			return value, err
+		},
+		"stateSet": func(key string, value interface{}) (interface{}, error) {
+			if store == nil {
+				return nil, errStateUnavailable
+			}
+			return value, store.Set(key, value, 0)
+		},
+		"stateIncr": func(key string, delta float64) (float64, error) {
+			if store == nil {
+				return 0, errStateUnavailable
+			}
+			return store.Incr(key, delta, 0)
+		},
+	}
+}
+
+// UdfEnv 获取状态存储函数和`Config.Udf`注册的go函数
+// 需要通过反射过滤函数，节点应该在初始化时获取一次，然后传给`NewEnv`复用
+func UdfEnv(config types.Config) map[string]interface{} {
+	var env = stateFunctions(nil)
+	for k, v := range config.Udf {
+		//只允许调用go函数，脚本类型的函数由对应的脚本引擎处理
+		if v != nil && reflect.TypeOf(v).Kind() == reflect.Func {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+// Run 执行表达式
+func Run(program *vm.Program, env map[string]interface{}) (interface{}, error) {
+	return vm.Run(program, env)
+}
+
+// envSignature 函数签名
+func envSignature(env map[string]interface{}) string {
+	var items []string
+	for k, v := range env {
+		typeName := "nil"
+		if v != nil {
+			typeName = reflect.TypeOf(v).String()
+		}
+		items = append(items, k+":"+typeName)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package expr
+
+import (
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"testing"
+	"time"
+)
+
+func TestBuiltins(t *testing.T) {
+	config := types.NewConfig()
+	var testcases = []struct {
+		expr     string
+		expected interface{}
+	}{
+		{expr: `jsonParse('{"a":1}').a`, expected: float64(1)},
+		{expr: `jsonStringify({"a":"b"})`, expected: `{"a":"b"}`},
+		{expr: `regexMatch('^t\\d+$', 't01')`, expected: true},
+		{expr: `regexMatch('^t\\d+$', 'a01')`, expected: false},
+		{expr: `regexFind('\\d+', 'ab12cd34')`, expected: "12"},
+		{expr: `len(regexFindAll('\\d+', 'ab12cd34'))`, expected: 2},
+		{expr: `regexReplace('\\d', 'a1b2', '*')`, expected: "a*b*"},
+		{expr: `formatTime(parseTime('2023-11-22 10:20:30', 'yyyy-MM-dd HH:mm:ss'), 'yyyy/MM/dd HH:mm')`, expected: "2023/11/22 10:20"},
+		{expr: `pow(2, 10)`, expected: float64(1024)},
+		{expr: `sqrt(16)`, expected: float64(4)},
+		{expr: `log10(100)`, expected: float64(2)},
+		{expr: `toNumber('12.5') + 1`, expected: 13.5},
+		{expr: `toString(12)`, expected: "12"},
+		{expr: `substring('rulego', 0, 4)`, expected: "rule"},
+		{expr: `substring('rulego', 4)`, expected: "go"},
+		{expr: `substring('rulego', 4, 100)`, expected: "go"},
+		{expr: `padLeft('7', 3, '0')`, expected: "007"},
+		{expr: `padRight('ab', 5, 'xy')`, expected: "abxyx"},
+		{expr: `format('%s-%d', 'a', 1)`, expected: "a-1"},
+	}
+	for _, item := range testcases {
+		program, err := Compile(config, item.expr)
+		assert.Nil(t, err)
+		out, err := Run(program, NewEnv(UdfEnv(config), types.RuleMsg{}, nil))
+		assert.Nil(t, err)
+		assert.Equal(t, item.expected, out)
+	}
+
+	program, err := Compile(config, `nowMilli()`)
+	assert.Nil(t, err)
+	out, err := Run(program, NewEnv(UdfEnv(config), types.RuleMsg{}, nil))
+	assert.Nil(t, err)
+	assert.True(t, out.(int64) <= time.Now().UnixMilli())
+
+	program, err = Compile(config, `pow('a', 1)`)
+	assert.Nil(t, err)
+	_, err = Run(program, NewEnv(UdfEnv(config), types.RuleMsg{}, nil))
+	assert.NotNil(t, err)
+}
+
+func TestUdf(t *testing.T) {
+	config := types.NewConfig()
+	config.RegisterUdf("add", func(a, b float64) float64 {
+		return a + b
+	})
+	//脚本函数不会注入
+	config.RegisterUdf("jsFunc", "function jsFunc(){return 1}")
+
+	metadata := types.NewMetadata()
+	metadata.PutValue("productType", "test")
+	msg := types.NewMsg(0, "TEST_MSG_TYPE", types.JSON, metadata, `{"a":1,"b":2}`)
+	data := map[string]interface{}{"a": float64(1), "b": float64(2)}
+
+	program, err := CompileAsBool(config, "add(msg.a, msg.b) == 3 && metadata.productType == 'test' && msgType == 'TEST_MSG_TYPE'")
+	assert.Nil(t, err)
+	out, err := Run(program, NewEnv(UdfEnv(config), msg, data))
+	assert.Nil(t, err)
+	assert.Equal(t, true, out)
+
+	program, err = Compile(config, "jsFunc()")
+	assert.Nil(t, err)
+	_, err = Run(program, NewEnv(UdfEnv(config), msg, data))
+	assert.NotNil(t, err)
+
+	_, err = CompileAsBool(config, "add(msg.a, msg.b)")
+	assert.NotNil(t, err)
+
+	//没注册该函数
+	program, err = Compile(types.NewConfig(), "add(msg.a, msg.b)")
+	assert.Nil(t, err)
+	_, err = Run(program, NewEnv(UdfEnv(types.NewConfig()), msg, data))
+	assert.NotNil(t, err)
+}
+
+func TestCompileCache(t *testing.T) {
+	config := types.NewConfig()
+	program1, err := Compile(config, "msg.a + 1")
+	assert.Nil(t, err)
+	program2, err := Compile(config, "msg.a + 1")
+	assert.Nil(t, err)
+	assert.True(t, program1 == program2)
+
+	program3, err := CompileAsBool(config, "msg.a + 1 > 1")
+	assert.Nil(t, err)
+	program4, err := Compile(config, "msg.a + 1 > 1")
+	assert.Nil(t, err)
+	assert.True(t, program3 != program4)
+
+	config.RegisterUdf("add", func(a, b int) int {
+		return a + b
+	})
+	program5, err := Compile(config, "msg.a + 1")
+	assert.Nil(t, err)
+	assert.True(t, program1 != program5)
+
+	//缓存数量有限制，淘汰最久没有使用的
+	cache := newLruCache(2)
+	cache.Store("a", 1)
+	cache.Store("b", 2)
+	_, _ = cache.Load("a")
+	cache.Store("c", 3)
+	assert.Equal(t, 2, cache.Len())
+	_, ok := cache.Load("b")
+	assert.False(t, ok)
+	v, ok := cache.Load("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	//消息中的正则表达式不会无限缓存
+	program, err := Compile(config, "regexMatch(msg.pattern, msg.value)")
+	assert.Nil(t, err)
+	for i := 0; i < maxRegexCacheSize+10; i++ {
+		_, err = Run(program, NewEnv(UdfEnv(config), types.RuleMsg{Metadata: types.NewMetadata()}, map[string]interface{}{
+			"pattern": fmt.Sprintf("^a%d$", i),
+			"value":   "a1",
+		}))
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, maxRegexCacheSize, regexCache.Len())
+}
+
+func TestState(t *testing.T) {
+	config := types.NewConfig()
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string, err error) {})
+	msg := types.NewMsg(0, "TEST_MSG_TYPE", types.JSON, types.NewMetadata(), "{}")
+
+	program, err := Compile(config, `stateIncr('count', 1) > 2 ? 'alarm' : stateGet('count')`)
+	assert.Nil(t, err)
+	var results []interface{}
+	for i := 0; i < 3; i++ {
+		out, err := Run(program, NewEnvWithContext(ctx, UdfEnv(config), msg, map[string]interface{}{"temperature": 50}))
+		assert.Nil(t, err)
+		results = append(results, out)
+	}
+	assert.Equal(t, []interface{}{float64(1), float64(2), "alarm"}, results)
+
+	program, err = Compile(config, `stateSet('last', msg.temperature) + 1`)
+	assert.Nil(t, err)
+	out, err := Run(program, NewEnvWithContext(ctx, UdfEnv(config), msg, map[string]interface{}{"temperature": 50}))
+	assert.Nil(t, err)
+	assert.Equal(t, 51, out)
+	value, ok, _ := ctx.StateStore().Get("chain::last")
+	assert.True(t, ok)
+	assert.Equal(t, 50, value)
+
+	//没有上下文，无法访问状态
+	_, err = Run(program, NewEnv(UdfEnv(config), msg, map[string]interface{}{"temperature": 50}))
+	assert.NotNil(t, err)
+}
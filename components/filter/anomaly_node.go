@@ -0,0 +1,289 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "anomaly",
+//        "name": "温度异常检测",
+//        "debugMode": false,
+//        "configuration": {
+//          "key": "${deviceId}",
+//          "field": "temperature",
+//          "method": "zscore",
+//          "window": 30,
+//          "threshold": 3
+//        }
+//      }
+import (
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/num"
+	"github.com/rulego/rulego/utils/str"
+	"math"
+	"sync"
+	"time"
+)
+
+// 异常检测节点关系类型
+const (
+	//AnomalyNormal 正常
+	AnomalyNormal = "Normal"
+	//AnomalyDetected 异常
+	AnomalyDetected = "Anomaly"
+)
+
+// 异常检测方法
+const (
+	//AnomalyZScore 最近window个值的均值和标准差
+	AnomalyZScore = "zscore"
+	//AnomalyEwma 指数加权移动平均的均值和标准差
+	AnomalyEwma = "ewma"
+)
+
+// 异常检测节点输出的metadata
+const (
+	AnomalyScoreKey  = "anomalyScore"
+	AnomalyMeanKey   = "anomalyMean"
+	AnomalyStddevKey = "anomalyStddev"
+)
+
+func init() {
+	Registry.Add(&AnomalyNode{})
+}
+
+// AnomalyNodeConfiguration 节点配置
+type AnomalyNodeConfiguration struct {
+	//Key 分组键，每个分组独立统计，可以使用${metadataKey}方式从metadata获取
+	Key string
+	//Field 检测的字段，支持嵌套字段，例如：temperature或者data.temperature
+	Field string
+	//Method 检测方法：zscore、ewma
+	Method string
+	//Window zscore滚动窗口大小
+	Window int
+	//Alpha ewma平滑系数，取值(0,1]，越大越偏重最近的值
+	Alpha float64
+	//Threshold 分数绝对值超过该值则认为是异常
+	Threshold float64
+	//MinSamples 样本数量达到该值才开始检测，之前的消息都认为正常
+	MinSamples int
+	//Ttl 分组统计值过期时间，单位毫秒，分组超过该时间没有新消息则重新统计，<=0表示永不过期
+	Ttl int64
+}
+
+// AnomalyNode 异常检测节点，使用消息之前的统计值计算分数：(value-mean)/stddev
+// 分数绝对值超过阈值通过`Anomaly`链发送到下一个节点，否则通过`Normal`链发送到下一个节点，字段不是数字则发送到`Failure`链
+// 分数、均值和标准差写入metadata：anomalyScore、anomalyMean、anomalyStddev
+// 标准差为0时，和均值不相等的值分数为+Inf或者-Inf
+// 分组统计值以节点作用域保存在`types.Config.StateStore`，使用持久化的状态存储，重启后不需要重新统计
+type AnomalyNode struct {
+	//节点配置
+	Config AnomalyNodeConfiguration
+	//保证统计值读取和修改的原子性
+	mu sync.Mutex
+}
+
+// anomalyStats 分组统计值
+type anomalyStats struct {
+	//Values zscore滚动窗口的值
+	Values []float64 `json:"values,omitempty"`
+	//Count 样本数量
+	Count    int     `json:"count"`
+	Mean     float64 `json:"mean"`
+	Variance float64 `json:"variance"`
+}
+
+// Type 组件类型
+func (x *AnomalyNode) Type() string {
+	return "anomaly"
+}
+
+func (x *AnomalyNode) New() types.Node {
+	return &AnomalyNode{Config: AnomalyNodeConfiguration{
+		Field:      "temperature",
+		Method:     AnomalyZScore,
+		Window:     30,
+		Alpha:      0.3,
+		Threshold:  3,
+		MinSamples: 5,
+		Ttl:        86400000,
+	}}
+}
+
+// Init 初始化
+func (x *AnomalyNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.Config); err != nil {
+		return err
+	}
+	if x.Config.Field == "" {
+		return fmt.Errorf("field is empty")
+	}
+	switch x.Config.Method {
+	case "":
+		x.Config.Method = AnomalyZScore
+	case AnomalyZScore, AnomalyEwma:
+	default:
+		return fmt.Errorf("unsupported method: %s", x.Config.Method)
+	}
+	if x.Config.Window <= 1 {
+		x.Config.Window = 30
+	}
+	if x.Config.Alpha <= 0 || x.Config.Alpha > 1 {
+		return fmt.Errorf("alpha must be in (0,1]")
+	}
+	if x.Config.Threshold <= 0 {
+		x.Config.Threshold = 3
+	}
+	if x.Config.MinSamples <= 0 {
+		x.Config.MinSamples = 5
+	}
+	if x.Config.Method == AnomalyZScore && x.Config.MinSamples > x.Config.Window {
+		x.Config.MinSamples = x.Config.Window
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *AnomalyNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var data interface{}
+	if err := json.Unmarshal([]byte(msg.Data), &data); err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	value, err := num.ToFloat64(maps.Get(data, x.Config.Field))
+	if err != nil {
+		ctx.TellFailure(msg, fmt.Errorf("invalid field %s:%s", x.Config.Field, err.Error()))
+		return
+	}
+	store, err := types.ScopedState(ctx, types.StateScopeNode)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	key := "anomaly:" + str.SprintfDict(x.Config.Key, msg.Metadata.Values())
+
+	x.mu.Lock()
+	stats, err := x.load(store, key)
+	if err != nil {
+		x.mu.Unlock()
+		ctx.TellFailure(msg, err)
+		return
+	}
+	mean, stddev := x.current(stats)
+	var score float64
+	if stats.Count >= x.Config.MinSamples {
+		score = zScore(value, mean, stddev)
+	}
+	x.add(stats, value)
+	err = x.save(store, key, stats)
+	x.mu.Unlock()
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+
+	msg.Metadata.PutValue(AnomalyScoreKey, str.ToString(score))
+	msg.Metadata.PutValue(AnomalyMeanKey, str.ToString(mean))
+	msg.Metadata.PutValue(AnomalyStddevKey, str.ToString(stddev))
+	if math.Abs(score) >= x.Config.Threshold {
+		ctx.TellNext(msg, AnomalyDetected)
+	} else {
+		ctx.TellNext(msg, AnomalyNormal)
+	}
+}
+
+// Destroy 销毁
+func (x *AnomalyNode) Destroy() {
+}
+
+// load 从状态存储读取分组统计值，不存在返回空的统计值
+func (x *AnomalyNode) load(store types.StateStore, key string) (*anomalyStats, error) {
+	var stats anomalyStats
+	value, ok, err := store.Get(key)
+	if err != nil || !ok {
+		return &stats, err
+	}
+	if err := json.Unmarshal([]byte(str.ToString(value)), &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// save 统计值以JSON字符串保存，保证不同状态存储实现读取结果一致
+func (x *AnomalyNode) save(store types.StateStore, key string, stats *anomalyStats) error {
+	value, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	return store.Set(key, string(value), time.Duration(x.Config.Ttl)*time.Millisecond)
+}
+
+// current 当前均值和标准差
+func (x *AnomalyNode) current(stats *anomalyStats) (float64, float64) {
+	if x.Config.Method == AnomalyEwma {
+		return stats.Mean, math.Sqrt(stats.Variance)
+	}
+	if len(stats.Values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range stats.Values {
+		sum += v
+	}
+	mean := sum / float64(len(stats.Values))
+	var variance float64
+	for _, v := range stats.Values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(stats.Values)))
+}
+
+// add 把值加入统计
+func (x *AnomalyNode) add(stats *anomalyStats, value float64) {
+	stats.Count++
+	if x.Config.Method == AnomalyEwma {
+		if stats.Count == 1 {
+			stats.Mean = value
+			return
+		}
+		diff := value - stats.Mean
+		incr := x.Config.Alpha * diff
+		stats.Mean += incr
+		stats.Variance = (1 - x.Config.Alpha) * (stats.Variance + diff*incr)
+		return
+	}
+	stats.Values = append(stats.Values, value)
+	if len(stats.Values) > x.Config.Window {
+		stats.Values = stats.Values[1:]
+	}
+}
+
+func zScore(value, mean, stddev float64) float64 {
+	if stddev == 0 {
+		if value == mean {
+			return 0
+		} else if value > mean {
+			return math.Inf(1)
+		}
+		return math.Inf(-1)
+	}
+	return (value - mean) / stddev
+}
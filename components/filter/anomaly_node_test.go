@@ -0,0 +1,176 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+import (
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"testing"
+	"time"
+)
+
+func TestAnomalyNode(t *testing.T) {
+	var targetNodeType = "anomaly"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &AnomalyNode{}, types.Configuration{
+			"field":      "temperature",
+			"method":     AnomalyZScore,
+			"window":     30,
+			"alpha":      0.3,
+			"threshold":  float64(3),
+			"minSamples": 5,
+			"ttl":        int64(86400000),
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"key":        "${deviceId}",
+			"method":     AnomalyZScore,
+			"window":     3,
+			"minSamples": 10,
+		}, types.Configuration{
+			"key":        "${deviceId}",
+			"method":     AnomalyZScore,
+			"window":     3,
+			"minSamples": 3,
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"field": ""}, Registry)
+		assert.Equal(t, "field is empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"method": "mad"}, Registry)
+		assert.Equal(t, "unsupported method: mad", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"method": AnomalyEwma, "alpha": 1.5}, Registry)
+		assert.Equal(t, "alpha must be in (0,1]", err.Error())
+	})
+
+	var newMetadata = func(deviceId string) types.Metadata {
+		metaData := types.NewMetadata()
+		metaData.PutValue("deviceId", deviceId)
+		return metaData
+	}
+	var newMsgList = func(deviceId string, values ...interface{}) []test.Msg {
+		var msgList []test.Msg
+		for _, v := range values {
+			msgList = append(msgList, test.Msg{MetaData: newMetadata(deviceId), Data: fmt.Sprintf("{\"temperature\":%v}", v)})
+		}
+		return msgList
+	}
+
+	t.Run("ZScore", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key":        "${deviceId}",
+			"window":     4,
+			"minSamples": 4,
+		}, Registry)
+		assert.Nil(t, err)
+		var results []string
+		msgList := newMsgList("aa", 20, 21, 19, 20, 20.5, 40, 20)
+		msgList = append(msgList, newMsgList("bb", 40)...)
+		msgList = append(msgList, newMsgList("aa", "\"aa\"")...)
+		test.NodeOnMsg(t, node, msgList, func(msg types.RuleMsg, relationType string, err error) {
+			results = append(results, relationType+":"+msg.Metadata.GetValue(AnomalyMeanKey))
+		})
+		assert.Equal(t, []string{
+			//样本不足
+			"Normal:0", "Normal:20", "Normal:20.5", "Normal:20",
+			"Normal:20", "Anomaly:20.125",
+			//窗口内包含异常值，标准差变大
+			"Normal:24.875",
+			"Normal:0",
+			"Failure:",
+		}, results)
+	})
+
+	t.Run("ZeroStddev", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"minSamples": 2,
+		}, Registry)
+		assert.Nil(t, err)
+		var results []string
+		test.NodeOnMsg(t, node, newMsgList("aa", 20, 20, 20, 19), func(msg types.RuleMsg, relationType string, err error) {
+			results = append(results, relationType+":"+msg.Metadata.GetValue(AnomalyScoreKey))
+		})
+		assert.Equal(t, []string{"Normal:0", "Normal:0", "Normal:0", "Anomaly:-Inf"}, results)
+	})
+
+	t.Run("Ewma", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"method":     AnomalyEwma,
+			"alpha":      0.5,
+			"minSamples": 3,
+			"threshold":  2,
+		}, Registry)
+		assert.Nil(t, err)
+		var results []string
+		test.NodeOnMsg(t, node, newMsgList("aa", 10, 12, 10, 11, 30), func(msg types.RuleMsg, relationType string, err error) {
+			results = append(results, relationType+":"+msg.Metadata.GetValue(AnomalyMeanKey))
+		})
+		assert.Equal(t, []string{"Normal:0", "Normal:10", "Normal:11", "Normal:10.5", "Anomaly:10.75"}, results)
+	})
+
+	t.Run("StateStore", func(t *testing.T) {
+		configuration := types.Configuration{
+			"key":        "${deviceId}",
+			"minSamples": 2,
+		}
+		config := types.NewConfig()
+		var results []string
+		ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string, err error) {
+			results = append(results, relationType+":"+msg.Metadata.GetValue(AnomalyMeanKey))
+		})
+		node, err := test.CreateAndInitNode(targetNodeType, configuration, Registry)
+		assert.Nil(t, err)
+		for _, item := range newMsgList("aa", 20, 20) {
+			node.OnMsg(ctx, ctx.NewMsg("TEST", item.MetaData, item.Data))
+		}
+		node.Destroy()
+
+		//新的节点实例使用状态存储中的统计值继续检测
+		node, err = test.CreateAndInitNode(targetNodeType, configuration, Registry)
+		assert.Nil(t, err)
+		for _, item := range newMsgList("aa", 30) {
+			node.OnMsg(ctx, ctx.NewMsg("TEST", item.MetaData, item.Data))
+		}
+		assert.Equal(t, []string{AnomalyNormal + ":0", AnomalyNormal + ":20", AnomalyDetected + ":20"}, results)
+		store, err := types.ScopedState(ctx, types.StateScopeNode)
+		assert.Nil(t, err)
+		_, ok, _ := store.Get("anomaly:aa")
+		assert.True(t, ok)
+	})
+
+	t.Run("Ttl", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"minSamples": 1,
+			"ttl":        50,
+		}, Registry)
+		assert.Nil(t, err)
+		var results []string
+		msgList := newMsgList("aa", 20, 30)
+		msgList[0].AfterSleep = time.Millisecond * 100
+		test.NodeOnMsg(t, node, msgList, func(msg types.RuleMsg, relationType string, err error) {
+			results = append(results, relationType+":"+msg.Metadata.GetValue(AnomalyMeanKey))
+		})
+		//统计值过期，重新统计
+		assert.Equal(t, []string{AnomalyNormal + ":0", AnomalyNormal + ":0"}, results)
+	})
+}
@@ -27,11 +27,12 @@ package filter
 //        }
 //      }
 import (
-	"github.com/expr-lang/expr"
 	"github.com/expr-lang/expr/vm"
 	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/expr"
 	"github.com/rulego/rulego/utils/json"
 	"github.com/rulego/rulego/utils/maps"
+	"strings"
 )
 
 func init() {
@@ -51,9 +52,12 @@ type ExprFilterNodeConfiguration struct {
 // 通过`metadata`变量访问消息元数据。例如 `metadata.customerName`
 // 通过`msgType`变量访问消息类型
 // 通过`dataType`变量访问数据类型
+// 可以使用内置函数库`expr.Builtins`，例如：`regexMatch('^t\\d+', msg.name)`
+// 也可以调用`Config.Udf`注册的go函数，例如：`add(msg.a, msg.b) > 10`
 type ExprFilterNode struct {
 	//节点配置
 	Config  ExprFilterNodeConfiguration
+	udf     map[string]interface{}
 	program *vm.Program
 }
 
@@ -71,8 +75,9 @@ func (x *ExprFilterNode) New() types.Node {
 func (x *ExprFilterNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
 	err := maps.Map2Struct(configuration, &x.Config)
 	if err == nil {
-		if program, err := expr.Compile(x.Config.Expr, expr.AllowUndefinedVariables(), expr.AsBool()); err == nil {
-			x.program = program
+		x.udf = expr.UdfEnv(ruleConfig)
+		if exprV := strings.TrimSpace(x.Config.Expr); exprV != "" {
+			x.program, err = expr.CompileAsBool(ruleConfig, exprV)
 		}
 	}
 	return err
@@ -88,13 +93,11 @@ func (x *ExprFilterNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 			data = dataMap
 		}
 	}
-	var evn = make(map[string]interface{})
-	evn[types.MsgKey] = data
-	evn[types.MetadataKey] = msg.Metadata
-	evn[types.MsgTypeKey] = msg.Type
-	evn[types.DataTypeKey] = msg.DataType
-
-	if out, err := vm.Run(x.program, evn); err != nil {
+	if x.program == nil {
+		ctx.TellNext(msg, types.False)
+		return
+	}
+	if out, err := expr.Run(x.program, expr.NewEnvWithContext(ctx, x.udf, msg, data)); err != nil {
 		ctx.TellFailure(msg, err)
 	} else {
 		if result, ok := out.(bool); ok && result {
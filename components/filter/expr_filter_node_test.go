@@ -157,4 +157,30 @@ func TestExprFilterNode(t *testing.T) {
 		}
 		time.Sleep(time.Millisecond * 20)
 	})
+
+	t.Run("OnMsgWithUdf", func(t *testing.T) {
+		config := types.NewConfig()
+		config.RegisterUdf("add", func(a, b float64) float64 {
+			return a + b
+		})
+		node := &ExprFilterNode{}
+		err := node.Init(config, types.Configuration{
+			"expr": "add(msg.temperature, msg.humidity) > 80 && regexMatch('^a+$', msg.name)",
+		})
+		assert.Nil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"expr": "msg.temperature >",
+		}, Registry)
+		assert.NotNil(t, err)
+
+		msgList := []test.Msg{{
+			MetaData:   types.NewMetadata(),
+			MsgType:    "ACTIVITY_EVENT",
+			Data:       "{\"name\":\"aa\",\"temperature\":60,\"humidity\":30}",
+			AfterSleep: time.Millisecond * 200,
+		}}
+		test.NodeOnMsg(t, node, msgList, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.True, relationType)
+		})
+	})
 }
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+//规则链节点配置示例：
+//{
+//        "id": "s2",
+//        "type": "exprSwitch",
+//        "name": "表达式路由",
+//        "debugMode": false,
+//        "configuration": {
+//          "cases": [
+//            {"expr": "msg.temperature > 50", "then": "high"},
+//            {"expr": "msg.temperature < 10", "then": "low"}
+//          ],
+//          "allMatches": false,
+//          "defaultRelation": "Default"
+//        }
+//      }
+import (
+	"fmt"
+	"github.com/expr-lang/expr/vm"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/expr"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"strings"
+)
+
+// ExprSwitchDefaultRelation exprSwitch 所有表达式都不匹配时使用的默认关系
+const ExprSwitchDefaultRelation = "Default"
+
+func init() {
+	Registry.Add(&ExprSwitchNode{})
+}
+
+// ExprSwitchCase 路由分支
+type ExprSwitchCase struct {
+	//Expr 表达式，返回值必须是bool类型
+	Expr string
+	//Then 表达式返回true时，消息路由的关系名称
+	Then string
+}
+
+// ExprSwitchNodeConfiguration 节点配置
+type ExprSwitchNodeConfiguration struct {
+	//Cases 路由分支列表，按顺序匹配
+	Cases []ExprSwitchCase
+	//AllMatches 是否路由到所有匹配的分支，如果为false，则只路由到第一个匹配的分支
+	AllMatches bool
+	//DefaultRelation 所有分支都不匹配时，消息路由的关系名称，默认：Default
+	DefaultRelation string
+}
+
+// ExprSwitchNode 使用expr表达式路由消息，不需要创建js vm
+// 按顺序执行分支表达式，把消息路由到表达式返回true的分支`Then`关系
+// AllMatches=false，只路由到第一个匹配的分支，否则路由到所有匹配的分支
+// 如果所有分支都不匹配，则路由到`DefaultRelation`关系；如果表达式执行失败则发送到`Failure`链
+// 表达式可以访问的变量和函数与`exprFilter`一致
+type ExprSwitchNode struct {
+	//节点配置
+	Config   ExprSwitchNodeConfiguration
+	udf      map[string]interface{}
+	programs []*vm.Program
+}
+
+// Type 组件类型
+func (x *ExprSwitchNode) Type() string {
+	return "exprSwitch"
+}
+
+func (x *ExprSwitchNode) New() types.Node {
+	return &ExprSwitchNode{Config: ExprSwitchNodeConfiguration{
+		DefaultRelation: ExprSwitchDefaultRelation,
+	}}
+}
+
+// Init 初始化
+func (x *ExprSwitchNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.Config)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(x.Config.DefaultRelation) == "" {
+		x.Config.DefaultRelation = ExprSwitchDefaultRelation
+	}
+	x.udf = expr.UdfEnv(ruleConfig)
+	x.programs = nil
+	for i, item := range x.Config.Cases {
+		exprV := strings.TrimSpace(item.Expr)
+		if exprV == "" || strings.TrimSpace(item.Then) == "" {
+			return fmt.Errorf("cases[%d] expr or then is empty", i)
+		}
+		program, err := expr.CompileAsBool(ruleConfig, exprV)
+		if err != nil {
+			return fmt.Errorf("cases[%d] compile error:%s", i, err.Error())
+		}
+		x.programs = append(x.programs, program)
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *ExprSwitchNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var data interface{} = msg.Data
+	if msg.DataType == types.JSON {
+		var dataMap interface{}
+		if err := json.Unmarshal([]byte(msg.Data), &dataMap); err == nil {
+			data = dataMap
+		}
+	}
+	env := expr.NewEnvWithContext(ctx, x.udf, msg, data)
+	var relationTypes []string
+	for i, program := range x.programs {
+		out, err := expr.Run(program, env)
+		if err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+		if result, ok := out.(bool); ok && result {
+			relationTypes = append(relationTypes, x.Config.Cases[i].Then)
+			if !x.Config.AllMatches {
+				break
+			}
+		}
+	}
+	if len(relationTypes) == 0 {
+		relationTypes = append(relationTypes, x.Config.DefaultRelation)
+	}
+	ctx.TellNext(msg, relationTypes...)
+}
+
+// Destroy 销毁
+func (x *ExprSwitchNode) Destroy() {
+}
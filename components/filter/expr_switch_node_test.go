@@ -0,0 +1,125 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"strings"
+	"testing"
+)
+
+func TestExprSwitchNode(t *testing.T) {
+	var targetNodeType = "exprSwitch"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &ExprSwitchNode{}, types.Configuration{
+			"allMatches":      false,
+			"defaultRelation": ExprSwitchDefaultRelation,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"cases": []interface{}{
+				map[string]interface{}{"expr": "msg.temperature > 50", "then": "high"},
+			},
+			"allMatches": true,
+		}, types.Configuration{
+			"allMatches":      true,
+			"defaultRelation": ExprSwitchDefaultRelation,
+		}, Registry)
+
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"cases": []interface{}{
+				map[string]interface{}{"expr": "msg.temperature >", "then": "high"},
+			},
+		}, Registry)
+		assert.NotNil(t, err)
+
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"cases": []interface{}{
+				map[string]interface{}{"expr": "msg.temperature > 50"},
+			},
+		}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("DefaultConfig", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{}, types.Configuration{
+			"allMatches":      false,
+			"defaultRelation": ExprSwitchDefaultRelation,
+		}, Registry)
+	})
+
+	t.Run("OnMsg", func(t *testing.T) {
+		cases := []interface{}{
+			map[string]interface{}{"expr": "msg.temperature > 50", "then": "high"},
+			map[string]interface{}{"expr": "msg.temperature > 30", "then": "warm"},
+			map[string]interface{}{"expr": "metadata.productType == 'test'", "then": "test"},
+		}
+		firstMatchNode, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"cases": cases,
+		}, Registry)
+		assert.Nil(t, err)
+		allMatchesNode, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"cases":           cases,
+			"allMatches":      true,
+			"defaultRelation": "other",
+		}, Registry)
+		assert.Nil(t, err)
+		errNode, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"cases": []interface{}{
+				map[string]interface{}{"expr": "int(msg.name) > 1", "then": "high"},
+			},
+		}, Registry)
+		assert.Nil(t, err)
+
+		metaData := types.BuildMetadata(make(map[string]string))
+		metaData.PutValue("productType", "test")
+		hotMsg := test.Msg{
+			MetaData: metaData,
+			MsgType:  "ACTIVITY_EVENT",
+			Data:     "{\"name\":\"aa\",\"temperature\":60}",
+		}
+		coldMsg := test.Msg{
+			MetaData: types.NewMetadata(),
+			MsgType:  "ACTIVITY_EVENT",
+			Data:     "{\"temperature\":10}",
+		}
+
+		var testcases = []struct {
+			node     types.Node
+			msg      test.Msg
+			expected string
+		}{
+			{node: firstMatchNode, msg: hotMsg, expected: "high"},
+			{node: firstMatchNode, msg: coldMsg, expected: ExprSwitchDefaultRelation},
+			{node: allMatchesNode, msg: hotMsg, expected: "high,warm,test"},
+			{node: allMatchesNode, msg: coldMsg, expected: "other"},
+			{node: errNode, msg: hotMsg, expected: types.Failure},
+		}
+		for _, item := range testcases {
+			var relationTypes []string
+			test.NodeOnMsg(t, item.node, []test.Msg{item.msg}, func(msg types.RuleMsg, relationType string, err error) {
+				relationTypes = append(relationTypes, relationType)
+			})
+			assert.Equal(t, item.expected, strings.Join(relationTypes, ","))
+		}
+	})
+}
@@ -0,0 +1,469 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+//规则链节点配置示例：坐标使用GeoJSON顺序[经度,纬度]
+//{
+//        "id": "s1",
+//        "type": "geofence",
+//        "name": "电子围栏",
+//        "debugMode": false,
+//        "configuration": {
+//          "key": "${deviceId}",
+//          "latField": "latitude",
+//          "lonField": "longitude",
+//          "zones": [
+//            {"id": "park", "type": "polygon", "points": [[113.1,23.1],[113.2,23.1],[113.2,23.2],[113.1,23.2]]},
+//            {"id": "office", "type": "circle", "center": [113.3,23.1], "radius": 500}
+//          ],
+//          "geoJsonFile": "./zones.geojson"
+//        }
+//      }
+import (
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/num"
+	"github.com/rulego/rulego/utils/str"
+	"math"
+	"os"
+	"strings"
+	"sync"
+)
+
+// 电子围栏节点关系类型
+const (
+	//GeofenceInside 在任意区域内
+	GeofenceInside = "Inside"
+	//GeofenceOutside 不在任何区域内
+	GeofenceOutside = "Outside"
+	//GeofenceEntered 进入了新的区域
+	GeofenceEntered = "Entered"
+	//GeofenceExited 离开了区域
+	GeofenceExited = "Exited"
+)
+
+// 区域类型
+const (
+	GeoZonePolygon = "polygon"
+	GeoZoneCircle  = "circle"
+)
+
+// 电子围栏节点输出的metadata，多个区域ID使用`,`隔开
+const (
+	//GeofenceZoneIdsKey 当前所在的区域ID
+	GeofenceZoneIdsKey = "zoneIds"
+	//GeofenceEnteredZoneIdsKey 进入的区域ID
+	GeofenceEnteredZoneIdsKey = "enteredZoneIds"
+	//GeofenceExitedZoneIdsKey 离开的区域ID
+	GeofenceExitedZoneIdsKey = "exitedZoneIds"
+)
+
+// earthRadius 地球平均半径，单位米
+const earthRadius = 6371008.8
+
+func init() {
+	Registry.Add(&GeofenceNode{})
+}
+
+// GeoZone 区域配置，坐标使用[经度,纬度]
+type GeoZone struct {
+	//Id 区域ID
+	Id string
+	//Type 区域类型：polygon、circle
+	Type string
+	//Points 多边形顶点，polygon类型有效
+	Points [][]float64
+	//Center 圆心，circle类型有效
+	Center []float64
+	//Radius 半径，单位米，circle类型有效
+	Radius float64
+}
+
+// GeofenceNodeConfiguration 节点配置
+type GeofenceNodeConfiguration struct {
+	//Key 设备标识，用于跟踪进入、离开区域，可以使用${metadataKey}方式从metadata获取
+	Key string
+	//LatField 纬度字段，支持嵌套字段，例如：latitude或者gps.lat
+	LatField string
+	//LonField 经度字段，支持嵌套字段，例如：longitude或者gps.lon
+	LonField string
+	//Zones 区域列表
+	Zones []GeoZone
+	//GeoJsonFile 从GeoJSON文件加载区域，支持FeatureCollection、Feature，几何类型支持Polygon、MultiPolygon，
+	//以及包含properties.radius的Point(圆形区域)，区域ID使用feature.id或者properties.id
+	GeoJsonFile string
+}
+
+// GeofenceNode 电子围栏节点，判断消息中的坐标是否在配置的区域内
+// 在任意区域内通过`Inside`链发送，否则通过`Outside`链发送；和该设备上一次的位置比较，进入新的区域同时通过`Entered`链发送，
+// 离开区域同时通过`Exited`链发送。区域ID写入metadata：zoneIds、enteredZoneIds、exitedZoneIds
+// 坐标不是数字则发送到`Failure`链。设备所在区域保存在`types.Config.StateStore`节点作用域
+type GeofenceNode struct {
+	//节点配置
+	Config GeofenceNodeConfiguration
+	zones  []geoZone
+	//保证同一个设备读取和修改状态的原子性
+	mu sync.Mutex
+}
+
+// geoPoint [经度,纬度]
+type geoPoint [2]float64
+
+// geoZone 解析后的区域
+type geoZone struct {
+	id string
+	//polygons 多边形列表，每个多边形第一个环是外边界，其余是洞
+	polygons [][][]geoPoint
+	center   geoPoint
+	radius   float64
+}
+
+// Type 组件类型
+func (x *GeofenceNode) Type() string {
+	return "geofence"
+}
+
+func (x *GeofenceNode) New() types.Node {
+	return &GeofenceNode{Config: GeofenceNodeConfiguration{
+		Key:      "${deviceId}",
+		LatField: "latitude",
+		LonField: "longitude",
+	}}
+}
+
+// Init 初始化
+func (x *GeofenceNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.Config); err != nil {
+		return err
+	}
+	if x.Config.LatField == "" || x.Config.LonField == "" {
+		return fmt.Errorf("latField or lonField is empty")
+	}
+	x.zones = nil
+	for i, item := range x.Config.Zones {
+		zone, err := parseGeoZone(item)
+		if err != nil {
+			return fmt.Errorf("zones[%d] %w", i, err)
+		}
+		x.zones = append(x.zones, zone)
+	}
+	if x.Config.GeoJsonFile != "" {
+		zones, err := loadGeoJsonZones(x.Config.GeoJsonFile)
+		if err != nil {
+			return err
+		}
+		x.zones = append(x.zones, zones...)
+	}
+	if len(x.zones) == 0 {
+		return fmt.Errorf("zones is empty")
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *GeofenceNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var data interface{}
+	if err := json.Unmarshal([]byte(msg.Data), &data); err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	lat, err := num.ToFloat64(maps.Get(data, x.Config.LatField))
+	if err != nil {
+		ctx.TellFailure(msg, fmt.Errorf("invalid field %s:%s", x.Config.LatField, err.Error()))
+		return
+	}
+	lon, err := num.ToFloat64(maps.Get(data, x.Config.LonField))
+	if err != nil {
+		ctx.TellFailure(msg, fmt.Errorf("invalid field %s:%s", x.Config.LonField, err.Error()))
+		return
+	}
+	store, err := types.ScopedState(ctx, types.StateScopeNode)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	point := geoPoint{lon, lat}
+	var current []string
+	for _, zone := range x.zones {
+		if zone.contains(point) {
+			current = append(current, zone.id)
+		}
+	}
+	key := "geofence:" + str.SprintfDict(x.Config.Key, msg.Metadata.Values())
+
+	x.mu.Lock()
+	previous, err := x.swapZoneIds(store, key, current)
+	x.mu.Unlock()
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	entered := difference(current, previous)
+	exited := difference(previous, current)
+
+	msg.Metadata.PutValue(GeofenceZoneIdsKey, strings.Join(current, ","))
+	msg.Metadata.PutValue(GeofenceEnteredZoneIdsKey, strings.Join(entered, ","))
+	msg.Metadata.PutValue(GeofenceExitedZoneIdsKey, strings.Join(exited, ","))
+	relationTypes := []string{GeofenceOutside}
+	if len(current) > 0 {
+		relationTypes[0] = GeofenceInside
+	}
+	if len(entered) > 0 {
+		relationTypes = append(relationTypes, GeofenceEntered)
+	}
+	if len(exited) > 0 {
+		relationTypes = append(relationTypes, GeofenceExited)
+	}
+	ctx.TellNext(msg, relationTypes...)
+}
+
+// Destroy 销毁
+func (x *GeofenceNode) Destroy() {
+}
+
+// swapZoneIds 保存设备当前所在的区域ID，返回上一次所在的区域ID
+// 区域ID以JSON数组字符串保存，区域ID可以包含任意字符
+func (x *GeofenceNode) swapZoneIds(store types.StateStore, key string, current []string) ([]string, error) {
+	var previous []string
+	last, ok, err := store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if err := json.Unmarshal([]byte(str.ToString(last)), &previous); err != nil {
+			return nil, err
+		}
+	}
+	if current == nil {
+		current = []string{}
+	}
+	value, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	return previous, store.Set(key, string(value), 0)
+}
+
+// difference 在a中但不在b中的元素
+func difference(a, b []string) []string {
+	var result []string
+	for _, item := range a {
+		found := false
+		for _, v := range b {
+			if v == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// contains 点是否在区域内，多边形边界上的点可能判断为内部或者外部
+func (z geoZone) contains(p geoPoint) bool {
+	if z.radius > 0 {
+		return haversine(z.center, p) <= z.radius
+	}
+	for _, polygon := range z.polygons {
+		if !inRing(polygon[0], p) {
+			continue
+		}
+		inHole := false
+		for _, hole := range polygon[1:] {
+			if inRing(hole, p) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// inRing 射线法判断点是否在环内，按平面坐标计算，不适用于跨越180度经线的区域
+func inRing(ring []geoPoint, p geoPoint) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > p[1]) != (b[1] > p[1]) && p[0] < (b[0]-a[0])*(p[1]-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// haversine 两点之间的球面距离，单位米
+func haversine(a, b geoPoint) float64 {
+	lat1, lat2 := a[1]*math.Pi/180, b[1]*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b[0] - a[0]) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// parseGeoZone 解析区域配置
+func parseGeoZone(item GeoZone) (geoZone, error) {
+	zone := geoZone{id: item.Id}
+	if item.Id == "" {
+		return zone, fmt.Errorf("id is empty")
+	}
+	switch item.Type {
+	case GeoZonePolygon:
+		ring, err := toRing(item.Points)
+		if err != nil {
+			return zone, err
+		}
+		zone.polygons = [][][]geoPoint{{ring}}
+	case GeoZoneCircle:
+		center, err := toPoint(item.Center)
+		if err != nil {
+			return zone, err
+		}
+		if item.Radius <= 0 {
+			return zone, fmt.Errorf("radius must be greater than 0")
+		}
+		zone.center = center
+		zone.radius = item.Radius
+	default:
+		return zone, fmt.Errorf("unsupported zone type: %s", item.Type)
+	}
+	return zone, nil
+}
+
+func toPoint(values []float64) (geoPoint, error) {
+	if len(values) < 2 {
+		return geoPoint{}, fmt.Errorf("point must be [longitude,latitude]")
+	}
+	return geoPoint{values[0], values[1]}, nil
+}
+
+func toRing(points [][]float64) ([]geoPoint, error) {
+	var ring []geoPoint
+	for _, item := range points {
+		p, err := toPoint(item)
+		if err != nil {
+			return nil, err
+		}
+		ring = append(ring, p)
+	}
+	if len(ring) < 3 {
+		return nil, fmt.Errorf("polygon requires at least 3 points")
+	}
+	return ring, nil
+}
+
+// geoJsonFeature GeoJSON Feature
+type geoJsonFeature struct {
+	Type       string                 `json:"type"`
+	Id         interface{}            `json:"id"`
+	Properties map[string]interface{} `json:"properties"`
+	Geometry   struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+	Features []geoJsonFeature `json:"features"`
+}
+
+// loadGeoJsonZones 从GeoJSON文件加载区域
+func loadGeoJsonZones(path string) ([]geoZone, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var root geoJsonFeature
+	if err := json.Unmarshal(content, &root); err != nil {
+		return nil, err
+	}
+	features := []geoJsonFeature{root}
+	if root.Type == "FeatureCollection" {
+		features = root.Features
+	}
+	var zones []geoZone
+	for i, feature := range features {
+		zone, err := parseGeoJsonFeature(feature)
+		if err != nil {
+			return nil, fmt.Errorf("geojson features[%d] %w", i, err)
+		}
+		zones = append(zones, zone)
+	}
+	return zones, nil
+}
+
+// parseGeoJsonFeature 解析GeoJSON Feature
+func parseGeoJsonFeature(feature geoJsonFeature) (geoZone, error) {
+	zone := geoZone{id: str.ToString(feature.Id)}
+	if zone.id == "" {
+		zone.id = str.ToString(feature.Properties["id"])
+	}
+	if zone.id == "" {
+		return zone, fmt.Errorf("id is empty")
+	}
+	var err error
+	switch feature.Geometry.Type {
+	case "Polygon":
+		var polygon [][][]float64
+		if err = json.Unmarshal(feature.Geometry.Coordinates, &polygon); err == nil {
+			err = zone.addPolygon(polygon)
+		}
+	case "MultiPolygon":
+		var polygons [][][][]float64
+		if err = json.Unmarshal(feature.Geometry.Coordinates, &polygons); err == nil {
+			for _, polygon := range polygons {
+				if err = zone.addPolygon(polygon); err != nil {
+					break
+				}
+			}
+		}
+	case "Point":
+		var center []float64
+		if err = json.Unmarshal(feature.Geometry.Coordinates, &center); err == nil {
+			zone.center, err = toPoint(center)
+		}
+		if err == nil {
+			zone.radius, _ = num.ToFloat64(feature.Properties["radius"])
+			if zone.radius <= 0 {
+				err = fmt.Errorf("point requires properties.radius")
+			}
+		}
+	default:
+		err = fmt.Errorf("unsupported geometry type: %s", feature.Geometry.Type)
+	}
+	return zone, err
+}
+
+func (z *geoZone) addPolygon(polygon [][][]float64) error {
+	var rings [][]geoPoint
+	for _, item := range polygon {
+		ring, err := toRing(item)
+		if err != nil {
+			return err
+		}
+		rings = append(rings, ring)
+	}
+	if len(rings) == 0 {
+		return fmt.Errorf("polygon is empty")
+	}
+	z.polygons = append(z.polygons, rings)
+	return nil
+}
@@ -0,0 +1,189 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGeofenceNode(t *testing.T) {
+	var targetNodeType = "geofence"
+
+	var zones = []interface{}{
+		map[string]interface{}{"id": "park", "type": "polygon", "points": []interface{}{
+			[]interface{}{113.1, 23.1}, []interface{}{113.2, 23.1}, []interface{}{113.2, 23.2}, []interface{}{113.1, 23.2},
+		}},
+		map[string]interface{}{"id": "office", "type": "circle", "center": []interface{}{113.2, 23.2}, "radius": 1000},
+	}
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &GeofenceNode{}, types.Configuration{
+			"key":      "${deviceId}",
+			"latField": "latitude",
+			"lonField": "longitude",
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"latField": "gps.lat",
+			"lonField": "gps.lon",
+			"zones":    zones,
+		}, types.Configuration{
+			"key":      "${deviceId}",
+			"latField": "gps.lat",
+			"lonField": "gps.lon",
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		var testcases = []struct {
+			configuration types.Configuration
+			err           string
+		}{
+			{configuration: types.Configuration{}, err: "zones is empty"},
+			{configuration: types.Configuration{"latField": "", "zones": zones}, err: "latField or lonField is empty"},
+			{configuration: types.Configuration{"zones": []interface{}{
+				map[string]interface{}{"type": "circle", "center": []interface{}{113.2, 23.2}, "radius": 10},
+			}}, err: "zones[0] id is empty"},
+			{configuration: types.Configuration{"zones": []interface{}{
+				map[string]interface{}{"id": "a", "type": "circle", "center": []interface{}{113.2, 23.2}},
+			}}, err: "zones[0] radius must be greater than 0"},
+			{configuration: types.Configuration{"zones": []interface{}{
+				map[string]interface{}{"id": "a", "type": "polygon", "points": []interface{}{[]interface{}{113.1, 23.1}}},
+			}}, err: "zones[0] polygon requires at least 3 points"},
+			{configuration: types.Configuration{"zones": []interface{}{
+				map[string]interface{}{"id": "a", "type": "line"},
+			}}, err: "zones[0] unsupported zone type: line"},
+		}
+		for _, item := range testcases {
+			_, err := test.CreateAndInitNode(targetNodeType, item.configuration, Registry)
+			assert.NotNil(t, err)
+			assert.Equal(t, item.err, err.Error())
+		}
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"geoJsonFile": "./not_found.geojson"}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	var newMetadata = func(deviceId string) types.Metadata {
+		metaData := types.NewMetadata()
+		metaData.PutValue("deviceId", deviceId)
+		return metaData
+	}
+
+	t.Run("OnMsg", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"zones": zones,
+		}, Registry)
+		assert.Nil(t, err)
+		var results []string
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: newMetadata("aa"), Data: "{\"latitude\":23.0,\"longitude\":113.15}"},
+			{MetaData: newMetadata("aa"), Data: "{\"latitude\":23.15,\"longitude\":113.15}"},
+			{MetaData: newMetadata("bb"), Data: "{\"latitude\":23.15,\"longitude\":113.15}"},
+			//两个区域重叠的位置
+			{MetaData: newMetadata("aa"), Data: "{\"latitude\":23.199,\"longitude\":113.199}"},
+			//只在圆形区域内
+			{MetaData: newMetadata("aa"), Data: "{\"latitude\":23.201,\"longitude\":113.201}"},
+			{MetaData: newMetadata("aa"), Data: "{\"latitude\":24,\"longitude\":113.201}"},
+			{MetaData: newMetadata("aa"), Data: "{\"latitude\":\"aa\",\"longitude\":113.201}"},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			results = append(results, relationType+":"+msg.Metadata.GetValue(GeofenceZoneIdsKey)+":"+
+				msg.Metadata.GetValue(GeofenceEnteredZoneIdsKey)+":"+msg.Metadata.GetValue(GeofenceExitedZoneIdsKey))
+		})
+		assert.Equal(t, strings.Join([]string{
+			"Outside:::",
+			"Inside:park:park:", "Entered:park:park:",
+			"Inside:park:park:", "Entered:park:park:",
+			"Inside:park,office:office:", "Entered:park,office:office:",
+			"Inside:office::park", "Exited:office::park",
+			"Outside:::office", "Exited:::office",
+			"Failure:::",
+		}, "\n"), strings.Join(results, "\n"))
+	})
+
+	t.Run("GeoJsonFile", func(t *testing.T) {
+		geoJson := `{
+  "type": "FeatureCollection",
+  "features": [
+    {"type": "Feature", "id": "lake", "geometry": {"type": "Polygon", "coordinates": [
+      [[113.0, 23.0], [114.0, 23.0], [114.0, 24.0], [113.0, 24.0], [113.0, 23.0]],
+      [[113.4, 23.4], [113.6, 23.4], [113.6, 23.6], [113.4, 23.6], [113.4, 23.4]]
+    ]}},
+    {"type": "Feature", "properties": {"id": "islands"}, "geometry": {"type": "MultiPolygon", "coordinates": [
+      [[[113.45, 23.45], [113.55, 23.45], [113.55, 23.55], [113.45, 23.55]]],
+      [[[120.0, 30.0], [121.0, 30.0], [121.0, 31.0]]]
+    ]}},
+    {"type": "Feature", "properties": {"id": "port", "radius": 2000}, "geometry": {"type": "Point", "coordinates": [120.0, 22.0]}}
+  ]
+}`
+		path := filepath.Join(t.TempDir(), "zones.geojson")
+		assert.Nil(t, os.WriteFile(path, []byte(geoJson), 0644))
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"latField":    "gps.lat",
+			"lonField":    "gps.lon",
+			"geoJsonFile": path,
+		}, Registry)
+		assert.Nil(t, err)
+		var results []string
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: newMetadata("aa"), Data: "{\"gps\":{\"lat\":23.2,\"lon\":113.2}}"},
+			//在洞内
+			{MetaData: newMetadata("bb"), Data: "{\"gps\":{\"lat\":23.42,\"lon\":113.42}}"},
+			//在洞内的岛上
+			{MetaData: newMetadata("cc"), Data: "{\"gps\":{\"lat\":23.5,\"lon\":113.5}}"},
+			{MetaData: newMetadata("dd"), Data: "{\"gps\":{\"lat\":22.01,\"lon\":120.0}}"},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			if relationType == GeofenceInside || relationType == GeofenceOutside {
+				results = append(results, relationType+":"+msg.Metadata.GetValue(GeofenceZoneIdsKey))
+			}
+		})
+		assert.Equal(t, []string{"Inside:lake", "Outside:", "Inside:islands", "Inside:port"}, results)
+
+		assert.Nil(t, os.WriteFile(path, []byte(`{"type": "Feature", "id": "a", "geometry": {"type": "LineString", "coordinates": []}}`), 0644))
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"geoJsonFile": path}, Registry)
+		assert.Equal(t, "geojson features[0] unsupported geometry type: LineString", err.Error())
+	})
+
+	t.Run("ZoneIdWithComma", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"zones": []map[string]interface{}{
+				{"id": "a,b", "type": GeoZoneCircle, "center": []float64{113.3, 23.1}, "radius": 500},
+			},
+		}, Registry)
+		assert.Nil(t, err)
+		var results []string
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: newMetadata("aa"), Data: "{\"latitude\":23.1,\"longitude\":113.3}"},
+			{MetaData: newMetadata("aa"), Data: "{\"latitude\":23.1,\"longitude\":113.3}"},
+			{MetaData: newMetadata("aa"), Data: "{\"latitude\":24,\"longitude\":113.3}"},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			results = append(results, relationType+":"+msg.Metadata.GetValue(GeofenceEnteredZoneIdsKey)+":"+msg.Metadata.GetValue(GeofenceExitedZoneIdsKey))
+		})
+		assert.Equal(t, []string{
+			"Inside:a,b:", "Entered:a,b:",
+			"Inside::",
+			"Outside::a,b", "Exited::a,b",
+		}, results)
+	})
+}
@@ -27,7 +27,6 @@ package filter
 //        }
 //      }
 import (
-	"fmt"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/components/js"
 	"github.com/rulego/rulego/utils/json"
@@ -45,7 +44,8 @@ type JsFilterNodeConfiguration struct {
 	//完整脚本函数：
 	//function Filter(msg, metadata, msgType) { ${JsScript} }
 	//return bool
-	JsScript string
+	//可以使用内置函数库，参考：`js.Builtins`
+	JsScript string `desc:"过滤函数体：function Filter(msg, metadata, msgType) {...}，返回bool。内置函数库：$encoding、$crypto、$uuid、$time"`
 }
 
 // JsFilterNode 使用js脚本过滤传入信息
@@ -58,6 +58,8 @@ type JsFilterNode struct {
 	//节点配置
 	Config   JsFilterNodeConfiguration
 	jsEngine types.JsEngine
+	//脚本是否使用`$ctx`宿主对象
+	withContext bool
 }
 
 // Type 组件类型
@@ -75,7 +77,8 @@ func (x *JsFilterNode) New() types.Node {
 func (x *JsFilterNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
 	err := maps.Map2Struct(configuration, &x.Config)
 	if err == nil {
-		jsScript := fmt.Sprintf("function Filter(msg, metadata, msgType) { %s }", x.Config.JsScript)
+		var jsScript string
+		jsScript, x.withContext = js.WrapFunction("Filter", "msg, metadata, msgType", x.Config.JsScript)
 		x.jsEngine, err = js.NewGojaJsEngine(ruleConfig, jsScript, nil)
 	}
 	return err
@@ -91,7 +94,7 @@ func (x *JsFilterNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 		}
 	}
 
-	out, err := x.jsEngine.Execute("Filter", data, msg.Metadata.Values(), msg.Type)
+	out, err := js.Execute(x.jsEngine, x.withContext, ctx, msg, "Filter", data, msg.Metadata.Values(), msg.Type)
 	if err != nil {
 		ctx.TellFailure(msg, err)
 	} else {
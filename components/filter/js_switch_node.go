@@ -28,7 +28,6 @@ package filter
 //      }
 import (
 	"errors"
-	"fmt"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/components/js"
 	"github.com/rulego/rulego/utils/json"
@@ -45,7 +44,11 @@ func init() {
 
 // JsSwitchNodeConfiguration 节点配置
 type JsSwitchNodeConfiguration struct {
-	JsScript string
+	//JsScript 配置函数体脚本内容，返回路由的关系名称数组
+	//完整脚本函数：
+	//function Switch(msg, metadata, msgType) { ${JsScript} }
+	//可以使用内置函数库，参考：`js.Builtins`
+	JsScript string `desc:"路由函数体：function Switch(msg, metadata, msgType) {...}，返回关系名称数组。内置函数库：$encoding、$crypto、$uuid、$time"`
 }
 
 // JsSwitchNode 节点执行已配置的JS脚本。脚本应返回消息应路由到的下一个链名称的数组。
@@ -53,10 +56,13 @@ type JsSwitchNodeConfiguration struct {
 // 消息体可以通过`msg`变量访问，如果消息的dataType是json类型，可以通过 `msg.XX`方式访问msg的字段。例如:`msg.temperature > 50;`
 // 消息元数据可以通过`metadata`变量访问。例如 `metadata.customerName === 'Lala';`
 // 消息类型可以通过`msgType`变量访问.
+// 脚本可以通过`$ctx.tellNext(msg, relationType)`发送新消息，通过`await $ctx.call(functionName, msg)`调用`action.Functions`注册的函数
 type JsSwitchNode struct {
 	//节点配置
 	Config   JsSwitchNodeConfiguration
 	jsEngine types.JsEngine
+	//withContext 脚本是否使用`$ctx`
+	withContext bool
 }
 
 // Type 组件类型
@@ -73,7 +79,8 @@ func (x *JsSwitchNode) New() types.Node {
 func (x *JsSwitchNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
 	err := maps.Map2Struct(configuration, &x.Config)
 	if err == nil {
-		jsScript := fmt.Sprintf("function Switch(msg, metadata, msgType) { %s }", x.Config.JsScript)
+		var jsScript string
+		jsScript, x.withContext = js.WrapFunction("Switch", "msg, metadata, msgType", x.Config.JsScript)
 		x.jsEngine, err = js.NewGojaJsEngine(ruleConfig, jsScript, nil)
 	}
 	return err
@@ -90,7 +97,7 @@ func (x *JsSwitchNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 		}
 	}
 
-	out, err := x.jsEngine.Execute("Switch", data, msg.Metadata.Values(), msg.Type)
+	out, err := js.Execute(x.jsEngine, x.withContext, ctx, msg, "Switch", data, msg.Metadata.Values(), msg.Type)
 
 	if err != nil {
 		ctx.TellFailure(msg, err)
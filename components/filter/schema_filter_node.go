@@ -0,0 +1,200 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+//规则链节点配置示例：
+//{
+//        "id": "s1",
+//        "type": "schemaFilter",
+//        "name": "数据格式校验",
+//        "debugMode": false,
+//        "configuration": {
+//          "schema": "{\"type\":\"object\",\"required\":[\"temperature\"],\"properties\":{\"temperature\":{\"type\":\"number\"}}}",
+//          "metadataSchema": "{\"type\":\"object\",\"required\":[\"deviceId\"]}"
+//        }
+//      }
+import (
+	"errors"
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"strings"
+)
+
+// DefaultValidationErrorsKey 校验错误保存到metadata的默认key
+const DefaultValidationErrorsKey = "validationErrors"
+
+func init() {
+	Registry.Add(&SchemaFilterNode{})
+}
+
+// SchemaFilterNodeConfiguration 节点配置
+type SchemaFilterNodeConfiguration struct {
+	//Schema 消息体JSON Schema，默认使用draft 2020-12，可以通过$schema指定其他版本
+	Schema string
+	//SchemaFile 消息体JSON Schema文件路径，不为空则优先使用，忽略Schema
+	SchemaFile string
+	//MetadataSchema 元数据JSON Schema，为空则不校验元数据，元数据的值都是字符串
+	MetadataSchema string
+	//ErrorsKey 校验错误保存到metadata的key
+	ErrorsKey string
+}
+
+// SchemaFilterNode 使用JSON Schema校验消息体和元数据
+// 校验通过则把消息通过`True`链发送到下一个节点，否则通过`False`链发送到下一个节点
+// 校验错误以JSON数组格式保存到metadata，例如：
+//
+//	[{"target":"msg","instanceLocation":"/temperature","keywordLocation":"/properties/temperature/type","message":"expected number, but got string"}]
+//
+// 消息体不是合法的JSON也认为校验不通过
+type SchemaFilterNode struct {
+	//节点配置
+	Config         SchemaFilterNodeConfiguration
+	schema         *jsonschema.Schema
+	metadataSchema *jsonschema.Schema
+}
+
+// SchemaValidationError 校验错误
+type SchemaValidationError struct {
+	//Target 校验对象：msg、metadata
+	Target string `json:"target"`
+	//InstanceLocation 校验失败的值位置，JSON Pointer格式
+	InstanceLocation string `json:"instanceLocation"`
+	//KeywordLocation 校验失败的schema关键字位置
+	KeywordLocation string `json:"keywordLocation"`
+	Message         string `json:"message"`
+}
+
+// Type 组件类型
+func (x *SchemaFilterNode) Type() string {
+	return "schemaFilter"
+}
+
+func (x *SchemaFilterNode) New() types.Node {
+	return &SchemaFilterNode{Config: SchemaFilterNodeConfiguration{
+		Schema:    "{\"type\":\"object\"}",
+		ErrorsKey: DefaultValidationErrorsKey,
+	}}
+}
+
+// Init 初始化
+func (x *SchemaFilterNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.Config); err != nil {
+		return err
+	}
+	if x.Config.ErrorsKey == "" {
+		x.Config.ErrorsKey = DefaultValidationErrorsKey
+	}
+	var err error
+	if x.Config.SchemaFile != "" {
+		compiler := newSchemaCompiler()
+		x.schema, err = compiler.Compile(x.Config.SchemaFile)
+	} else if strings.TrimSpace(x.Config.Schema) != "" {
+		x.schema, err = compileSchema("msg.schema.json", x.Config.Schema)
+	} else {
+		err = fmt.Errorf("schema and schemaFile are empty")
+	}
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(x.Config.MetadataSchema) != "" {
+		x.metadataSchema, err = compileSchema("metadata.schema.json", x.Config.MetadataSchema)
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *SchemaFilterNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var validationErrors []SchemaValidationError
+	var data interface{}
+	if err := json.Unmarshal([]byte(msg.Data), &data); err != nil {
+		validationErrors = append(validationErrors, SchemaValidationError{Target: types.MsgKey, Message: "invalid json: " + err.Error()})
+	} else {
+		validationErrors = append(validationErrors, validate(x.schema, types.MsgKey, data)...)
+	}
+	if x.metadataSchema != nil {
+		metadata := make(map[string]interface{})
+		for k, v := range msg.Metadata.Values() {
+			metadata[k] = v
+		}
+		validationErrors = append(validationErrors, validate(x.metadataSchema, types.MetadataKey, metadata)...)
+	}
+	if len(validationErrors) == 0 {
+		ctx.TellNext(msg, types.True)
+		return
+	}
+	value, err := json.Marshal(validationErrors)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.Metadata.PutValue(x.Config.ErrorsKey, string(value))
+	ctx.TellNext(msg, types.False)
+}
+
+// Destroy 销毁
+func (x *SchemaFilterNode) Destroy() {
+}
+
+func newSchemaCompiler() *jsonschema.Compiler {
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	//2020-12 format默认只是注释，开启format校验
+	compiler.AssertFormat = true
+	return compiler
+}
+
+// compileSchema 编译内联schema
+func compileSchema(url string, schema string) (*jsonschema.Schema, error) {
+	compiler := newSchemaCompiler()
+	if err := compiler.AddResource(url, strings.NewReader(schema)); err != nil {
+		return nil, err
+	}
+	return compiler.Compile(url)
+}
+
+// validate 校验，返回最底层的错误列表
+func validate(schema *jsonschema.Schema, target string, value interface{}) []SchemaValidationError {
+	err := schema.Validate(value)
+	if err == nil {
+		return nil
+	}
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return []SchemaValidationError{{Target: target, Message: err.Error()}}
+	}
+	var result []SchemaValidationError
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			result = append(result, SchemaValidationError{
+				Target:           target,
+				InstanceLocation: e.InstanceLocation,
+				KeywordLocation:  e.KeywordLocation,
+				Message:          e.Message,
+			})
+			return
+		}
+		for _, cause := range e.Causes {
+			walk(cause)
+		}
+	}
+	walk(ve)
+	return result
+}
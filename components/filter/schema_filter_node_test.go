@@ -0,0 +1,146 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"github.com/rulego/rulego/utils/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSchemaFilterNode(t *testing.T) {
+	var targetNodeType = "schemaFilter"
+
+	var schema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "required": ["deviceId", "temperature"],
+  "properties": {
+    "deviceId": {"type": "string", "minLength": 2},
+    "temperature": {"type": "number", "minimum": -50, "maximum": 150},
+    "email": {"type": "string", "format": "email"},
+    "tags": {"type": "array", "prefixItems": [{"type": "string"}], "items": false}
+  }
+}`
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &SchemaFilterNode{}, types.Configuration{
+			"schema":    "{\"type\":\"object\"}",
+			"errorsKey": DefaultValidationErrorsKey,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"schema":    schema,
+			"errorsKey": "",
+		}, types.Configuration{
+			"schema":    schema,
+			"errorsKey": DefaultValidationErrorsKey,
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"schema": ""}, Registry)
+		assert.Equal(t, "schema and schemaFile are empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"schema": "{\"type\":"}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"schema": "{\"type\":\"unknown\"}"}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"schema": "", "schemaFile": "./not_found.json"}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	var getErrors = func(msg types.RuleMsg) []SchemaValidationError {
+		var result []SchemaValidationError
+		if v := msg.Metadata.GetValue(DefaultValidationErrorsKey); v != "" {
+			_ = json.Unmarshal([]byte(v), &result)
+		}
+		return result
+	}
+
+	t.Run("OnMsg", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"schema":         schema,
+			"metadataSchema": "{\"type\":\"object\",\"required\":[\"productType\"]}",
+		}, Registry)
+		assert.Nil(t, err)
+		metaData := types.NewMetadata()
+		metaData.PutValue("productType", "test")
+
+		var relations []string
+		var errs [][]SchemaValidationError
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: metaData.Copy(), Data: `{"deviceId":"aa","temperature":41,"email":"a@b.com","tags":["x"]}`},
+			{MetaData: metaData.Copy(), Data: `{"deviceId":"a","temperature":"41"}`},
+			{MetaData: metaData.Copy(), Data: `{"deviceId":"aa","temperature":200,"email":"abc","tags":["x","y"]}`},
+			{MetaData: types.NewMetadata(), Data: `{"deviceId":"aa","temperature":41}`},
+			{MetaData: metaData.Copy(), Data: `aa`},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			relations = append(relations, relationType)
+			errs = append(errs, getErrors(msg))
+		})
+		assert.Equal(t, []string{types.True, types.False, types.False, types.False, types.False}, relations)
+		assert.Equal(t, 0, len(errs[0]))
+
+		assert.Equal(t, 2, len(errs[1]))
+		locations := map[string]string{}
+		for _, item := range errs[1] {
+			assert.Equal(t, types.MsgKey, item.Target)
+			locations[item.InstanceLocation] = item.KeywordLocation
+		}
+		assert.Equal(t, map[string]string{
+			"/deviceId":    "/properties/deviceId/minLength",
+			"/temperature": "/properties/temperature/type",
+		}, locations)
+
+		assert.Equal(t, 3, len(errs[2]))
+
+		assert.Equal(t, 1, len(errs[3]))
+		assert.Equal(t, types.MetadataKey, errs[3][0].Target)
+		assert.Equal(t, "/required", errs[3][0].KeywordLocation)
+
+		assert.Equal(t, 1, len(errs[4]))
+		assert.Equal(t, "", errs[4][0].InstanceLocation)
+	})
+
+	t.Run("SchemaFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "device.schema.json")
+		assert.Nil(t, os.WriteFile(path, []byte(schema), 0644))
+		//只配置schemaFile，使用文件中的schema，忽略默认的schema
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"schemaFile": path,
+			"errorsKey":  "errors",
+		}, Registry)
+		assert.Nil(t, err)
+		var relations []string
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: types.NewMetadata(), Data: `{"deviceId":"aa","temperature":41}`},
+			{MetaData: types.NewMetadata(), Data: `{"deviceId":"aa"}`},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			relations = append(relations, relationType)
+			if relationType == types.False {
+				assert.True(t, msg.Metadata.GetValue("errors") != "")
+			}
+		})
+		assert.Equal(t, []string{types.True, types.False}, relations)
+	})
+}
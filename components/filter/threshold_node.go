@@ -0,0 +1,189 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+//规则链节点配置示例：温度>=50进入超限状态，<=45才恢复正常
+//{
+//        "id": "s1",
+//        "type": "threshold",
+//        "name": "温度阈值",
+//        "debugMode": false,
+//        "configuration": {
+//          "key": "${deviceId}",
+//          "field": "temperature",
+//          "high": 50,
+//          "low": 45,
+//          "direction": "above"
+//        }
+//      }
+import (
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/num"
+	"github.com/rulego/rulego/utils/str"
+	"sync"
+)
+
+// 阈值方向
+const (
+	//ThresholdAbove 值>=high进入超限状态，<=low恢复正常
+	ThresholdAbove = "above"
+	//ThresholdBelow 值<=low进入超限状态，>=high恢复正常
+	ThresholdBelow = "below"
+)
+
+// 阈值节点输出的metadata
+const (
+	//ThresholdStateKey 当前状态：exceeded(超限)、normal(正常)
+	ThresholdStateKey = "thresholdState"
+	//ThresholdChangedKey 本条消息是否导致状态变化：true/false
+	ThresholdChangedKey = "thresholdChanged"
+)
+
+// 阈值状态
+const (
+	ThresholdExceeded = "exceeded"
+	ThresholdNormal   = "normal"
+)
+
+func init() {
+	Registry.Add(&ThresholdNode{})
+}
+
+// ThresholdNodeConfiguration 节点配置
+type ThresholdNodeConfiguration struct {
+	//Key 分组键，每个分组独立维护状态，可以使用${metadataKey}方式从metadata获取
+	Key string
+	//Field 比较的字段，支持嵌套字段，例如：temperature或者data.temperature
+	Field string
+	//High 上限
+	High float64
+	//Low 下限，必须小于或者等于上限，两者之间为回差区间，在该区间内保持原状态
+	Low float64
+	//Direction 方向：above(超过上限告警)、below(低于下限告警)
+	Direction string
+}
+
+// ThresholdNode 带回差的阈值过滤节点，避免值在阈值附近波动时状态频繁切换
+// 处于超限状态通过`True`链发送到下一个节点，否则通过`False`链发送到下一个节点，字段不是数字则发送到`Failure`链
+// 状态保存在`types.Config.StateStore`节点作用域，并把状态写入metadata：thresholdState、thresholdChanged
+type ThresholdNode struct {
+	//节点配置
+	Config ThresholdNodeConfiguration
+	//保证同一个分组读取和修改状态的原子性
+	mu sync.Mutex
+}
+
+// Type 组件类型
+func (x *ThresholdNode) Type() string {
+	return "threshold"
+}
+
+func (x *ThresholdNode) New() types.Node {
+	return &ThresholdNode{Config: ThresholdNodeConfiguration{
+		Field:     "temperature",
+		High:      50,
+		Low:       45,
+		Direction: ThresholdAbove,
+	}}
+}
+
+// Init 初始化
+func (x *ThresholdNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.Config); err != nil {
+		return err
+	}
+	if x.Config.Field == "" {
+		return fmt.Errorf("field is empty")
+	}
+	if x.Config.Low > x.Config.High {
+		return fmt.Errorf("low can not be greater than high")
+	}
+	switch x.Config.Direction {
+	case "":
+		x.Config.Direction = ThresholdAbove
+	case ThresholdAbove, ThresholdBelow:
+	default:
+		return fmt.Errorf("unsupported direction: %s", x.Config.Direction)
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *ThresholdNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var data interface{}
+	if err := json.Unmarshal([]byte(msg.Data), &data); err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	value, err := num.ToFloat64(maps.Get(data, x.Config.Field))
+	if err != nil {
+		ctx.TellFailure(msg, fmt.Errorf("invalid field %s:%s", x.Config.Field, err.Error()))
+		return
+	}
+	store, err := types.ScopedState(ctx, types.StateScopeNode)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	key := "threshold:" + str.SprintfDict(x.Config.Key, msg.Metadata.Values())
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	last, _, err := store.Get(key)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	exceeded, _ := last.(bool)
+	changed := x.transition(exceeded, value)
+	if changed {
+		exceeded = !exceeded
+		if err := store.Set(key, exceeded, 0); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+	}
+	msg.Metadata.PutValue(ThresholdChangedKey, str.ToString(changed))
+	if exceeded {
+		msg.Metadata.PutValue(ThresholdStateKey, ThresholdExceeded)
+		ctx.TellNext(msg, types.True)
+	} else {
+		msg.Metadata.PutValue(ThresholdStateKey, ThresholdNormal)
+		ctx.TellNext(msg, types.False)
+	}
+}
+
+// Destroy 销毁
+func (x *ThresholdNode) Destroy() {
+}
+
+// transition 判断值是否导致状态切换
+func (x *ThresholdNode) transition(exceeded bool, value float64) bool {
+	if x.Config.Direction == ThresholdBelow {
+		if exceeded {
+			return value >= x.Config.High
+		}
+		return value <= x.Config.Low
+	}
+	if exceeded {
+		return value <= x.Config.Low
+	}
+	return value >= x.Config.High
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"testing"
+)
+
+func TestThresholdNode(t *testing.T) {
+	var targetNodeType = "threshold"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &ThresholdNode{}, types.Configuration{
+			"field":     "temperature",
+			"high":      float64(50),
+			"low":       float64(45),
+			"direction": ThresholdAbove,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"key":       "${deviceId}",
+			"field":     "data.battery",
+			"high":      20,
+			"low":       10,
+			"direction": ThresholdBelow,
+		}, types.Configuration{
+			"key":       "${deviceId}",
+			"field":     "data.battery",
+			"high":      float64(20),
+			"low":       float64(10),
+			"direction": ThresholdBelow,
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"field": ""}, Registry)
+		assert.Equal(t, "field is empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"high": 10, "low": 20}, Registry)
+		assert.Equal(t, "low can not be greater than high", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"direction": "up"}, Registry)
+		assert.Equal(t, "unsupported direction: up", err.Error())
+	})
+
+	var newMetadata = func(deviceId string) types.Metadata {
+		metaData := types.NewMetadata()
+		metaData.PutValue("deviceId", deviceId)
+		return metaData
+	}
+
+	t.Run("Above", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key": "${deviceId}",
+		}, Registry)
+		assert.Nil(t, err)
+		var results []string
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: newMetadata("aa"), Data: "{\"temperature\":49}"},
+			{MetaData: newMetadata("aa"), Data: "{\"temperature\":50}"},
+			//回差区间内保持超限
+			{MetaData: newMetadata("aa"), Data: "{\"temperature\":47}"},
+			{MetaData: newMetadata("bb"), Data: "{\"temperature\":47}"},
+			{MetaData: newMetadata("aa"), Data: "{\"temperature\":49.5}"},
+			{MetaData: newMetadata("aa"), Data: "{\"temperature\":45}"},
+			{MetaData: newMetadata("aa"), Data: "{\"temperature\":48}"},
+			{MetaData: newMetadata("aa"), Data: "{\"temperature\":\"aa\"}"},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			results = append(results, relationType+":"+msg.Metadata.GetValue(ThresholdStateKey)+":"+msg.Metadata.GetValue(ThresholdChangedKey))
+		})
+		assert.Equal(t, []string{
+			"False:normal:false",
+			"True:exceeded:true",
+			"True:exceeded:false",
+			"False:normal:false",
+			"True:exceeded:false",
+			"False:normal:true",
+			"False:normal:false",
+			"Failure::",
+		}, results)
+	})
+
+	t.Run("Below", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"field":     "battery",
+			"high":      20,
+			"low":       10,
+			"direction": ThresholdBelow,
+		}, Registry)
+		assert.Nil(t, err)
+		var results []string
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: newMetadata("aa"), Data: "{\"battery\":12}"},
+			{MetaData: newMetadata("aa"), Data: "{\"battery\":10}"},
+			{MetaData: newMetadata("aa"), Data: "{\"battery\":15}"},
+			{MetaData: newMetadata("aa"), Data: "{\"battery\":20}"},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			results = append(results, relationType)
+		})
+		assert.Equal(t, []string{types.False, types.True, types.True, types.False}, results)
+	})
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package js
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/gofrs/uuid/v5"
+	"github.com/rulego/rulego/utils/times"
+	"hash"
+	"net/url"
+	"time"
+)
+
+// Builtins js内置函数库，使用go原生实现，js vm 初始化时注入，可以通过`Config.DisableJsBuiltins`禁用
+// 使用方式：$crypto.md5(msg.name)
+//
+// $encoding 编码：
+//
+//	base64Encode(str) base64Decode(str) base64UrlEncode(str) base64UrlDecode(str)
+//	hexEncode(str) hexDecode(str) urlEncode(str) urlDecode(str)
+//
+// $crypto 摘要，返回十六进制字符串：
+//
+//	md5(str) sha1(str) sha256(str) sha512(str)
+//	hmacMd5(str,key) hmacSha1(str,key) hmacSha256(str,key) hmacSha512(str,key)
+//
+// $uuid 唯一ID：
+//
+//	v4() v7()
+//
+// $time 时间，layout 使用 yyyy-MM-dd HH:mm:ss.SSS 风格或者go时间格式：
+//
+//	now() 当前毫秒时间戳
+//	format(time,layout) time可以是Date对象或者毫秒时间戳，为空则使用当前时间
+//	parse(str,layout) 解析时间，返回毫秒时间戳
+var Builtins = map[string]map[string]interface{}{
+	"$encoding": {
+		"base64Encode": func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		},
+		"base64Decode": func(s string) (string, error) {
+			b, err := base64.StdEncoding.DecodeString(s)
+			return string(b), err
+		},
+		"base64UrlEncode": func(s string) string {
+			return base64.RawURLEncoding.EncodeToString([]byte(s))
+		},
+		"base64UrlDecode": func(s string) (string, error) {
+			b, err := base64.RawURLEncoding.DecodeString(s)
+			return string(b), err
+		},
+		"hexEncode": func(s string) string {
+			return hex.EncodeToString([]byte(s))
+		},
+		"hexDecode": func(s string) (string, error) {
+			b, err := hex.DecodeString(s)
+			return string(b), err
+		},
+		"urlEncode": url.QueryEscape,
+		"urlDecode": url.QueryUnescape,
+	},
+	"$crypto": {
+		"md5": func(s string) string {
+			return digest(md5.New(), s)
+		},
+		"sha1": func(s string) string {
+			return digest(sha1.New(), s)
+		},
+		"sha256": func(s string) string {
+			return digest(sha256.New(), s)
+		},
+		"sha512": func(s string) string {
+			return digest(sha512.New(), s)
+		},
+		"hmacMd5": func(s, key string) string {
+			return digest(hmac.New(md5.New, []byte(key)), s)
+		},
+		"hmacSha1": func(s, key string) string {
+			return digest(hmac.New(sha1.New, []byte(key)), s)
+		},
+		"hmacSha256": func(s, key string) string {
+			return digest(hmac.New(sha256.New, []byte(key)), s)
+		},
+		"hmacSha512": func(s, key string) string {
+			return digest(hmac.New(sha512.New, []byte(key)), s)
+		},
+	},
+	"$uuid": {
+		"v4": func() (string, error) {
+			v, err := uuid.NewV4()
+			return v.String(), err
+		},
+		"v7": func() (string, error) {
+			v, err := uuid.NewV7()
+			return v.String(), err
+		},
+	},
+	"$time": {
+		"now": func() int64 {
+			return time.Now().UnixMilli()
+		},
+		"format": func(value interface{}, layout string) (string, error) {
+			t, err := toTime(value)
+			if err != nil {
+				return "", err
+			}
+			return times.Format(t, layout), nil
+		},
+		"parse": func(value string, layout string) (int64, error) {
+			t, err := times.Parse(layout, value)
+			return t.UnixMilli(), err
+		},
+	},
+}
+
+// digest 计算摘要，返回十六进制字符串
+func digest(h hash.Hash, s string) string {
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// toTime js Date对象或者毫秒时间戳转time.Time
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case nil:
+		return time.Now(), nil
+	case time.Time:
+		return v, nil
+	case int64:
+		return times.FromUnixMilli(v), nil
+	case float64:
+		return times.FromUnixMilli(int64(v)), nil
+	default:
+		return time.Time{}, fmt.Errorf("unsupported time value:%v", value)
+	}
+}
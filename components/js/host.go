@@ -0,0 +1,292 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package js
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dop251/goja"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/str"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//HostContextKey 脚本回调规则引擎的宿主对象名称
+	HostContextKey = "$ctx"
+	//RelationTypeKey 函数调用结果关系类型字段
+	RelationTypeKey = "relationType"
+)
+
+// awaitPattern 脚本使用await时需要包装成async函数
+var awaitPattern = regexp.MustCompile(`\bawait\b`)
+
+// WrapFunction 把脚本包装成js函数，返回函数定义和是否需要注入`$ctx`宿主对象
+// 只有脚本使用await时才包装成async函数，避免每次执行都创建promise
+func WrapFunction(functionName, params, script string) (string, bool) {
+	jsScript := fmt.Sprintf("function %s(%s) { %s }", functionName, params, script)
+	if awaitPattern.MatchString(script) {
+		jsScript = "async " + jsScript
+	}
+	return jsScript, strings.Contains(script, HostContextKey)
+}
+
+// Execute 执行js脚本指定函数，withContext为true并且引擎支持时注入`$ctx`宿主对象
+func Execute(engine types.JsEngine, withContext bool, ctx types.RuleContext, msg types.RuleMsg, functionName string, argumentList ...interface{}) (interface{}, error) {
+	if withContext {
+		if contextEngine, ok := engine.(types.JsContextEngine); ok {
+			return contextEngine.ExecuteWithContext(ctx, msg, functionName, argumentList...)
+		}
+	}
+	return engine.Execute(functionName, argumentList...)
+}
+
+// flushProgram 空脚本，用于触发执行promise回调任务队列
+var flushProgram = goja.MustCompile("", "", true)
+
+// eventLoop 在js vm所在协程执行异步任务回调，用于等待promise执行完成
+type eventLoop struct {
+	tasks chan func()
+	done  chan struct{}
+}
+
+func newEventLoop() *eventLoop {
+	return &eventLoop{
+		tasks: make(chan func()),
+		done:  make(chan struct{}),
+	}
+}
+
+// post 提交任务到js vm所在协程执行，如果事件循环已经结束则丢弃
+func (l *eventLoop) post(task func()) {
+	select {
+	case l.tasks <- task:
+	case <-l.done:
+	}
+}
+
+func (l *eventLoop) close() {
+	close(l.done)
+}
+
+// hostContext 脚本回调规则引擎的宿主对象，每次执行创建一个实例
+// 提供以下方法：
+// $ctx.tellNext(msg, relationType...) 使用指定关系，把新消息发送到下一个节点
+// $ctx.call(functionName, msg) 调用`Config.HostFunctions`注册的函数，返回promise，结果格式：{'msg':msg,'metadata':metadata,'msgType':msgType,'relationType':relationType}
+// $ctx.state 当前规则链作用域的状态存储，提供get(key)、set(key, value, ttlMs)、incr(key, delta, ttlMs)、del(key)方法，
+// 通过$ctx.state.scope('global'|'chain'|'node')获取其他作用域的状态存储
+// msg 可以是{'msg':msg,'metadata':metadata,'msgType':msgType}格式，也可以是消息体，缺少的字段使用当前消息的值
+type hostContext struct {
+	vm   *goja.Runtime
+	ctx  types.RuleContext
+	msg  types.RuleMsg
+	loop *eventLoop
+	//functions `$ctx.call`调用的函数库
+	functions types.FunctionsGetter
+}
+
+// object 创建js宿主对象
+func (h *hostContext) object() *goja.Object {
+	obj := h.vm.NewObject()
+	_ = obj.Set("tellNext", h.tellNext)
+	_ = obj.Set("call", h.call)
+	_ = obj.Set("selfId", h.ctx.GetSelfId())
+	if h.ctx.StateStore() != nil {
+		_ = obj.Set("state", h.stateObject(types.StateScopeChain))
+	}
+	return obj
+}
+
+// stateObject 创建指定作用域的js状态存储对象
+func (h *hostContext) stateObject(scope string) *goja.Object {
+	store, err := types.ScopedState(h.ctx, scope)
+	if err != nil {
+		panic(h.vm.NewGoError(err))
+	}
+	obj := h.vm.NewObject()
+	_ = obj.Set("get", func(key string) interface{} {
+		value, ok, err := store.Get(key)
+		if err != nil {
+			panic(h.vm.NewGoError(err))
+		}
+		if !ok {
+			return nil
+		}
+		return value
+	})
+	_ = obj.Set("set", func(key string, value goja.Value, ttlMs int64) {
+		if err := store.Set(key, value.Export(), time.Duration(ttlMs)*time.Millisecond); err != nil {
+			panic(h.vm.NewGoError(err))
+		}
+	})
+	_ = obj.Set("incr", func(call goja.FunctionCall) goja.Value {
+		delta := 1.0
+		if arg := call.Argument(1); !goja.IsUndefined(arg) {
+			delta = arg.ToFloat()
+		}
+		ttl := time.Duration(call.Argument(2).ToInteger()) * time.Millisecond
+		value, err := store.Incr(call.Argument(0).String(), delta, ttl)
+		if err != nil {
+			panic(h.vm.NewGoError(err))
+		}
+		return h.vm.ToValue(value)
+	})
+	_ = obj.Set("del", func(key string) {
+		if err := store.Delete(key); err != nil {
+			panic(h.vm.NewGoError(err))
+		}
+	})
+	_ = obj.Set("scope", h.stateObject)
+	return obj
+}
+
+func (h *hostContext) tellNext(call goja.FunctionCall) goja.Value {
+	msg, err := h.toRuleMsg(call.Argument(0))
+	if err != nil {
+		panic(h.vm.NewGoError(err))
+	}
+	var relationTypes []string
+	for _, item := range call.Arguments[1:] {
+		relationTypes = append(relationTypes, item.String())
+	}
+	if len(relationTypes) == 0 {
+		relationTypes = []string{types.Success}
+	}
+	h.ctx.TellNext(msg, relationTypes...)
+	return goja.Undefined()
+}
+
+func (h *hostContext) call(call goja.FunctionCall) goja.Value {
+	functionName := call.Argument(0).String()
+	promise, resolve, reject := h.vm.NewPromise()
+	var f func(ctx types.RuleContext, msg types.RuleMsg)
+	var ok bool
+	if h.functions != nil {
+		f, ok = h.functions.Get(functionName)
+	}
+	if !ok {
+		reject(h.vm.NewGoError(fmt.Errorf("can not found the function=%s", functionName)))
+		return h.vm.ToValue(promise)
+	}
+	msg := h.msg.Copy()
+	if !goja.IsUndefined(call.Argument(1)) {
+		var err error
+		if msg, err = h.toRuleMsg(call.Argument(1)); err != nil {
+			reject(h.vm.NewGoError(err))
+			return h.vm.ToValue(promise)
+		}
+	}
+	callCtx := &functionCallContext{RuleContext: h.ctx}
+	callCtx.onResult = func(resultMsg types.RuleMsg, relationType string, err error) {
+		h.loop.post(func() {
+			if err != nil {
+				reject(h.vm.NewGoError(err))
+			} else {
+				resolve(h.toJsResult(resultMsg, relationType))
+			}
+		})
+	}
+	h.ctx.SubmitTack(func() {
+		defer func() {
+			if e := recover(); e != nil {
+				callCtx.tell(msg, types.Failure, fmt.Errorf("%v", e))
+			}
+		}()
+		f(callCtx, msg)
+	})
+	return h.vm.ToValue(promise)
+}
+
+// toRuleMsg js值转换成新的消息
+func (h *hostContext) toRuleMsg(value goja.Value) (types.RuleMsg, error) {
+	msgType := h.msg.Type
+	metadata := h.msg.Metadata.Copy()
+	var data interface{}
+	if !goja.IsUndefined(value) && !goja.IsNull(value) {
+		data = value.Export()
+	}
+	if formatData, ok := data.(map[string]interface{}); ok {
+		if _, hasMsg := formatData[types.MsgKey]; hasMsg {
+			data = formatData[types.MsgKey]
+			if v, ok := formatData[types.MsgTypeKey]; ok {
+				msgType = str.ToString(v)
+			}
+			if v, ok := formatData[types.MetadataKey]; ok {
+				metadata = types.BuildMetadata(str.ToStringMapString(v))
+			}
+		}
+	}
+	newData, err := str.ToStringMaybeErr(data)
+	if err != nil {
+		return types.RuleMsg{}, err
+	}
+	return types.NewMsg(0, msgType, h.msg.DataType, metadata, newData), nil
+}
+
+// toJsResult 函数调用结果转换成js对象
+func (h *hostContext) toJsResult(msg types.RuleMsg, relationType string) map[string]interface{} {
+	var data interface{} = msg.Data
+	if msg.DataType == types.JSON {
+		var dataMap interface{}
+		if err := json.Unmarshal([]byte(msg.Data), &dataMap); err == nil {
+			data = dataMap
+		}
+	}
+	return map[string]interface{}{
+		types.MsgKey:      data,
+		types.MetadataKey: msg.Metadata.Values(),
+		types.MsgTypeKey:  msg.Type,
+		RelationTypeKey:   relationType,
+	}
+}
+
+// functionCallContext 脚本调用函数使用的上下文，拦截函数处理结果，不会流转到下一个节点
+type functionCallContext struct {
+	types.RuleContext
+	once     sync.Once
+	onResult func(msg types.RuleMsg, relationType string, err error)
+}
+
+func (c *functionCallContext) TellSuccess(msg types.RuleMsg) {
+	c.tell(msg, types.Success, nil)
+}
+
+func (c *functionCallContext) TellFailure(msg types.RuleMsg, err error) {
+	if err == nil {
+		err = errors.New("function call failure")
+	}
+	c.tell(msg, types.Failure, err)
+}
+
+func (c *functionCallContext) TellNext(msg types.RuleMsg, relationTypes ...string) {
+	relationType := types.Success
+	if len(relationTypes) > 0 {
+		relationType = relationTypes[0]
+	}
+	c.tell(msg, relationType, nil)
+}
+
+// tell 只有第一次调用有效
+func (c *functionCallContext) tell(msg types.RuleMsg, relationType string, err error) {
+	c.once.Do(func() {
+		c.onResult(msg, relationType, err)
+	})
+}
@@ -37,6 +37,8 @@ type GojaJsEngine struct {
 	config            types.Config
 	jsScript          *goja.Program
 	jsUdfProgramCache map[string]*goja.Program
+	//js模块编译缓存，key:模块规范化名称
+	jsModuleProgramCache map[string]*goja.Program
 }
 
 // NewGojaJsEngine Create a new instance of the JavaScript engine
@@ -84,12 +86,36 @@ func (g *GojaJsEngine) PreCompileJs(config types.Config) error {
 	}
 	g.jsUdfProgramCache = jsUdfProgramCache
 
+	var jsModuleProgramCache = make(map[string]*goja.Program)
+	for k, v := range config.JsModules {
+		name := ResolveModuleName(k, "")
+		if p, err := compileModule(name, v); err != nil {
+			return err
+		} else {
+			jsModuleProgramCache[name] = p
+		}
+	}
+	g.jsModuleProgramCache = jsModuleProgramCache
 	return nil
 }
 
 // NewVm new a js VM
 func (g *GojaJsEngine) NewVm(config types.Config, fromVars map[string]interface{}) *goja.Runtime {
 	vm := goja.New()
+	//Add CommonJS require function to the JavaScript runtime
+	if err := vm.Set(RequireKey, newModuleLoader(vm, g.jsModuleProgramCache).requireFunc("")); err != nil {
+		panic(errors.New("set require function error,err:" + err.Error()))
+	}
+	//Add builtin functions to the JavaScript runtime
+	if !config.DisableJsBuiltins {
+		for name, functions := range Builtins {
+			obj := vm.NewObject()
+			for k, f := range functions {
+				_ = obj.Set(k, f)
+			}
+			_ = vm.Set(name, obj)
+		}
+	}
 	vars := make(map[string]interface{})
 	if fromVars != nil {
 		for k, v := range fromVars {
@@ -149,6 +175,19 @@ func (g *GojaJsEngine) NewVm(config types.Config, fromVars map[string]interface{
 
 // Execute Execute JavaScript script
 func (g *GojaJsEngine) Execute(functionName string, argumentList ...interface{}) (out interface{}, err error) {
+	return g.execute(nil, types.RuleMsg{}, functionName, argumentList...)
+}
+
+// ExecuteWithContext 执行js脚本指定函数，并在脚本中注入`$ctx`宿主对象，允许脚本回调规则引擎
+// 脚本可以通过`$ctx.tellNext(msg, relationType...)`发送新消息到下一个节点，
+// 通过`$ctx.call(functionName, msg)`调用`Config.HostFunctions`注册的函数，该方法返回promise，可以使用await等待结果
+// 如果函数返回promise，则等待其完成，等待时间受`Config.ScriptMaxExecutionTime`限制，
+// 等待超时的vm中仍有未完成的promise，不再放回vm池
+func (g *GojaJsEngine) ExecuteWithContext(ctx types.RuleContext, msg types.RuleMsg, functionName string, argumentList ...interface{}) (out interface{}, err error) {
+	return g.execute(ctx, msg, functionName, argumentList...)
+}
+
+func (g *GojaJsEngine) execute(ctx types.RuleContext, msg types.RuleMsg, functionName string, argumentList ...interface{}) (out interface{}, err error) {
 	defer func() {
 		if caught := recover(); caught != nil {
 			err = fmt.Errorf("%s", caught)
@@ -156,28 +195,73 @@ func (g *GojaJsEngine) Execute(functionName string, argumentList ...interface{})
 	}()
 
 	vm := g.vmPool.Get().(*goja.Runtime)
-
+	startTime := time.Now()
 	state := g.setTimeout(vm)
 
 	f, ok := goja.AssertFunction(vm.Get(functionName))
 	if !ok {
 		return nil, errors.New(functionName + " is not a function")
 	}
+	loop := newEventLoop()
+	defer loop.close()
+	if ctx != nil {
+		host := &hostContext{vm: vm, ctx: ctx, msg: msg, loop: loop, functions: g.config.HostFunctions}
+		_ = vm.Set(HostContextKey, host.object())
+	}
 	var params []goja.Value
 	for _, v := range argumentList {
 		params = append(params, vm.ToValue(v))
 	}
 	res, err := f(goja.Undefined(), params...)
+	var isAsync, pending bool
+	if err == nil {
+		if promise, ok := res.Export().(*goja.Promise); ok {
+			isAsync = true
+			res, err = g.await(vm, loop, promise, g.config.ScriptMaxExecutionTime-time.Since(startTime))
+			pending = promise.State() == goja.PromiseStatePending
+		}
+	}
 	//If there is no timeout, state=0; otherwise, state=-2
 	closeStateChan(state)
+	if isAsync {
+		//等待promise期间可能触发了超时中断，清除中断标志，避免影响下次执行
+		vm.ClearInterrupt()
+	}
+	if ctx != nil {
+		_ = vm.Set(HostContextKey, goja.Undefined())
+	}
 	//Put back to the pool
-	g.vmPool.Put(vm)
+	if !pending {
+		g.vmPool.Put(vm)
+	}
 	if err != nil {
 		return nil, err
 	}
 	return res.Export(), err
 }
 
+// await 在当前协程执行异步任务回调，直到promise完成或者超时
+func (g *GojaJsEngine) await(vm *goja.Runtime, loop *eventLoop, promise *goja.Promise, timeout time.Duration) (goja.Value, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for promise.State() == goja.PromiseStatePending {
+		select {
+		case task := <-loop.tasks:
+			task()
+			//执行promise回调任务队列
+			if _, err := vm.RunProgram(flushProgram); err != nil {
+				return nil, err
+			}
+		case <-timer.C:
+			return nil, errors.New("execution timeout")
+		}
+	}
+	if promise.State() == goja.PromiseStateRejected {
+		return nil, errors.New(promise.Result().String())
+	}
+	return promise.Result(), nil
+}
+
 func (g *GojaJsEngine) Stop() {
 }
 
@@ -17,10 +17,13 @@
 package js
 
 import (
+	"errors"
 	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
 	"github.com/rulego/rulego/test/assert"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -205,3 +208,291 @@ func testExecuteJs(t *testing.T, jsEngine *GojaJsEngine, index int, group *sync.
 	jsEngine.config.Logger.Printf("index:%d,响应:%s,用时：%s", index, response, time.Since(start))
 
 }
+
+func TestJsEngineModules(t *testing.T) {
+	var jsScript = `
+	var utils = require('utils');
+	function Double(value) {
+		return utils.double(value);
+	}
+	function Counter() {
+		require('./counter.js').next();
+		return require('counter').next();
+	}
+	function Missing() {
+		return require('missing');
+	}
+	`
+	config := types.NewConfig()
+	config.RegisterJsModule("counter", `
+		var count = 0;
+		module.exports.next = function () {
+			count++;
+			return count;
+		};
+	`)
+	err := LoadModules(&config, "../../testdata/js")
+	assert.Nil(t, err)
+	assert.NotNil(t, config.JsModules["lib/math.js"])
+
+	jsEngine, err := NewGojaJsEngine(config, jsScript, nil)
+	assert.Nil(t, err)
+	defer jsEngine.Stop()
+
+	response, err := jsEngine.Execute("Double", 4)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(8), response.(int64))
+
+	//同一个vm，模块只执行一次
+	response, err = jsEngine.Execute("Counter")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), response.(int64))
+
+	_, err = jsEngine.Execute("Missing")
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "cannot find module 'missing'"))
+
+	//编译错误
+	config.RegisterJsModule("bad", `module.exports = {`)
+	_, err = NewGojaJsEngine(config, jsScript, nil)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, "lib/math", ResolveModuleName("./math.js", "lib/utils"))
+	assert.Equal(t, "math", ResolveModuleName("../math", "lib/utils"))
+	assert.Equal(t, "lib/math", ResolveModuleName("lib/math", ""))
+}
+
+func TestJsEngineBuiltins(t *testing.T) {
+	var jsScript = `
+	function Builtins(value) {
+		return {
+			base64: $encoding.base64Encode(value),
+			base64Decode: $encoding.base64Decode($encoding.base64Encode(value)),
+			hex: $encoding.hexEncode(value),
+			url: $encoding.urlEncode('a b&c'),
+			md5: $crypto.md5(value),
+			sha256: $crypto.sha256(value),
+			hmacSha256: $crypto.hmacSha256(value, 'key'),
+			uuid: $uuid.v4(),
+			date: $time.format(new Date(2024, 0, 2, 3, 4, 5), 'yyyy-MM-dd HH:mm:ss'),
+			ts: $time.parse('2024-01-02 03:04:05', 'yyyy-MM-dd HH:mm:ss'),
+		}
+	}
+	function BadBase64() {
+		return $encoding.base64Decode('!!');
+	}
+	function HasBuiltins() {
+		return typeof $crypto !== 'undefined';
+	}
+	`
+	config := types.NewConfig()
+	jsEngine, err := NewGojaJsEngine(config, jsScript, nil)
+	assert.Nil(t, err)
+	defer jsEngine.Stop()
+
+	response, err := jsEngine.Execute("Builtins", "rulego")
+	assert.Nil(t, err)
+	r := response.(map[string]interface{})
+	assert.Equal(t, "cnVsZWdv", r["base64"])
+	assert.Equal(t, "rulego", r["base64Decode"])
+	assert.Equal(t, "72756c65676f", r["hex"])
+	assert.Equal(t, "a+b%26c", r["url"])
+	assert.Equal(t, "54c1dd32e98582f12595c63f686f1c4e", r["md5"])
+	assert.Equal(t, 64, len(r["sha256"].(string)))
+	assert.Equal(t, 64, len(r["hmacSha256"].(string)))
+	assert.Equal(t, 36, len(r["uuid"].(string)))
+	assert.Equal(t, "2024-01-02 03:04:05", r["date"])
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local).UnixMilli(), r["ts"])
+
+	_, err = jsEngine.Execute("BadBase64")
+	assert.NotNil(t, err)
+
+	//禁用内置函数库
+	config.DisableJsBuiltins = true
+	jsEngine, err = NewGojaJsEngine(config, jsScript, nil)
+	assert.Nil(t, err)
+	response, err = jsEngine.Execute("HasBuiltins")
+	assert.Nil(t, err)
+	assert.Equal(t, false, response)
+}
+
+type testFunctions map[string]func(ctx types.RuleContext, msg types.RuleMsg)
+
+func (f testFunctions) Get(functionName string) (func(ctx types.RuleContext, msg types.RuleMsg), bool) {
+	v, ok := f[functionName]
+	return v, ok
+}
+
+func TestJsEngineWithContext(t *testing.T) {
+	functions := testFunctions{
+		"add": func(ctx types.RuleContext, msg types.RuleMsg) {
+			go func() {
+				time.Sleep(time.Millisecond * 10)
+				msg.Data = `{"value":10}`
+				msg.Metadata.PutValue("from", "add")
+				ctx.TellSuccess(msg)
+			}()
+		},
+		"fail": func(ctx types.RuleContext, msg types.RuleMsg) {
+			ctx.TellFailure(msg, errors.New("fail"))
+		},
+		"hang": func(ctx types.RuleContext, msg types.RuleMsg) {
+		},
+	}
+	var jsScript = `
+	function Tell(msg) {
+		$ctx.tellNext({'msg': {'index': 1}, 'msgType': 'SPLIT'}, 'One', 'Two');
+		$ctx.tellNext('text');
+		return 'ok';
+	}
+	async function Call(msg) {
+		var result = await $ctx.call('add', {'msg': msg});
+		return result.msg.value + 1 + result.metadata.from + result.relationType;
+	}
+	async function CallFail() {
+		try {
+			await $ctx.call('fail');
+		} catch (e) {
+			return 'caught:' + e.message;
+		}
+	}
+	async function CallNotFound() {
+		return await $ctx.call('notFound');
+	}
+	async function CallHang() {
+		return await $ctx.call('hang');
+	}
+	`
+	config := types.NewConfig(types.WithScriptMaxExecutionTime(time.Millisecond*500), types.WithHostFunctions(functions))
+	jsEngine, err := NewGojaJsEngine(config, jsScript, nil)
+	assert.Nil(t, err)
+
+	var count int32
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string, err error) {
+		atomic.AddInt32(&count, 1)
+		if relationType == types.Success {
+			assert.Equal(t, "text", msg.Data)
+		} else {
+			assert.Equal(t, "SPLIT", msg.Type)
+			assert.Equal(t, "{\"index\":1}", msg.Data)
+			assert.Equal(t, "test", msg.Metadata.GetValue("productType"))
+		}
+	})
+	metadata := types.NewMetadata()
+	metadata.PutValue("productType", "test")
+	msg := types.NewMsg(0, "TEST", types.JSON, metadata, "{}")
+
+	out, err := jsEngine.ExecuteWithContext(ctx, msg, "Tell", map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", out)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&count))
+
+	out, err = jsEngine.ExecuteWithContext(ctx, msg, "Call", map[string]interface{}{"value": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, "11addSuccess", out)
+
+	out, err = jsEngine.ExecuteWithContext(ctx, msg, "CallFail")
+	assert.Nil(t, err)
+	assert.Equal(t, "caught:fail", out)
+
+	_, err = jsEngine.ExecuteWithContext(ctx, msg, "CallNotFound")
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "can not found the function=notFound"))
+
+	start := time.Now()
+	_, err = jsEngine.ExecuteWithContext(ctx, msg, "CallHang")
+	assert.NotNil(t, err)
+	assert.Equal(t, "execution timeout", err.Error())
+	assert.True(t, time.Since(start) < time.Second)
+
+	//超时后vm可以继续使用
+	out, err = jsEngine.ExecuteWithContext(ctx, msg, "Call", map[string]interface{}{"value": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, "11addSuccess", out)
+
+	//没有上下文，无法访问$ctx
+	_, err = jsEngine.Execute("Tell", map[string]interface{}{})
+	assert.NotNil(t, err)
+}
+
+func TestJsEngineState(t *testing.T) {
+	var jsScript = `
+	function Count(msg) {
+		var last = $ctx.state.get('last');
+		$ctx.state.set('last', msg.value);
+		var count = $ctx.state.incr('count');
+		$ctx.state.scope('global').incr('total', 2, 1000);
+		return {'last': last, 'count': count, 'total': $ctx.state.scope('global').get('total')};
+	}
+	function Reset() {
+		$ctx.state.del('count');
+		return $ctx.state.get('count');
+	}
+	function BadScope() {
+		return $ctx.state.scope('unknown');
+	}
+	`
+	config := types.NewConfig()
+	jsEngine, err := NewGojaJsEngine(config, jsScript, nil)
+	assert.Nil(t, err)
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string, err error) {})
+	msg := types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}")
+
+	out, err := jsEngine.ExecuteWithContext(ctx, msg, "Count", map[string]interface{}{"value": 1})
+	assert.Nil(t, err)
+	result := out.(map[string]interface{})
+	assert.Nil(t, result["last"])
+	assert.Equal(t, int64(1), result["count"])
+	assert.Equal(t, int64(2), result["total"])
+
+	out, err = jsEngine.ExecuteWithContext(ctx, msg, "Count", map[string]interface{}{"value": 2})
+	assert.Nil(t, err)
+	result = out.(map[string]interface{})
+	assert.Equal(t, int64(1), result["last"])
+	assert.Equal(t, int64(2), result["count"])
+	assert.Equal(t, int64(4), result["total"])
+
+	//状态保存在配置的存储中
+	value, ok, _ := config.StateStore.Get("global:total")
+	assert.True(t, ok)
+	assert.Equal(t, float64(4), value)
+
+	out, err = jsEngine.ExecuteWithContext(ctx, msg, "Reset")
+	assert.Nil(t, err)
+	assert.Nil(t, out)
+
+	_, err = jsEngine.ExecuteWithContext(ctx, msg, "BadScope")
+	assert.NotNil(t, err)
+}
+
+func TestWrapFunction(t *testing.T) {
+	jsScript, withContext := WrapFunction("Filter", "msg, metadata, msgType", "return msg.temperature > 50;")
+	assert.Equal(t, "function Filter(msg, metadata, msgType) { return msg.temperature > 50; }", jsScript)
+	assert.False(t, withContext)
+
+	jsScript, withContext = WrapFunction("Filter", "msg", "$ctx.tellNext(msg);return true;")
+	assert.Equal(t, "function Filter(msg) { $ctx.tellNext(msg);return true; }", jsScript)
+	assert.True(t, withContext)
+
+	jsScript, withContext = WrapFunction("Filter", "msg", "var r = await $ctx.call('f', msg);return true;")
+	assert.Equal(t, "async function Filter(msg) { var r = await $ctx.call('f', msg);return true; }", jsScript)
+	assert.True(t, withContext)
+
+	//不支持上下文的引擎直接执行
+	jsEngine, err := NewGojaJsEngine(types.NewConfig(), jsScript, nil)
+	assert.Nil(t, err)
+	_, err = Execute(noContextEngine{jsEngine}, true, nil, types.RuleMsg{}, "Filter", map[string]interface{}{})
+	assert.NotNil(t, err)
+}
+
+// noContextEngine 不支持上下文的js引擎
+type noContextEngine struct {
+	engine types.JsEngine
+}
+
+func (e noContextEngine) Execute(functionName string, argumentList ...interface{}) (interface{}, error) {
+	return e.engine.Execute(functionName, argumentList...)
+}
+
+func (e noContextEngine) Stop() {
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package js
+
+import (
+	"fmt"
+	"github.com/dop251/goja"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	//RequireKey 模块引用函数名称
+	RequireKey = "require"
+	//moduleFileExt 模块文件后缀
+	moduleFileExt = ".js"
+	//CommonJS 模块包装函数
+	moduleWrapperPrefix = "(function(exports, require, module, __filename, __dirname) {"
+	moduleWrapperSuffix = "\n})"
+)
+
+// moduleProgramCache 已编译的模块缓存，所有js引擎实例共享
+// key:模块名称，value:*compiledModule
+var moduleProgramCache sync.Map
+
+// compiledModule 已编译的模块
+type compiledModule struct {
+	//模块脚本内容，用于判断模块是否被修改
+	source  string
+	program *goja.Program
+}
+
+// LoadModules 加载文件夹下所有的js文件，注册为js模块
+// 模块名称为文件相对文件夹的路径，不包括.js后缀，例如：文件folderPath/lib/utils.js，模块名称为：lib/utils
+func LoadModules(config *types.Config, folderPath string) error {
+	paths, err := fs.GetFilePaths(filepath.Join(folderPath, "*"+moduleFileExt))
+	if err != nil {
+		return err
+	}
+	for _, file := range paths {
+		rel, err := filepath.Rel(folderPath, file)
+		if err != nil {
+			return err
+		}
+		if b := fs.LoadFile(file); b != nil {
+			config.RegisterJsModule(filepath.ToSlash(rel), string(b))
+		}
+	}
+	return nil
+}
+
+// ResolveModuleName 获取模块规范化名称
+// 以./或者../开头的名称，相对parent模块所在路径解析，并且去掉.js后缀
+func ResolveModuleName(name, parent string) string {
+	name = strings.TrimSpace(name)
+	if strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../") {
+		name = path.Join(path.Dir(parent), name)
+	}
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	return strings.TrimSuffix(name, moduleFileExt)
+}
+
+// compileModule 编译模块，如果模块内容没变化，则使用缓存
+func compileModule(name, source string) (*goja.Program, error) {
+	if v, ok := moduleProgramCache.Load(name); ok {
+		if m := v.(*compiledModule); m.source == source {
+			return m.program, nil
+		}
+	}
+	program, err := goja.Compile(name+moduleFileExt, moduleWrapperPrefix+source+moduleWrapperSuffix, true)
+	if err != nil {
+		return nil, err
+	}
+	moduleProgramCache.Store(name, &compiledModule{source: source, program: program})
+	return program, nil
+}
+
+// moduleLoader 模块加载器，每个js vm一个实例
+// 同一个vm，模块只会执行一次，后续引用返回已缓存的module.exports
+type moduleLoader struct {
+	vm       *goja.Runtime
+	programs map[string]*goja.Program
+	modules  map[string]*goja.Object
+}
+
+func newModuleLoader(vm *goja.Runtime, programs map[string]*goja.Program) *moduleLoader {
+	return &moduleLoader{
+		vm:       vm,
+		programs: programs,
+		modules:  make(map[string]*goja.Object),
+	}
+}
+
+// requireFunc 创建指定模块使用的require函数，相对路径基于该模块路径解析
+func (l *moduleLoader) requireFunc(parent string) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		name := ResolveModuleName(call.Argument(0).String(), parent)
+		module, err := l.require(name)
+		if err != nil {
+			panic(l.vm.NewGoError(err))
+		}
+		return module.Get("exports")
+	}
+}
+
+// require 加载模块，返回module对象
+func (l *moduleLoader) require(name string) (*goja.Object, error) {
+	if module, ok := l.modules[name]; ok {
+		return module, nil
+	}
+	program, ok := l.programs[name]
+	if !ok {
+		return nil, fmt.Errorf("cannot find module '%s'", name)
+	}
+	module := l.vm.NewObject()
+	exports := l.vm.NewObject()
+	_ = module.Set("exports", exports)
+	_ = module.Set("id", name)
+	//先放入缓存，支持循环引用
+	l.modules[name] = module
+
+	wrapper, err := l.vm.RunProgram(program)
+	if err != nil {
+		delete(l.modules, name)
+		return nil, err
+	}
+	f, ok := goja.AssertFunction(wrapper)
+	if !ok {
+		delete(l.modules, name)
+		return nil, fmt.Errorf("module '%s' is invalid", name)
+	}
+	filename := name + moduleFileExt
+	if _, err = f(exports, exports, l.vm.ToValue(l.requireFunc(name)), module,
+		l.vm.ToValue(filename), l.vm.ToValue(path.Dir(filename))); err != nil {
+		delete(l.modules, name)
+		return nil, err
+	}
+	return module, nil
+}
@@ -0,0 +1,414 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+//规则链节点配置示例：把CSV转换成JSON数组
+//{
+//	"id": "s2",
+//	"type": "codec",
+//	"name": "CSV解码",
+//	"debugMode": false,
+//		"configuration": {
+//			"format": "csv",
+//			"operation": "decode",
+//			"header": true,
+//			"columns": "device_id:deviceId,temp:temperature"
+//	}
+//}
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+	"github.com/vmihailenco/msgpack/v5"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// 编解码格式
+const (
+	CodecCsv     = "csv"
+	CodecXml     = "xml"
+	CodecMsgPack = "msgpack"
+	CodecCbor    = "cbor"
+)
+
+// 编解码操作
+const (
+	//CodecEncode JSON转换成指定格式
+	CodecEncode = "encode"
+	//CodecDecode 指定格式转换成JSON
+	CodecDecode = "decode"
+)
+
+func init() {
+	Registry.Add(&CodecNode{})
+}
+
+// CodecNodeConfiguration 节点配置
+type CodecNodeConfiguration struct {
+	//Format 格式：csv、xml、msgpack、cbor
+	Format string
+	//Operation 操作：encode(JSON转换成指定格式)、decode(指定格式转换成JSON)
+	Operation string
+	//Header csv第一行是否是列名，解码时读取列名，编码时写入列名
+	Header bool
+	//Columns csv列，多个与`,`隔开，可以使用`csv列名:JSON字段名`格式映射列名
+	//解码时如果配置了Columns，则只输出这些列；没有列名行时按位置命名，为空并且没有列名行则输出二维数组
+	//编码时按Columns的顺序输出列，为空则使用所有字段按名称排序
+	Columns string
+	//Delimiter csv分隔符，默认`,`
+	Delimiter string
+	//RootElement xml根元素名称，编码时使用该名称包装消息，解码时去掉根元素
+	RootElement string
+}
+
+// CodecNode 消息体格式转换节点，支持JSON和CSV、XML、MessagePack、CBOR互相转换
+// 编码后CSV、XML消息的dataType为TEXT，MessagePack、CBOR为BINARY；解码后消息的dataType为JSON
+// CSV解码结果为对象数组，值都是字符串；编码支持对象数组、二维数组和单个对象
+// XML解码时属性使用`@属性名`字段，同时有属性或者子元素的文本使用`#text`字段，重复的元素转换成数组，值都是字符串
+// 转换成功通过`Success`链发送到下一个节点，解析失败则发送到`Failure`链
+type CodecNode struct {
+	//节点配置
+	Config    CodecNodeConfiguration
+	columns   []csvColumn
+	delimiter rune
+}
+
+// csvColumn csv列和JSON字段的映射
+type csvColumn struct {
+	name  string
+	field string
+}
+
+// Type 组件类型
+func (x *CodecNode) Type() string {
+	return "codec"
+}
+
+func (x *CodecNode) New() types.Node {
+	return &CodecNode{Config: CodecNodeConfiguration{
+		Format:      CodecCsv,
+		Operation:   CodecDecode,
+		Header:      true,
+		Delimiter:   ",",
+		RootElement: "root",
+	}}
+}
+
+// Init 初始化
+func (x *CodecNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.Config); err != nil {
+		return err
+	}
+	switch x.Config.Format {
+	case CodecCsv, CodecXml, CodecMsgPack, CodecCbor:
+	default:
+		return fmt.Errorf("unsupported format: %s", x.Config.Format)
+	}
+	if x.Config.Operation != CodecEncode && x.Config.Operation != CodecDecode {
+		return fmt.Errorf("unsupported operation: %s", x.Config.Operation)
+	}
+	if x.Config.Delimiter == "" {
+		x.Config.Delimiter = ","
+	}
+	if utf8.RuneCountInString(x.Config.Delimiter) != 1 {
+		return fmt.Errorf("delimiter must be a single character")
+	}
+	x.delimiter, _ = utf8.DecodeRuneInString(x.Config.Delimiter)
+	if x.Config.RootElement == "" {
+		x.Config.RootElement = "root"
+	}
+	x.columns = nil
+	for _, item := range strings.Split(x.Config.Columns, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		column := csvColumn{name: item, field: item}
+		if i := strings.Index(item, ":"); i >= 0 {
+			column = csvColumn{name: strings.TrimSpace(item[:i]), field: strings.TrimSpace(item[i+1:])}
+		}
+		x.columns = append(x.columns, column)
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *CodecNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var err error
+	if x.Config.Operation == CodecEncode {
+		err = x.encode(&msg)
+	} else {
+		err = x.decode(&msg)
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else {
+		ctx.TellSuccess(msg)
+	}
+}
+
+// Destroy 销毁
+func (x *CodecNode) Destroy() {
+}
+
+// encode JSON转换成指定格式
+func (x *CodecNode) encode(msg *types.RuleMsg) error {
+	var data interface{}
+	if err := json.Unmarshal([]byte(msg.Data), &data); err != nil {
+		return err
+	}
+	var out []byte
+	var err error
+	dataType := types.BINARY
+	switch x.Config.Format {
+	case CodecCsv:
+		out, err = x.encodeCsv(data)
+		dataType = types.TEXT
+	case CodecXml:
+		out, err = encodeXml(x.Config.RootElement, data)
+		dataType = types.TEXT
+	case CodecMsgPack:
+		out, err = msgpack.Marshal(toCompactNumbers(data))
+	case CodecCbor:
+		out, err = cbor.Marshal(toCompactNumbers(data))
+	}
+	if err != nil {
+		return err
+	}
+	msg.Data = string(out)
+	msg.DataType = dataType
+	return nil
+}
+
+// decode 指定格式转换成JSON
+func (x *CodecNode) decode(msg *types.RuleMsg) error {
+	var data interface{}
+	var err error
+	switch x.Config.Format {
+	case CodecCsv:
+		data, err = x.decodeCsv(msg.Data)
+	case CodecXml:
+		data, err = decodeXml(msg.Data)
+	case CodecMsgPack:
+		err = msgpack.Unmarshal([]byte(msg.Data), &data)
+	case CodecCbor:
+		err = cbor.Unmarshal([]byte(msg.Data), &data)
+	}
+	if err != nil {
+		return err
+	}
+	out, err := json.Marshal(toJsonValue(data))
+	if err != nil {
+		return err
+	}
+	msg.Data = string(out)
+	msg.DataType = types.JSON
+	return nil
+}
+
+// decodeCsv csv转换成对象数组，没有列名行并且没有配置列时转换成二维数组
+func (x *CodecNode) decodeCsv(data string) (interface{}, error) {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.Comma = x.delimiter
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	//列序号对应的JSON字段名
+	var indexes []int
+	var fields []string
+	if x.Config.Header && len(records) > 0 {
+		header := records[0]
+		records = records[1:]
+		if len(x.columns) == 0 {
+			for i, name := range header {
+				indexes = append(indexes, i)
+				fields = append(fields, name)
+			}
+		} else {
+			for _, column := range x.columns {
+				for i, name := range header {
+					if name == column.name {
+						indexes = append(indexes, i)
+						fields = append(fields, column.field)
+						break
+					}
+				}
+			}
+		}
+	} else if len(x.columns) == 0 {
+		result := make([]interface{}, 0, len(records))
+		for _, record := range records {
+			row := make([]interface{}, 0, len(record))
+			for _, v := range record {
+				row = append(row, v)
+			}
+			result = append(result, row)
+		}
+		return result, nil
+	} else {
+		for i, column := range x.columns {
+			indexes = append(indexes, i)
+			fields = append(fields, column.field)
+		}
+	}
+	result := make([]interface{}, 0, len(records))
+	for _, record := range records {
+		row := make(map[string]interface{}, len(fields))
+		for j, i := range indexes {
+			if i < len(record) {
+				row[fields[j]] = record[i]
+			}
+		}
+		result = append(result, row)
+	}
+	return result, nil
+}
+
+// encodeCsv 对象数组、二维数组或者单个对象转换成csv
+func (x *CodecNode) encodeCsv(data interface{}) ([]byte, error) {
+	var rows []interface{}
+	switch v := data.(type) {
+	case []interface{}:
+		rows = v
+	case map[string]interface{}:
+		rows = []interface{}{v}
+	default:
+		return nil, fmt.Errorf("csv encode requires an array or object, but got %T", data)
+	}
+	columns := x.columns
+	if len(columns) == 0 {
+		//没有配置列，使用所有对象字段按名称排序
+		keys := make(map[string]struct{})
+		for _, row := range rows {
+			if item, ok := row.(map[string]interface{}); ok {
+				for k := range item {
+					keys[k] = struct{}{}
+				}
+			}
+		}
+		for k := range keys {
+			columns = append(columns, csvColumn{name: k, field: k})
+		}
+		sort.Slice(columns, func(i, j int) bool {
+			return columns[i].name < columns[j].name
+		})
+	}
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Comma = x.delimiter
+	if x.Config.Header && len(columns) > 0 {
+		header := make([]string, 0, len(columns))
+		for _, column := range columns {
+			header = append(header, column.name)
+		}
+		if err := writer.Write(header); err != nil {
+			return nil, err
+		}
+	}
+	for _, row := range rows {
+		var record []string
+		switch item := row.(type) {
+		case map[string]interface{}:
+			record = make([]string, 0, len(columns))
+			for _, column := range columns {
+				record = append(record, csvValue(item[column.field]))
+			}
+		case []interface{}:
+			record = make([]string, 0, len(item))
+			for _, v := range item {
+				record = append(record, csvValue(v))
+			}
+		default:
+			record = []string{csvValue(item)}
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// csvValue 转换成csv单元格的值，对象和数组使用JSON格式
+func csvValue(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return ""
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(v)
+		return string(b)
+	default:
+		return str.ToString(v)
+	}
+}
+
+// toCompactNumbers 把JSON解析出的整数float64转换成int64，使二进制格式编码更紧凑
+func toCompactNumbers(data interface{}) interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = toCompactNumbers(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = toCompactNumbers(item)
+		}
+		return v
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			return int64(v)
+		}
+		return v
+	default:
+		return data
+	}
+}
+
+// toJsonValue 把解码出的map[interface{}]interface{}转换成map[string]interface{}，以便转换成JSON
+func toJsonValue(data interface{}) interface{} {
+	switch v := data.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result[str.ToString(k)] = toJsonValue(item)
+		}
+		return result
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = toJsonValue(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = toJsonValue(item)
+		}
+		return v
+	default:
+		return data
+	}
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"testing"
+)
+
+func TestCodecNode(t *testing.T) {
+	var targetNodeType = "codec"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &CodecNode{}, types.Configuration{
+			"format":      CodecCsv,
+			"operation":   CodecDecode,
+			"header":      true,
+			"delimiter":   ",",
+			"rootElement": "root",
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"format":    CodecXml,
+			"operation": CodecEncode,
+			"delimiter": ";",
+		}, types.Configuration{
+			"format":    CodecXml,
+			"operation": CodecEncode,
+			"delimiter": ";",
+		}, Registry)
+
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"format": "yaml"}, Registry)
+		assert.Equal(t, "unsupported format: yaml", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"operation": "convert"}, Registry)
+		assert.Equal(t, "unsupported operation: convert", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"delimiter": "||"}, Registry)
+		assert.Equal(t, "delimiter must be a single character", err.Error())
+	})
+
+	t.Run("OnMsg", func(t *testing.T) {
+		var testcases = []struct {
+			configuration types.Configuration
+			data          string
+			expected      string
+			dataType      types.DataType
+		}{
+			{
+				configuration: types.Configuration{"format": CodecCsv, "operation": CodecDecode},
+				data:          "name,temp\naa,25\n\"b,b\",26\n",
+				expected:      `[{"name":"aa","temp":"25"},{"name":"b,b","temp":"26"}]`,
+				dataType:      types.JSON,
+			},
+			{
+				configuration: types.Configuration{"format": CodecCsv, "operation": CodecDecode, "columns": "temp:temperature"},
+				data:          "name,temp\naa,25\n",
+				expected:      `[{"temperature":"25"}]`,
+				dataType:      types.JSON,
+			},
+			{
+				configuration: types.Configuration{"format": CodecCsv, "operation": CodecDecode, "header": false, "columns": "name,temp", "delimiter": ";"},
+				data:          "aa;25\n",
+				expected:      `[{"name":"aa","temp":"25"}]`,
+				dataType:      types.JSON,
+			},
+			{
+				configuration: types.Configuration{"format": CodecCsv, "operation": CodecDecode, "header": false},
+				data:          "aa,25\nbb,26\n",
+				expected:      `[["aa","25"],["bb","26"]]`,
+				dataType:      types.JSON,
+			},
+			{
+				configuration: types.Configuration{"format": CodecCsv, "operation": CodecEncode},
+				data:          `[{"name":"aa","temp":25},{"name":"b,b","temp":26.5,"tags":["x"]}]`,
+				expected:      "name,tags,temp\naa,,25\n\"b,b\",\"[\"\"x\"\"]\",26.5\n",
+				dataType:      types.TEXT,
+			},
+			{
+				configuration: types.Configuration{"format": CodecCsv, "operation": CodecEncode, "columns": "device:name,temperature:temp"},
+				data:          `{"name":"aa","temp":25}`,
+				expected:      "device,temperature\naa,25\n",
+				dataType:      types.TEXT,
+			},
+			{
+				configuration: types.Configuration{"format": CodecXml, "operation": CodecDecode},
+				data:          `<?xml version="1.0"?><device id="1"><name>aa</name><sensor type="t">25</sensor><tag>x</tag><tag>y</tag><empty/></device>`,
+				expected:      `{"@id":"1","empty":"","name":"aa","sensor":{"#text":"25","@type":"t"},"tag":["x","y"]}`,
+				dataType:      types.JSON,
+			},
+			{
+				configuration: types.Configuration{"format": CodecXml, "operation": CodecEncode, "rootElement": "device"},
+				data:          `{"@id":"1","name":"a<b","sensor":{"#text":25,"@type":"t"},"tag":["x","y"]}`,
+				expected:      `<device id="1"><name>a&lt;b</name><sensor type="t">25</sensor><tag>x</tag><tag>y</tag></device>`,
+				dataType:      types.TEXT,
+			},
+			{
+				configuration: types.Configuration{"format": CodecXml, "operation": CodecEncode},
+				data:          `[1,2]`,
+				expected:      `<root><item>1</item><item>2</item></root>`,
+				dataType:      types.TEXT,
+			},
+		}
+		for _, item := range testcases {
+			node, err := test.CreateAndInitNode(targetNodeType, item.configuration, Registry)
+			assert.Nil(t, err)
+			test.NodeOnMsg(t, node, []test.Msg{
+				{MetaData: types.NewMetadata(), Data: item.data},
+			}, func(msg types.RuleMsg, relationType string, err error) {
+				assert.Equal(t, types.Success, relationType)
+				assert.Equal(t, item.expected, msg.Data)
+				assert.Equal(t, item.dataType, msg.DataType)
+			})
+		}
+	})
+
+	t.Run("Binary", func(t *testing.T) {
+		data := `{"name":"aa","nested":{"list":[1,2.5,"x",true,null]},"temp":25}`
+		for _, format := range []string{CodecMsgPack, CodecCbor} {
+			encoder, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"format": format, "operation": CodecEncode}, Registry)
+			assert.Nil(t, err)
+			decoder, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"format": format, "operation": CodecDecode}, Registry)
+			assert.Nil(t, err)
+			var encoded string
+			test.NodeOnMsg(t, encoder, []test.Msg{
+				{MetaData: types.NewMetadata(), Data: data},
+			}, func(msg types.RuleMsg, relationType string, err error) {
+				assert.Equal(t, types.Success, relationType)
+				assert.Equal(t, types.BINARY, msg.DataType)
+				encoded = msg.Data
+			})
+			assert.True(t, len(encoded) < len(data))
+			test.NodeOnMsg(t, decoder, []test.Msg{
+				{MetaData: types.NewMetadata(), DataType: types.BINARY, Data: encoded},
+			}, func(msg types.RuleMsg, relationType string, err error) {
+				assert.Equal(t, types.Success, relationType)
+				assert.Equal(t, types.JSON, msg.DataType)
+				assert.Equal(t, data, msg.Data)
+			})
+		}
+	})
+
+	t.Run("OnMsgErr", func(t *testing.T) {
+		var testcases = []struct {
+			configuration types.Configuration
+			data          string
+		}{
+			{configuration: types.Configuration{"format": CodecCsv, "operation": CodecDecode}, data: "a,b\n\"c,d\n"},
+			{configuration: types.Configuration{"format": CodecCsv, "operation": CodecEncode}, data: `"aa"`},
+			{configuration: types.Configuration{"format": CodecXml, "operation": CodecDecode}, data: `<a><b></a>`},
+			{configuration: types.Configuration{"format": CodecXml, "operation": CodecDecode}, data: `not xml`},
+			{configuration: types.Configuration{"format": CodecMsgPack, "operation": CodecDecode}, data: "\xc1"},
+			{configuration: types.Configuration{"format": CodecCbor, "operation": CodecDecode}, data: "\xff"},
+			{configuration: types.Configuration{"format": CodecMsgPack, "operation": CodecEncode}, data: `{"a":`},
+		}
+		for _, item := range testcases {
+			node, err := test.CreateAndInitNode(targetNodeType, item.configuration, Registry)
+			assert.Nil(t, err)
+			test.NodeOnMsg(t, node, []test.Msg{
+				{MetaData: types.NewMetadata(), Data: item.data},
+			}, func(msg types.RuleMsg, relationType string, err error) {
+				assert.Equal(t, types.Failure, relationType)
+				assert.NotNil(t, err)
+				assert.Equal(t, item.data, msg.Data)
+			})
+		}
+	})
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"github.com/rulego/rulego/utils/str"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	//xmlAttrPrefix xml属性字段前缀
+	xmlAttrPrefix = "@"
+	//xmlTextKey xml元素文本字段
+	xmlTextKey = "#text"
+	//xmlArrayItem 根元素是数组时，数组元素的名称
+	xmlArrayItem = "item"
+)
+
+// decodeXml xml转换成map，去掉根元素
+func decodeXml(data string) (interface{}, error) {
+	decoder := xml.NewDecoder(strings.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil, errors.New("xml root element not found")
+		} else if err != nil {
+			return nil, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return decodeXmlElement(decoder, start)
+		}
+	}
+}
+
+// decodeXmlElement 解析元素，只有文本的元素转换成字符串
+func decodeXmlElement(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	result := make(map[string]interface{})
+	for _, attr := range start.Attr {
+		result[xmlAttrPrefix+attr.Name.Local] = attr.Value
+	}
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			child, err := decodeXmlElement(decoder, t)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			if old, ok := result[name]; !ok {
+				result[name] = child
+			} else if list, ok := old.([]interface{}); ok {
+				result[name] = append(list, child)
+			} else {
+				result[name] = []interface{}{old, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			value := strings.TrimSpace(text.String())
+			if len(result) == 0 {
+				return value, nil
+			}
+			if value != "" {
+				result[xmlTextKey] = value
+			}
+			return result, nil
+		}
+	}
+}
+
+// encodeXml 使用根元素包装数据并转换成xml
+func encodeXml(root string, data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := xml.NewEncoder(&buf)
+	var err error
+	if list, ok := data.([]interface{}); ok {
+		err = encodeXmlElement(encoder, root, map[string]interface{}{xmlArrayItem: list})
+	} else {
+		err = encodeXmlElement(encoder, root, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = encoder.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeXmlElement 编码元素，数组编码成多个同名元素
+func encodeXmlElement(encoder *xml.Encoder, name string, data interface{}) error {
+	if list, ok := data.([]interface{}); ok {
+		for _, item := range list {
+			if err := encodeXmlElement(encoder, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	obj, isObj := data.(map[string]interface{})
+	var keys []string
+	if isObj {
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if strings.HasPrefix(k, xmlAttrPrefix) {
+				start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: k[len(xmlAttrPrefix):]}, Value: str.ToString(obj[k])})
+			}
+		}
+	}
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+	if isObj {
+		if text, ok := obj[xmlTextKey]; ok {
+			if err := encoder.EncodeToken(xml.CharData(str.ToString(text))); err != nil {
+				return err
+			}
+		}
+		for _, k := range keys {
+			if k == xmlTextKey || strings.HasPrefix(k, xmlAttrPrefix) {
+				continue
+			}
+			if err := encodeXmlElement(encoder, k, obj[k]); err != nil {
+				return err
+			}
+		}
+	} else if data != nil {
+		if err := encoder.EncodeToken(xml.CharData(str.ToString(data))); err != nil {
+			return err
+		}
+	}
+	return encoder.EncodeToken(start.End())
+}
@@ -0,0 +1,279 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+//规则链节点配置示例：
+//{
+//	"id": "s2",
+//	"type": "compress",
+//	"name": "解压",
+//	"debugMode": false,
+//		"configuration": {
+//			"operation": "decompress",
+//			"algorithm": "gzip",
+//			"maxSize": 10485760
+//	}
+//}
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"io"
+	"unicode/utf8"
+)
+
+// 压缩操作
+const (
+	CompressOpCompress   = "compress"
+	CompressOpDecompress = "decompress"
+)
+
+// 压缩算法
+const (
+	CompressGzip   = "gzip"
+	CompressZlib   = "zlib"
+	CompressZstd   = "zstd"
+	CompressSnappy = "snappy"
+)
+
+// DefaultMaxDecompressSize 默认解压后最大字节数
+const DefaultMaxDecompressSize = 10 * 1024 * 1024
+
+func init() {
+	Registry.Add(&CompressNode{})
+}
+
+// CompressNodeConfiguration 节点配置
+type CompressNodeConfiguration struct {
+	//Operation 操作：compress(压缩)、decompress(解压)
+	Operation string
+	//Algorithm 算法：gzip、zlib、zstd、snappy
+	Algorithm string
+	//Level 压缩级别，0使用算法默认级别，gzip、zlib：1-9，zstd：1-4，snappy不支持
+	Level int
+	//MaxSize 最大字节数，压缩时限制输入，解压时限制输出，防止解压炸弹，<=0表示不限制，默认10M
+	MaxSize int64
+	//DataType 解压后消息的dataType，为空则自动识别：JSON、TEXT或者BINARY
+	DataType string
+}
+
+// CompressNode 压缩和解压节点，压缩后消息的dataType为BINARY
+// 成功通过`Success`链发送到下一个节点，解压失败或者超过最大字节数则发送到`Failure`链
+type CompressNode struct {
+	//节点配置
+	Config CompressNodeConfiguration
+	//zstd编解码器，EncodeAll、DecodeAll可以并发使用
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+}
+
+// Type 组件类型
+func (x *CompressNode) Type() string {
+	return "compress"
+}
+
+func (x *CompressNode) New() types.Node {
+	return &CompressNode{Config: CompressNodeConfiguration{
+		Operation: CompressOpCompress,
+		Algorithm: CompressGzip,
+		MaxSize:   DefaultMaxDecompressSize,
+	}}
+}
+
+// Init 初始化
+func (x *CompressNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.Config); err != nil {
+		return err
+	}
+	if x.Config.Operation != CompressOpCompress && x.Config.Operation != CompressOpDecompress {
+		return fmt.Errorf("unsupported operation: %s", x.Config.Operation)
+	}
+	switch x.Config.DataType {
+	case "", string(types.JSON), string(types.TEXT), string(types.BINARY):
+	default:
+		return fmt.Errorf("unsupported dataType: %s", x.Config.DataType)
+	}
+	level := x.Config.Level
+	switch x.Config.Algorithm {
+	case CompressGzip, CompressZlib:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+			return fmt.Errorf("invalid compression level: %d", x.Config.Level)
+		}
+		x.Config.Level = level
+	case CompressZstd:
+		var err error
+		if level == 0 {
+			level = int(zstd.SpeedDefault)
+		}
+		if level < int(zstd.SpeedFastest) || level > int(zstd.SpeedBestCompression) {
+			return fmt.Errorf("invalid compression level: %d", x.Config.Level)
+		}
+		x.Config.Level = level
+		if x.zstdEncoder, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevel(level))); err != nil {
+			return err
+		}
+		opts := []zstd.DOption{zstd.WithDecoderConcurrency(0)}
+		if x.Config.MaxSize > 0 {
+			opts = append(opts, zstd.WithDecoderMaxMemory(uint64(x.Config.MaxSize)))
+		}
+		if x.zstdDecoder, err = zstd.NewReader(nil, opts...); err != nil {
+			return err
+		}
+	case CompressSnappy:
+	default:
+		return fmt.Errorf("unsupported algorithm: %s", x.Config.Algorithm)
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *CompressNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var out []byte
+	var err error
+	if x.Config.Operation == CompressOpCompress {
+		out, err = x.compress([]byte(msg.Data))
+	} else {
+		out, err = x.decompress([]byte(msg.Data))
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	msg.Data = string(out)
+	if x.Config.Operation == CompressOpCompress {
+		msg.DataType = types.BINARY
+	} else if x.Config.DataType != "" {
+		msg.DataType = types.DataType(x.Config.DataType)
+	} else {
+		msg.DataType = detectDataType(out)
+	}
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *CompressNode) Destroy() {
+	if x.zstdEncoder != nil {
+		_ = x.zstdEncoder.Close()
+	}
+	if x.zstdDecoder != nil {
+		x.zstdDecoder.Close()
+	}
+}
+
+// compress 压缩
+func (x *CompressNode) compress(data []byte) ([]byte, error) {
+	if x.Config.MaxSize > 0 && int64(len(data)) > x.Config.MaxSize {
+		return nil, fmt.Errorf("max limit of compress size is %d", x.Config.MaxSize)
+	}
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	var err error
+	switch x.Config.Algorithm {
+	case CompressZstd:
+		return x.zstdEncoder.EncodeAll(data, nil), nil
+	case CompressSnappy:
+		return snappy.Encode(nil, data), nil
+	case CompressZlib:
+		writer, err = zlib.NewWriterLevel(&buf, x.Config.Level)
+	default:
+		writer, err = gzip.NewWriterLevel(&buf, x.Config.Level)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err = writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress 解压，超过MaxSize则返回错误
+func (x *CompressNode) decompress(data []byte) ([]byte, error) {
+	var reader io.Reader
+	switch x.Config.Algorithm {
+	case CompressSnappy:
+		//snappy头部包含解压后的长度，解压前先检查
+		n, err := snappy.DecodedLen(data)
+		if err != nil {
+			return nil, err
+		}
+		if x.Config.MaxSize > 0 && int64(n) > x.Config.MaxSize {
+			return nil, x.errMaxSize()
+		}
+		return snappy.Decode(nil, data)
+	case CompressZstd:
+		out, err := x.zstdDecoder.DecodeAll(data, nil)
+		if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+			return nil, x.errMaxSize()
+		}
+		return out, err
+	case CompressZlib:
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		reader = zr
+	default:
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		reader = gr
+	}
+	if x.Config.MaxSize > 0 {
+		reader = io.LimitReader(reader, x.Config.MaxSize+1)
+	}
+	out, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if x.Config.MaxSize > 0 && int64(len(out)) > x.Config.MaxSize {
+		return nil, x.errMaxSize()
+	}
+	return out, nil
+}
+
+func (x *CompressNode) errMaxSize() error {
+	return fmt.Errorf("max limit of decompress size is %d", x.Config.MaxSize)
+}
+
+// detectDataType 识别数据类型
+func detectDataType(data []byte) types.DataType {
+	var v interface{}
+	if json.Unmarshal(data, &v) == nil {
+		return types.JSON
+	}
+	if utf8.Valid(data) {
+		return types.TEXT
+	}
+	return types.BINARY
+}
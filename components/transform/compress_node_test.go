@@ -0,0 +1,146 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"strings"
+	"testing"
+)
+
+// compressData 使用节点压缩或者解压数据，返回结果消息
+func compressData(t *testing.T, configuration types.Configuration, data string) (types.RuleMsg, string, error) {
+	node, err := test.CreateAndInitNode("compress", configuration, Registry)
+	assert.Nil(t, err)
+	var result types.RuleMsg
+	var relation string
+	var resultErr error
+	test.NodeOnMsg(t, node, []test.Msg{
+		{MetaData: types.NewMetadata(), Data: data},
+	}, func(msg types.RuleMsg, relationType string, err error) {
+		result, relation, resultErr = msg, relationType, err
+	})
+	return result, relation, resultErr
+}
+
+func TestCompressNode(t *testing.T) {
+	var targetNodeType = "compress"
+	algorithms := []string{CompressGzip, CompressZlib, CompressZstd, CompressSnappy}
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &CompressNode{}, types.Configuration{
+			"operation": CompressOpCompress,
+			"algorithm": CompressGzip,
+			"maxSize":   int64(DefaultMaxDecompressSize),
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"operation": CompressOpDecompress,
+			"algorithm": CompressZstd,
+			"level":     3,
+			"maxSize":   1024,
+			"dataType":  "TEXT",
+		}, types.Configuration{
+			"operation": CompressOpDecompress,
+			"algorithm": CompressZstd,
+			"level":     3,
+			"maxSize":   int64(1024),
+			"dataType":  "TEXT",
+		}, Registry)
+
+		var testcases = []struct {
+			configuration types.Configuration
+			err           string
+		}{
+			{configuration: types.Configuration{"operation": "zip"}, err: "unsupported operation: zip"},
+			{configuration: types.Configuration{"algorithm": "lz4"}, err: "unsupported algorithm: lz4"},
+			{configuration: types.Configuration{"algorithm": CompressGzip, "level": 10}, err: "invalid compression level: 10"},
+			{configuration: types.Configuration{"algorithm": CompressZstd, "level": 5}, err: "invalid compression level: 5"},
+			{configuration: types.Configuration{"dataType": "XML"}, err: "unsupported dataType: XML"},
+		}
+		for _, item := range testcases {
+			_, err := test.CreateAndInitNode(targetNodeType, item.configuration, Registry)
+			assert.Equal(t, item.err, err.Error())
+		}
+	})
+
+	t.Run("OnMsg", func(t *testing.T) {
+		jsonData := `{"name":"aa","values":[` + strings.Repeat(`1,2,3,`, 100) + `4]}`
+		var testcases = []struct {
+			data     string
+			config   types.Configuration
+			dataType types.DataType
+		}{
+			{data: jsonData, dataType: types.JSON},
+			{data: strings.Repeat("hello ", 100), dataType: types.TEXT},
+			{data: strings.Repeat("\xff\x00\x01", 100), dataType: types.BINARY},
+			{data: jsonData, config: types.Configuration{"dataType": "TEXT"}, dataType: types.TEXT},
+		}
+		for _, algorithm := range algorithms {
+			for _, item := range testcases {
+				compressed, relationType, err := compressData(t, types.Configuration{"algorithm": algorithm}, item.data)
+				assert.Equal(t, types.Success, relationType)
+				assert.Nil(t, err)
+				assert.Equal(t, types.BINARY, compressed.DataType)
+				assert.True(t, len(compressed.Data) < len(item.data))
+
+				config := types.Configuration{"operation": CompressOpDecompress, "algorithm": algorithm}
+				for k, v := range item.config {
+					config[k] = v
+				}
+				msg, relationType, err := compressData(t, config, compressed.Data)
+				assert.Equal(t, types.Success, relationType)
+				assert.Nil(t, err)
+				assert.Equal(t, item.data, msg.Data)
+				assert.Equal(t, item.dataType, msg.DataType)
+			}
+		}
+	})
+
+	t.Run("MaxSize", func(t *testing.T) {
+		bomb := strings.Repeat("\x00", 1024*1024)
+		for _, algorithm := range algorithms {
+			_, relationType, err := compressData(t, types.Configuration{"algorithm": algorithm, "maxSize": 1024}, bomb)
+			assert.Equal(t, types.Failure, relationType)
+			assert.Equal(t, "max limit of compress size is 1024", err.Error())
+
+			compressed, relationType, _ := compressData(t, types.Configuration{"algorithm": algorithm, "maxSize": 0, "level": 0}, bomb)
+			assert.Equal(t, types.Success, relationType)
+
+			msg, relationType, err := compressData(t, types.Configuration{"operation": CompressOpDecompress, "algorithm": algorithm, "maxSize": 1024}, compressed.Data)
+			assert.Equal(t, types.Failure, relationType)
+			assert.Equal(t, "max limit of decompress size is 1024", err.Error())
+			assert.Equal(t, compressed.Data, msg.Data)
+
+			msg, relationType, err = compressData(t, types.Configuration{"operation": CompressOpDecompress, "algorithm": algorithm, "maxSize": -1}, compressed.Data)
+			assert.Equal(t, types.Success, relationType)
+			assert.Equal(t, len(bomb), len(msg.Data))
+		}
+	})
+
+	t.Run("OnMsgErr", func(t *testing.T) {
+		for _, algorithm := range algorithms {
+			_, relationType, err := compressData(t, types.Configuration{"operation": CompressOpDecompress, "algorithm": algorithm}, "not compressed data")
+			assert.Equal(t, types.Failure, relationType)
+			assert.NotNil(t, err)
+		}
+	})
+}
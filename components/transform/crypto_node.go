@@ -0,0 +1,429 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+//规则链节点配置示例：
+//{
+//	"id": "s2",
+//	"type": "crypto",
+//	"name": "加密",
+//	"debugMode": false,
+//		"configuration": {
+//			"operation": "encrypt",
+//			"algorithm": "AES-GCM",
+//			"key": "${global.aesKey}",
+//			"keyEncoding": "base64"
+//	}
+//}
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"hash"
+	"os"
+	"strings"
+)
+
+// 加密操作
+const (
+	CryptoEncrypt = "encrypt"
+	CryptoDecrypt = "decrypt"
+	CryptoSign    = "sign"
+	CryptoVerify  = "verify"
+	CryptoHash    = "hash"
+)
+
+// 加密算法
+const (
+	CryptoAesGcm     = "AES-GCM"
+	CryptoHmacSha256 = "HMAC-SHA256"
+	CryptoEd25519    = "Ed25519"
+	CryptoRsaSha256  = "RSA-SHA256"
+	CryptoSha256     = "SHA256"
+	CryptoMd5        = "MD5"
+)
+
+// 编码方式
+const (
+	CryptoEncodingRaw    = "raw"
+	CryptoEncodingHex    = "hex"
+	CryptoEncodingBase64 = "base64"
+)
+
+// DefaultSignatureKey 验签时签名所在的元数据key
+const DefaultSignatureKey = "signature"
+
+// ErrSignatureMismatch 验签失败
+var ErrSignatureMismatch = errors.New("signature verification failed")
+
+func init() {
+	Registry.Add(&CryptoNode{})
+}
+
+// CryptoNodeConfiguration 节点配置
+type CryptoNodeConfiguration struct {
+	//Operation 操作：encrypt、decrypt、sign、verify、hash
+	Operation string
+	//Algorithm 算法
+	//encrypt/decrypt：AES-GCM
+	//sign/verify：HMAC-SHA256、Ed25519、RSA-SHA256
+	//hash：SHA256、MD5
+	Algorithm string
+	//Key 密钥，可以使用 ${global.xx} 从全局属性获取
+	//AES-GCM、HMAC-SHA256 使用KeyEncoding解码，Ed25519、RSA-SHA256使用PEM格式
+	Key string
+	//KeyFile 密钥文件路径，Key为空时使用
+	KeyFile string
+	//KeyEncoding AES-GCM、HMAC-SHA256密钥编码：raw、hex、base64，默认raw，raw编码的密钥不会去除首尾空白字符
+	KeyEncoding string
+	//Field 处理的JSON字段，例如：payload.secret，为空则处理整个消息体
+	Field string
+	//Encoding 密文、签名和摘要的编码：hex、base64，默认base64
+	Encoding string
+	//MetadataKey 结果保存到元数据的key，为空则替换消息体
+	MetadataKey string
+	//SignatureKey 验签时签名所在的元数据key，默认signature
+	SignatureKey string
+}
+
+// CryptoNode 加解密、签名验签和摘要节点
+// AES-GCM 密文格式：nonce+密文，使用Encoding编码
+// 成功通过`Success`链发送到下一个节点，解密、验签失败或者其他错误则发送到`Failure`链
+type CryptoNode struct {
+	//节点配置
+	Config CryptoNodeConfiguration
+	//secret AES-GCM、HMAC-SHA256密钥
+	secret []byte
+	aead   cipher.AEAD
+	//privateKey Ed25519、RSA-SHA256私钥
+	privateKey crypto.Signer
+	//publicKey Ed25519、RSA-SHA256公钥
+	publicKey crypto.PublicKey
+}
+
+// Type 组件类型
+func (x *CryptoNode) Type() string {
+	return "crypto"
+}
+
+func (x *CryptoNode) New() types.Node {
+	return &CryptoNode{Config: CryptoNodeConfiguration{
+		Operation:    CryptoHash,
+		Algorithm:    CryptoSha256,
+		KeyEncoding:  CryptoEncodingRaw,
+		Encoding:     CryptoEncodingBase64,
+		SignatureKey: DefaultSignatureKey,
+	}}
+}
+
+// Init 初始化
+func (x *CryptoNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.Config); err != nil {
+		return err
+	}
+	if x.Config.Encoding != CryptoEncodingHex && x.Config.Encoding != CryptoEncodingBase64 {
+		return fmt.Errorf("unsupported encoding: %s", x.Config.Encoding)
+	}
+	if x.Config.SignatureKey == "" {
+		x.Config.SignatureKey = DefaultSignatureKey
+	}
+	switch x.Config.Operation {
+	case CryptoEncrypt, CryptoDecrypt:
+		if x.Config.Algorithm != CryptoAesGcm {
+			return fmt.Errorf("unsupported algorithm for %s: %s", x.Config.Operation, x.Config.Algorithm)
+		}
+	case CryptoSign, CryptoVerify:
+		if x.Config.Algorithm != CryptoHmacSha256 && x.Config.Algorithm != CryptoEd25519 && x.Config.Algorithm != CryptoRsaSha256 {
+			return fmt.Errorf("unsupported algorithm for %s: %s", x.Config.Operation, x.Config.Algorithm)
+		}
+	case CryptoHash:
+		if x.Config.Algorithm != CryptoSha256 && x.Config.Algorithm != CryptoMd5 {
+			return fmt.Errorf("unsupported algorithm for %s: %s", x.Config.Operation, x.Config.Algorithm)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported operation: %s", x.Config.Operation)
+	}
+	key, err := loadKey(x.Config.Key, x.Config.KeyFile)
+	if err != nil {
+		return err
+	}
+	switch x.Config.Algorithm {
+	case CryptoAesGcm:
+		if x.secret, err = decodeKey(key, x.Config.KeyEncoding); err != nil {
+			return err
+		}
+		block, err := aes.NewCipher(x.secret)
+		if err != nil {
+			return err
+		}
+		x.aead, err = cipher.NewGCM(block)
+		return err
+	case CryptoHmacSha256:
+		x.secret, err = decodeKey(key, x.Config.KeyEncoding)
+		return err
+	default:
+		return x.parsePemKey(key)
+	}
+}
+
+// OnMsg 处理消息
+func (x *CryptoNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	input, err := x.input(msg)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	var result string
+	switch x.Config.Operation {
+	case CryptoEncrypt:
+		nonce := make([]byte, x.aead.NonceSize())
+		if _, err = rand.Read(nonce); err == nil {
+			result = x.encode(x.aead.Seal(nonce, nonce, input, nil))
+		}
+	case CryptoDecrypt:
+		var plaintext []byte
+		if plaintext, err = x.decrypt(input); err == nil {
+			result = string(plaintext)
+		}
+	case CryptoSign:
+		var signature []byte
+		if signature, err = x.sign(input); err == nil {
+			result = x.encode(signature)
+		}
+	case CryptoVerify:
+		err = x.verify(input, msg.Metadata.GetValue(x.Config.SignatureKey))
+		if err == nil {
+			ctx.TellSuccess(msg)
+			return
+		}
+	case CryptoHash:
+		var h hash.Hash
+		if x.Config.Algorithm == CryptoMd5 {
+			h = md5.New()
+		} else {
+			h = sha256.New()
+		}
+		h.Write(input)
+		result = x.encode(h.Sum(nil))
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	if x.Config.MetadataKey != "" {
+		msg.Metadata.PutValue(x.Config.MetadataKey, result)
+	} else {
+		msg.Data = result
+		msg.DataType = types.TEXT
+		if x.Config.Operation == CryptoDecrypt {
+			//解密结果是JSON则恢复JSON类型
+			var v interface{}
+			if json.Unmarshal([]byte(result), &v) == nil {
+				msg.DataType = types.JSON
+			}
+		}
+	}
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *CryptoNode) Destroy() {
+}
+
+// loadKey 获取密钥，key为空则读取keyFile
+// key中的 ${global.xx} 变量在节点配置加载时已经替换，这里不再替换，避免密钥本身包含 ${ 时被修改
+func loadKey(key, keyFile string) (string, error) {
+	if key == "" && keyFile != "" {
+		buf, err := os.ReadFile(keyFile)
+		if err != nil {
+			return "", err
+		}
+		key = string(buf)
+	}
+	if strings.TrimSpace(key) == "" {
+		return "", errors.New("key is empty")
+	}
+	return key, nil
+}
+
+// parsePemKey 解析PEM格式的私钥或者公钥
+func (x *CryptoNode) parsePemKey(key string) error {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return errors.New("invalid PEM key")
+	}
+	var parsed interface{}
+	var err error
+	if strings.Contains(block.Type, "PRIVATE KEY") {
+		if parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+			if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+				return err
+			}
+		}
+	} else {
+		if parsed, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			if parsed, err = x509.ParsePKCS1PublicKey(block.Bytes); err != nil {
+				return err
+			}
+		}
+	}
+	switch k := parsed.(type) {
+	case ed25519.PrivateKey:
+		x.privateKey, x.publicKey = k, k.Public()
+	case *rsa.PrivateKey:
+		x.privateKey, x.publicKey = k, k.Public()
+	case ed25519.PublicKey, *rsa.PublicKey:
+		x.publicKey = k
+	default:
+		return fmt.Errorf("unsupported key type: %T", parsed)
+	}
+	_, isEd25519 := x.publicKey.(ed25519.PublicKey)
+	if isEd25519 != (x.Config.Algorithm == CryptoEd25519) {
+		return fmt.Errorf("key type %T does not match algorithm %s", parsed, x.Config.Algorithm)
+	}
+	if x.Config.Operation == CryptoSign && x.privateKey == nil {
+		return errors.New("sign requires a private key")
+	}
+	return nil
+}
+
+// input 获取需要处理的数据
+func (x *CryptoNode) input(msg types.RuleMsg) ([]byte, error) {
+	var input []byte
+	if x.Config.Field == "" {
+		input = []byte(msg.Data)
+	} else {
+		var data interface{}
+		if err := json.Unmarshal([]byte(msg.Data), &data); err != nil {
+			return nil, err
+		}
+		value := maps.Get(data, x.Config.Field)
+		if value == nil {
+			return nil, fmt.Errorf("field %s not found", x.Config.Field)
+		}
+		if v, ok := value.(string); ok {
+			input = []byte(v)
+		} else {
+			var err error
+			if input, err = json.Marshal(value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if x.Config.Operation == CryptoDecrypt {
+		return x.decode(string(input))
+	}
+	return input, nil
+}
+
+// decrypt AES-GCM解密
+func (x *CryptoNode) decrypt(data []byte) ([]byte, error) {
+	nonceSize := x.aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, errors.New("ciphertext too short")
+	}
+	return x.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+}
+
+// sign 签名
+func (x *CryptoNode) sign(data []byte) ([]byte, error) {
+	switch x.Config.Algorithm {
+	case CryptoHmacSha256:
+		mac := hmac.New(sha256.New, x.secret)
+		mac.Write(data)
+		return mac.Sum(nil), nil
+	case CryptoEd25519:
+		return x.privateKey.Sign(rand.Reader, data, crypto.Hash(0))
+	default:
+		digest := sha256.Sum256(data)
+		return x.privateKey.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+}
+
+// verify 验签
+func (x *CryptoNode) verify(data []byte, signature string) error {
+	if signature == "" {
+		return fmt.Errorf("metadata %s is empty", x.Config.SignatureKey)
+	}
+	sig, err := x.decode(signature)
+	if err != nil {
+		return err
+	}
+	var ok bool
+	switch x.Config.Algorithm {
+	case CryptoHmacSha256:
+		mac := hmac.New(sha256.New, x.secret)
+		mac.Write(data)
+		ok = hmac.Equal(mac.Sum(nil), sig)
+	case CryptoEd25519:
+		ok = ed25519.Verify(x.publicKey.(ed25519.PublicKey), data, sig)
+	default:
+		digest := sha256.Sum256(data)
+		ok = rsa.VerifyPKCS1v15(x.publicKey.(*rsa.PublicKey), crypto.SHA256, digest[:], sig) == nil
+	}
+	if !ok {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
+
+// encode 使用Encoding编码
+func (x *CryptoNode) encode(data []byte) string {
+	if x.Config.Encoding == CryptoEncodingHex {
+		return hex.EncodeToString(data)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// decode 使用Encoding解码
+func (x *CryptoNode) decode(data string) ([]byte, error) {
+	data = strings.TrimSpace(data)
+	if x.Config.Encoding == CryptoEncodingHex {
+		return hex.DecodeString(data)
+	}
+	return base64.StdEncoding.DecodeString(data)
+}
+
+// decodeKey 按编码方式解码密钥，只有hex、base64编码去除首尾空白字符
+func decodeKey(key, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", CryptoEncodingRaw:
+		return []byte(key), nil
+	case CryptoEncodingHex:
+		return hex.DecodeString(strings.TrimSpace(key))
+	case CryptoEncodingBase64:
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	default:
+		return nil, fmt.Errorf("unsupported key encoding: %s", encoding)
+	}
+}
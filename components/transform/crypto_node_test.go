@@ -0,0 +1,285 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+import (
+	"crypto/ed25519"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// pemKey 生成PEM格式的私钥和公钥
+func pemKey(t *testing.T, privateKey interface{}, publicKey interface{}) (string, string) {
+	privateBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	assert.Nil(t, err)
+	publicBytes, err := x509.MarshalPKIXPublicKey(publicKey)
+	assert.Nil(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateBytes})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicBytes}))
+}
+
+func TestCryptoNode(t *testing.T) {
+	var targetNodeType = "crypto"
+	aesKey := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	data := `{"name":"aa","secret":{"password":"123456"}}`
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &CryptoNode{}, types.Configuration{
+			"operation":    CryptoHash,
+			"algorithm":    CryptoSha256,
+			"keyEncoding":  CryptoEncodingRaw,
+			"encoding":     CryptoEncodingBase64,
+			"signatureKey": DefaultSignatureKey,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"operation":   CryptoEncrypt,
+			"algorithm":   CryptoAesGcm,
+			"key":         aesKey,
+			"keyEncoding": CryptoEncodingBase64,
+			"encoding":    CryptoEncodingHex,
+			"metadataKey": "cipher",
+		}, types.Configuration{
+			"operation":   CryptoEncrypt,
+			"algorithm":   CryptoAesGcm,
+			"key":         aesKey,
+			"keyEncoding": CryptoEncodingBase64,
+			"encoding":    CryptoEncodingHex,
+			"metadataKey": "cipher",
+		}, Registry)
+
+		var testcases = []struct {
+			configuration types.Configuration
+			err           string
+		}{
+			{configuration: types.Configuration{"operation": "compress"}, err: "unsupported operation: compress"},
+			{configuration: types.Configuration{"encoding": "base32"}, err: "unsupported encoding: base32"},
+			{configuration: types.Configuration{"operation": CryptoHash, "algorithm": CryptoAesGcm}, err: "unsupported algorithm for hash: AES-GCM"},
+			{configuration: types.Configuration{"operation": CryptoEncrypt, "algorithm": CryptoAesGcm}, err: "key is empty"},
+			{configuration: types.Configuration{"operation": CryptoEncrypt, "algorithm": CryptoAesGcm, "key": "short"}, err: "crypto/aes: invalid key size 5"},
+			{configuration: types.Configuration{"operation": CryptoSign, "algorithm": CryptoHmacSha256, "key": "aa", "keyEncoding": "base32"}, err: "unsupported key encoding: base32"},
+			{configuration: types.Configuration{"operation": CryptoSign, "algorithm": CryptoEd25519, "key": "aa"}, err: "invalid PEM key"},
+		}
+		for _, item := range testcases {
+			_, err := test.CreateAndInitNode(targetNodeType, item.configuration, Registry)
+			assert.Equal(t, item.err, err.Error())
+		}
+	})
+
+	t.Run("Hash", func(t *testing.T) {
+		sha := sha256.Sum256([]byte(data))
+		md := md5.Sum([]byte("123456"))
+		var testcases = []struct {
+			configuration types.Configuration
+			expected      string
+		}{
+			{configuration: types.Configuration{"algorithm": CryptoSha256}, expected: base64.StdEncoding.EncodeToString(sha[:])},
+			{configuration: types.Configuration{"algorithm": CryptoMd5, "field": "secret.password", "encoding": CryptoEncodingHex}, expected: hex.EncodeToString(md[:])},
+		}
+		for _, item := range testcases {
+			node, err := test.CreateAndInitNode(targetNodeType, item.configuration, Registry)
+			assert.Nil(t, err)
+			test.NodeOnMsg(t, node, []test.Msg{
+				{MetaData: types.NewMetadata(), DataType: types.JSON, Data: data},
+			}, func(msg types.RuleMsg, relationType string, err error) {
+				assert.Equal(t, types.Success, relationType)
+				assert.Equal(t, item.expected, msg.Data)
+				assert.Equal(t, types.TEXT, msg.DataType)
+			})
+		}
+	})
+
+	t.Run("EncryptDecrypt", func(t *testing.T) {
+		//base64编码的密钥去除首尾空白字符
+		encryptNode, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"operation":   CryptoEncrypt,
+			"algorithm":   CryptoAesGcm,
+			"key":         aesKey + "\n",
+			"keyEncoding": CryptoEncodingBase64,
+		}, Registry)
+		assert.Nil(t, err)
+		decryptNode, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"operation":   CryptoDecrypt,
+			"algorithm":   CryptoAesGcm,
+			"key":         aesKey,
+			"keyEncoding": CryptoEncodingBase64,
+		}, Registry)
+		assert.Nil(t, err)
+
+		var encrypted string
+		test.NodeOnMsg(t, encryptNode, []test.Msg{
+			{MetaData: types.NewMetadata(), DataType: types.JSON, Data: data},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Success, relationType)
+			assert.Equal(t, types.TEXT, msg.DataType)
+			assert.True(t, msg.Data != data)
+			encrypted = msg.Data
+		})
+		test.NodeOnMsg(t, decryptNode, []test.Msg{
+			{MetaData: types.NewMetadata(), DataType: types.TEXT, Data: encrypted},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Success, relationType)
+			assert.Equal(t, types.JSON, msg.DataType)
+			assert.Equal(t, data, msg.Data)
+		})
+
+		//篡改密文
+		tampered, _ := base64.StdEncoding.DecodeString(encrypted)
+		tampered[len(tampered)-1] ^= 1
+		test.NodeOnMsg(t, decryptNode, []test.Msg{
+			{MetaData: types.NewMetadata(), DataType: types.TEXT, Data: base64.StdEncoding.EncodeToString(tampered)},
+			{MetaData: types.NewMetadata(), DataType: types.TEXT, Data: "YWE="},
+			{MetaData: types.NewMetadata(), DataType: types.TEXT, Data: "not base64"},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Failure, relationType)
+			assert.NotNil(t, err)
+		})
+
+		//${global.xx}变量已经在节点配置加载时替换，密钥中的变量不会再次替换
+		config := types.NewConfig()
+		config.Properties.PutValue("aesKey", aesKey)
+		rawKeyNode := (&CryptoNode{}).New().(*CryptoNode)
+		err = rawKeyNode.Init(config, types.Configuration{
+			"operation": CryptoEncrypt,
+			"algorithm": CryptoAesGcm,
+			"key":       "${global.aesKey}",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "${global.aesKey}", string(rawKeyNode.secret))
+		//raw编码的密钥不去除空白字符
+		rawKeyNode = (&CryptoNode{}).New().(*CryptoNode)
+		err = rawKeyNode.Init(config, types.Configuration{
+			"operation": CryptoSign,
+			"algorithm": CryptoHmacSha256,
+			"key":       " secret ",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, " secret ", string(rawKeyNode.secret))
+
+		//加密字段，结果保存到元数据
+		fieldNode, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"operation":   CryptoEncrypt,
+			"algorithm":   CryptoAesGcm,
+			"key":         aesKey,
+			"keyEncoding": CryptoEncodingBase64,
+			"field":       "secret",
+			"metadataKey": "secret",
+		}, Registry)
+		assert.Nil(t, err)
+		test.NodeOnMsg(t, fieldNode, []test.Msg{
+			{MetaData: types.NewMetadata(), DataType: types.JSON, Data: data},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Success, relationType)
+			assert.Equal(t, data, msg.Data)
+			assert.True(t, msg.Metadata.GetValue("secret") != "")
+		})
+	})
+
+	t.Run("SignVerify", func(t *testing.T) {
+		edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+		assert.Nil(t, err)
+		edPrivatePem, edPublicPem := pemKey(t, edPrivate, edPublic)
+		rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.Nil(t, err)
+		rsaPrivatePem, rsaPublicPem := pemKey(t, rsaPrivate, &rsaPrivate.PublicKey)
+		publicKeyFile := filepath.Join(t.TempDir(), "rsa_public.pem")
+		assert.Nil(t, os.WriteFile(publicKeyFile, []byte(rsaPublicPem), 0600))
+
+		var testcases = []struct {
+			algorithm string
+			signKey   types.Configuration
+			verifyKey types.Configuration
+		}{
+			{algorithm: CryptoHmacSha256, signKey: types.Configuration{"key": "secret"}, verifyKey: types.Configuration{"key": "secret"}},
+			{algorithm: CryptoEd25519, signKey: types.Configuration{"key": edPrivatePem}, verifyKey: types.Configuration{"key": edPublicPem}},
+			{algorithm: CryptoRsaSha256, signKey: types.Configuration{"key": rsaPrivatePem}, verifyKey: types.Configuration{"keyFile": publicKeyFile}},
+		}
+		for _, item := range testcases {
+			signConfig := types.Configuration{"operation": CryptoSign, "algorithm": item.algorithm, "metadataKey": DefaultSignatureKey}
+			verifyConfig := types.Configuration{"operation": CryptoVerify, "algorithm": item.algorithm}
+			for k, v := range item.signKey {
+				signConfig[k] = v
+			}
+			for k, v := range item.verifyKey {
+				verifyConfig[k] = v
+			}
+			signNode, err := test.CreateAndInitNode(targetNodeType, signConfig, Registry)
+			assert.Nil(t, err)
+			verifyNode, err := test.CreateAndInitNode(targetNodeType, verifyConfig, Registry)
+			assert.Nil(t, err)
+
+			metaData := types.NewMetadata()
+			test.NodeOnMsg(t, signNode, []test.Msg{
+				{MetaData: metaData, DataType: types.JSON, Data: data},
+			}, func(msg types.RuleMsg, relationType string, err error) {
+				assert.Equal(t, types.Success, relationType)
+				assert.Equal(t, data, msg.Data)
+				metaData = msg.Metadata
+			})
+			assert.True(t, metaData.GetValue(DefaultSignatureKey) != "")
+			test.NodeOnMsg(t, verifyNode, []test.Msg{
+				{MetaData: metaData, DataType: types.JSON, Data: data},
+			}, func(msg types.RuleMsg, relationType string, err error) {
+				assert.Equal(t, types.Success, relationType)
+				assert.Equal(t, data, msg.Data)
+			})
+			test.NodeOnMsg(t, verifyNode, []test.Msg{
+				{MetaData: metaData, DataType: types.JSON, Data: `{"name":"bb"}`},
+			}, func(msg types.RuleMsg, relationType string, err error) {
+				assert.Equal(t, types.Failure, relationType)
+				assert.Equal(t, ErrSignatureMismatch, err)
+			})
+			test.NodeOnMsg(t, verifyNode, []test.Msg{
+				{MetaData: types.NewMetadata(), DataType: types.JSON, Data: data},
+			}, func(msg types.RuleMsg, relationType string, err error) {
+				assert.Equal(t, types.Failure, relationType)
+				assert.Equal(t, "metadata signature is empty", err.Error())
+			})
+		}
+
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"operation": CryptoSign, "algorithm": CryptoEd25519, "key": edPublicPem}, Registry)
+		assert.Equal(t, "sign requires a private key", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"operation": CryptoVerify, "algorithm": CryptoEd25519, "key": rsaPublicPem}, Registry)
+		assert.Equal(t, "key type *rsa.PublicKey does not match algorithm Ed25519", err.Error())
+	})
+
+	t.Run("FieldNotFound", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"field": "notFound"}, Registry)
+		assert.Nil(t, err)
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: types.NewMetadata(), DataType: types.JSON, Data: data},
+			{MetaData: types.NewMetadata(), DataType: types.TEXT, Data: "aa"},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Failure, relationType)
+			assert.NotNil(t, err)
+		})
+	})
+}
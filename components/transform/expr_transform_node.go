@@ -32,9 +32,9 @@ package transform
 //	}
 //}
 import (
-	"github.com/expr-lang/expr"
 	"github.com/expr-lang/expr/vm"
 	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/expr"
 	"github.com/rulego/rulego/utils/json"
 	"github.com/rulego/rulego/utils/maps"
 	"github.com/rulego/rulego/utils/str"
@@ -78,9 +78,12 @@ type ExprTransformNodeConfiguration struct {
 // 通过`metadata`变量访问消息元数据。例如 `metadata.customerName`
 // 通过`msgType`变量访问消息类型
 // 通过`dataType`变量访问数据类型
+// 可以使用内置函数库`expr.Builtins`，例如：`formatTime(nowMilli(), 'yyyy-MM-dd')`
+// 也可以调用`Config.Udf`注册的go函数，例如：`add(msg.a, msg.b)`
 type ExprTransformNode struct {
 	//节点配置
 	Config         ExprTransformNodeConfiguration
+	udf            map[string]interface{}
 	program        *vm.Program
 	programMapping map[string]*vm.Program
 }
@@ -98,8 +101,9 @@ func (x *ExprTransformNode) New() types.Node {
 func (x *ExprTransformNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
 	err := maps.Map2Struct(configuration, &x.Config)
 	if err == nil {
+		x.udf = expr.UdfEnv(ruleConfig)
 		if exprV := strings.TrimSpace(x.Config.Expr); exprV != "" {
-			if program, err := expr.Compile(exprV, expr.AllowUndefinedVariables()); err != nil {
+			if program, err := expr.Compile(ruleConfig, exprV); err != nil {
 				return err
 			} else {
 				x.program = program
@@ -107,7 +111,7 @@ func (x *ExprTransformNode) Init(ruleConfig types.Config, configuration types.Co
 		} else {
 			x.programMapping = make(map[string]*vm.Program)
 			for k, v := range x.Config.Mapping {
-				if program, err := expr.Compile(v, expr.AllowUndefinedVariables()); err != nil {
+				if program, err := expr.Compile(ruleConfig, v); err != nil {
 					return err
 				} else {
 					x.programMapping[k] = program
@@ -128,11 +132,7 @@ func (x *ExprTransformNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 			data = dataMap
 		}
 	}
-	var evn = make(map[string]interface{})
-	evn[types.MsgKey] = data
-	evn[types.MetadataKey] = msg.Metadata
-	evn[types.MsgTypeKey] = msg.Type
-	evn[types.DataTypeKey] = msg.DataType
+	evn := expr.NewEnvWithContext(ctx, x.udf, msg, data)
 
 	var result interface{}
 	var exprVm = vm.VM{}
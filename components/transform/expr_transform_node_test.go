@@ -188,4 +188,29 @@ func TestExprTransformNode(t *testing.T) {
 		}
 		time.Sleep(time.Millisecond * 20)
 	})
+
+	t.Run("OnMsgWithUdf", func(t *testing.T) {
+		config := types.NewConfig()
+		config.RegisterUdf("add", func(a, b float64) float64 {
+			return a + b
+		})
+		node := &ExprTransformNode{}
+		err := node.Init(config, types.Configuration{
+			"mapping": map[string]string{
+				"total": "add(msg.temperature, msg.humidity)",
+				"name":  "padLeft(msg.name, 4, '0')",
+			},
+		})
+		assert.Nil(t, err)
+		msgList := []test.Msg{{
+			MetaData:   types.NewMetadata(),
+			MsgType:    "ACTIVITY_EVENT",
+			Data:       "{\"name\":\"aa\",\"temperature\":60,\"humidity\":30}",
+			AfterSleep: time.Millisecond * 200,
+		}}
+		test.NodeOnMsg(t, node, msgList, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Success, relationType)
+			assert.Equal(t, "{\"name\":\"00aa\",\"total\":90}", msg.Data)
+		})
+	})
 }
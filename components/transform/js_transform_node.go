@@ -28,7 +28,6 @@ package transform
 //      }
 import (
 	"errors"
-	"fmt"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/components/js"
 	"github.com/rulego/rulego/utils/json"
@@ -50,7 +49,8 @@ type JsTransformNodeConfiguration struct {
 	//完整脚本函数：
 	//function Transform(msg, metadata, msgType) { ${JsScript} }
 	//return {'msg':msg,'metadata':metadata,'msgType':msgType};
-	JsScript string
+	//可以使用内置函数库，参考：`js.Builtins`
+	JsScript string `desc:"转换函数体：function Transform(msg, metadata, msgType) {...}，返回{'msg':msg,'metadata':metadata,'msgType':msgType}。内置函数库：$encoding、$crypto、$uuid、$time"`
 }
 
 // JsTransformNode 使用JavaScript更改消息metadata，msg或msgType
@@ -59,11 +59,16 @@ type JsTransformNodeConfiguration struct {
 // msg:是消息的payload
 // msgType:是消息的 type
 // 法返回结构:return {'msg':msg,'metadata':metadata,'msgType':msgType};
+// 脚本可以通过`$ctx`对象回调规则引擎，例如：
+// $ctx.tellNext({'msg':msg,'metadata':metadata,'msgType':msgType}, 'Other') 发送新消息到指定关系的下一个节点
+// var result = await $ctx.call('functionName', msg) 调用`action.Functions`注册的函数，并等待处理结果
 // 脚本执行成功，发送信息到`Success`链, 否则发到`Failure`链。
 type JsTransformNode struct {
 	//节点配置
 	Config   JsTransformNodeConfiguration
 	jsEngine types.JsEngine
+	//withContext 脚本是否使用`$ctx`
+	withContext bool
 }
 
 // Type 组件类型
@@ -81,7 +86,8 @@ func (x *JsTransformNode) New() types.Node {
 func (x *JsTransformNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
 	err := maps.Map2Struct(configuration, &x.Config)
 	if err == nil {
-		jsScript := fmt.Sprintf("function Transform(msg, metadata, msgType) { %s }", x.Config.JsScript)
+		var jsScript string
+		jsScript, x.withContext = js.WrapFunction("Transform", "msg, metadata, msgType", x.Config.JsScript)
 		x.jsEngine, err = js.NewGojaJsEngine(ruleConfig, jsScript, nil)
 	}
 	return err
@@ -98,7 +104,7 @@ func (x *JsTransformNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 			data = make(map[string]interface{})
 		}
 	}
-	out, err := x.jsEngine.Execute("Transform", data, msg.Metadata.Values(), msg.Type)
+	out, err := js.Execute(x.jsEngine, x.withContext, ctx, msg, "Transform", data, msg.Metadata.Values(), msg.Type)
 	if err != nil {
 		ctx.TellFailure(msg, err)
 	} else {
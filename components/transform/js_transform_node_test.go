@@ -18,8 +18,10 @@ package transform
 
 import (
 	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/components/action"
 	"github.com/rulego/rulego/test"
 	"github.com/rulego/rulego/test/assert"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -114,4 +116,40 @@ func TestJsTransformNode(t *testing.T) {
 			assert.Equal(t, types.Failure, relationType)
 		})
 	})
+
+	t.Run("OnMsgWithContext", func(t *testing.T) {
+		action.Functions.Register("jsTransformTest", func(ctx types.RuleContext, msg types.RuleMsg) {
+			msg.Metadata.PutValue("fromFunction", "true")
+			ctx.TellSuccess(msg)
+		})
+		defer action.Functions.UnRegister("jsTransformTest")
+
+		node := &JsTransformNode{}
+		err := node.Init(types.NewConfig(types.WithHostFunctions(action.Functions)), types.Configuration{
+			"jsScript": "$ctx.tellNext({'msg':msg,'msgType':'COPY'}, 'Copy');var result = await $ctx.call('jsTransformTest', msg);return {'msg':result.msg,'metadata':result.metadata,'msgType':msgType};",
+		})
+		assert.Nil(t, err)
+		metaData := types.BuildMetadata(make(map[string]string))
+		metaData.PutValue("productType", "test")
+		var msgList = []test.Msg{
+			{
+				MetaData:   metaData,
+				MsgType:    "ACTIVITY_EVENT",
+				Data:       "{\"name\":\"lala\"}",
+				AfterSleep: time.Millisecond * 200,
+			},
+		}
+		var count int32
+		test.NodeOnMsg(t, node, msgList, func(msg types.RuleMsg, relationType string, err2 error) {
+			atomic.AddInt32(&count, 1)
+			if relationType == "Copy" {
+				assert.Equal(t, "COPY", msg.Type)
+			} else {
+				assert.Equal(t, types.Success, relationType)
+				assert.Equal(t, "true", msg.Metadata.GetValue("fromFunction"))
+				assert.Equal(t, "{\"name\":\"lala\"}", msg.Data)
+			}
+		})
+		assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+	})
 }
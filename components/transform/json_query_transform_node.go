@@ -0,0 +1,133 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+//规则链节点配置示例：
+//{
+//	"id": "s2",
+//	"type": "jsonQueryTransform",
+//	"name": "JSON查询转换",
+//	"debugMode": false,
+//		"configuration": {
+//			"query": "{deviceId: metadata.deviceId, hot: msg.sensors[?temperature > `50`].name, avg: avg(msg.sensors[*].temperature)}"
+//	}
+//}
+import (
+	"fmt"
+	"github.com/jmespath/go-jmespath"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"strings"
+)
+
+func init() {
+	Registry.Add(&JsonQueryTransformNode{})
+}
+
+// JsonQueryTransformNodeConfiguration 节点配置
+type JsonQueryTransformNodeConfiguration struct {
+	//Query JMESPath查询表达式，支持过滤、投影、数组切片、聚合函数等，参考：https://jmespath.org/specification.html
+	Query string
+	//MetadataKey 为空则查询结果替换消息体，否则把查询结果保存到metadata该key，消息体保持不变
+	MetadataKey string
+}
+
+// JsonQueryTransformNode 使用JMESPath表达式查询或者重组消息，表达式在初始化时编译
+// 查询的文档结构：{"msg":消息体,"metadata":元数据,"msgType":消息类型}，例如：
+//
+//	msg.items[?price > `10`] | sort_by(@, &price)[0:3]
+//	{name: msg.name, count: length(msg.items), total: sum(msg.items[*].price), productType: metadata.productType}
+//
+// 如果消息的dataType是json类型，消息体是解析后的值，否则是字符串
+// 查询结果转换成JSON替换消息体，通过`Success`链发送到下一个节点；查询失败则发送到`Failure`链
+type JsonQueryTransformNode struct {
+	//节点配置
+	Config JsonQueryTransformNodeConfiguration
+	query  *jmespath.JMESPath
+}
+
+// Type 组件类型
+func (x *JsonQueryTransformNode) Type() string {
+	return "jsonQueryTransform"
+}
+
+func (x *JsonQueryTransformNode) New() types.Node {
+	return &JsonQueryTransformNode{Config: JsonQueryTransformNodeConfiguration{
+		Query: "msg",
+	}}
+}
+
+// Init 初始化
+func (x *JsonQueryTransformNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.Config); err != nil {
+		return err
+	}
+	if strings.TrimSpace(x.Config.Query) == "" {
+		return fmt.Errorf("query is empty")
+	}
+	query, err := jmespath.Compile(x.Config.Query)
+	if err != nil {
+		return err
+	}
+	x.query = query
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *JsonQueryTransformNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var data interface{} = msg.Data
+	if msg.DataType == types.JSON {
+		var dataMap interface{}
+		if err := json.Unmarshal([]byte(msg.Data), &dataMap); err == nil {
+			data = dataMap
+		}
+	}
+	metadata := make(map[string]interface{})
+	for k, v := range msg.Metadata.Values() {
+		metadata[k] = v
+	}
+	result, err := x.query.Search(map[string]interface{}{
+		types.MsgKey:      data,
+		types.MetadataKey: metadata,
+		types.MsgTypeKey:  msg.Type,
+	})
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	value, err := json.Marshal(result)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	if x.Config.MetadataKey != "" {
+		if s, ok := result.(string); ok {
+			msg.Metadata.PutValue(x.Config.MetadataKey, s)
+		} else {
+			msg.Metadata.PutValue(x.Config.MetadataKey, string(value))
+		}
+	} else {
+		msg.Data = string(value)
+		msg.DataType = types.JSON
+	}
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *JsonQueryTransformNode) Destroy() {
+}
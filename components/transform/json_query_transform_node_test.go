@@ -0,0 +1,109 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"testing"
+)
+
+func TestJsonQueryTransformNode(t *testing.T) {
+	var targetNodeType = "jsonQueryTransform"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &JsonQueryTransformNode{}, types.Configuration{
+			"query": "msg",
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"query":       "msg.name",
+			"metadataKey": "name",
+		}, types.Configuration{
+			"query":       "msg.name",
+			"metadataKey": "name",
+		}, Registry)
+
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"query": " "}, Registry)
+		assert.Equal(t, "query is empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"query": "msg.items[?"}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("OnMsg", func(t *testing.T) {
+		metaData := types.NewMetadata()
+		metaData.PutValue("productType", "test")
+		data := `{"name":"aa","items":[{"name":"a","price":5},{"name":"b","price":30},{"name":"c","price":20},{"name":"d","price":12}]}`
+
+		var testcases = []struct {
+			configuration types.Configuration
+			expected      string
+			metadataValue string
+		}{
+			{
+				configuration: types.Configuration{"query": "{name: msg.name, productType: metadata.productType, msgType: msgType, count: length(msg.items), total: sum(msg.items[*].price)}"},
+				expected:      `{"count":4,"msgType":"ACTIVITY_EVENT","name":"aa","productType":"test","total":67}`,
+			},
+			{
+				configuration: types.Configuration{"query": "sort_by(msg.items[?price > `10`], &price)[0:2].name"},
+				expected:      `["d","c"]`,
+			},
+			{
+				configuration: types.Configuration{"query": "max_by(msg.items, &price)"},
+				expected:      `{"name":"b","price":30}`,
+			},
+			{
+				configuration: types.Configuration{"query": "msg.notFound"},
+				expected:      `null`,
+			},
+			{
+				configuration: types.Configuration{"query": "msg.items[-1].name", "metadataKey": "lastName"},
+				expected:      data,
+				metadataValue: "d",
+			},
+		}
+		for _, item := range testcases {
+			node, err := test.CreateAndInitNode(targetNodeType, item.configuration, Registry)
+			assert.Nil(t, err)
+			test.NodeOnMsg(t, node, []test.Msg{
+				{MetaData: metaData.Copy(), MsgType: "ACTIVITY_EVENT", Data: data},
+			}, func(msg types.RuleMsg, relationType string, err error) {
+				assert.Equal(t, types.Success, relationType)
+				assert.Equal(t, item.expected, msg.Data)
+				if item.metadataValue != "" {
+					assert.Equal(t, item.metadataValue, msg.Metadata.GetValue(item.configuration["metadataKey"].(string)))
+				}
+			})
+		}
+	})
+
+	t.Run("OnMsgErr", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"query": "sum(msg.items)",
+		}, Registry)
+		assert.Nil(t, err)
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: types.NewMetadata(), Data: `{"items":["a"]}`},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Failure, relationType)
+			assert.NotNil(t, err)
+		})
+	})
+}
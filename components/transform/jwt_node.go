@@ -0,0 +1,433 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+//规则链节点配置示例：校验请求头中的token
+//{
+//	"id": "s2",
+//	"type": "jwt",
+//	"name": "校验token",
+//	"debugMode": false,
+//		"configuration": {
+//			"mode": "verify",
+//			"algorithm": "RS256,ES256",
+//			"jwksFile": "./keys/jwks.json",
+//			"audience": "rulego",
+//			"issuer": "https://auth.example.com"
+//	}
+//}
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// JWT节点模式
+const (
+	//JwtVerify 校验token，并把claims保存到元数据
+	JwtVerify = "verify"
+	//JwtSign 根据claims模板签发token
+	JwtSign = "sign"
+)
+
+const (
+	//DefaultJwtTokenKey token所在的元数据key
+	DefaultJwtTokenKey = "Authorization"
+	//DefaultJwtTokenPrefix token前缀
+	DefaultJwtTokenPrefix = "Bearer "
+	//DefaultJwtClaimsPrefix claims保存到元数据的key前缀
+	DefaultJwtClaimsPrefix = "jwt_"
+)
+
+func init() {
+	Registry.Add(&JwtNode{})
+}
+
+// JwtNodeConfiguration 节点配置
+type JwtNodeConfiguration struct {
+	//Mode 模式：verify(校验token)、sign(签发token)
+	Mode string
+	//Algorithm 签名算法，支持HS256/HS384/HS512、RS256/RS384/RS512、ES256/ES384/ES512
+	//校验时可以使用`,`隔开指定多个允许的算法
+	Algorithm string
+	//Key 密钥，可以使用 ${global.xx} 从全局属性获取
+	//HS算法使用原始字符串，RS、ES算法使用PEM格式，签发时为私钥，校验时为公钥
+	Key string
+	//KeyFile 密钥文件路径，Key为空时使用
+	KeyFile string
+	//JwksFile 校验使用的JWKS文件路径，根据token头的kid选择公钥，配置后忽略Key和KeyFile
+	JwksFile string
+	//TokenKey token所在的元数据key，签发时把token写入该key，默认Authorization
+	TokenKey string
+	//TokenPrefix token前缀，校验时去掉该前缀，签发时添加该前缀，默认`Bearer `
+	TokenPrefix string
+	//Audience 校验时要求aud包含该值，签发时写入aud
+	Audience string
+	//Issuer 校验时要求iss等于该值，签发时写入iss
+	Issuer string
+	//Leeway 校验exp、nbf允许的时钟误差，单位秒
+	Leeway int64
+	//RequireExp 校验时是否要求token必须包含exp
+	RequireExp bool
+	//ClaimsPrefix 校验通过后claims保存到元数据的key前缀，默认jwt_，不能为空，避免claims覆盖其他元数据
+	//校验前会删除元数据中已有的该前缀的key，防止上游伪造claims
+	ClaimsPrefix string
+	//Claims 签发时的claims模板，字符串值可以使用 ${metadataKey} 替换元数据
+	Claims map[string]interface{}
+	//ExpiresIn 签发的token有效期，单位秒，<=0表示不设置exp
+	ExpiresIn int64
+	//Kid 签发时写入token头的kid
+	Kid string
+}
+
+// JwtNode JWT校验和签发节点
+// verify模式：从元数据获取token并校验签名、exp/nbf、aud、iss，校验通过把claims保存到元数据，
+// 字符串类型的claim直接保存，其他类型转换成JSON字符串
+// sign模式：根据claims模板签发token，并保存到元数据TokenKey
+// 成功通过`Success`链发送到下一个节点，失败则发送到`Failure`链
+type JwtNode struct {
+	//节点配置
+	Config JwtNodeConfiguration
+	method jwt.SigningMethod
+	//methods 校验允许的算法
+	methods []string
+	//signKey 签发密钥
+	signKey interface{}
+	//verifyKey 校验密钥
+	verifyKey interface{}
+	//jwks kid对应的校验密钥
+	jwks   map[string]interface{}
+	parser *jwt.Parser
+}
+
+// Type 组件类型
+func (x *JwtNode) Type() string {
+	return "jwt"
+}
+
+func (x *JwtNode) New() types.Node {
+	return &JwtNode{Config: JwtNodeConfiguration{
+		Mode:         JwtVerify,
+		Algorithm:    "HS256",
+		TokenKey:     DefaultJwtTokenKey,
+		TokenPrefix:  DefaultJwtTokenPrefix,
+		ClaimsPrefix: DefaultJwtClaimsPrefix,
+	}}
+}
+
+// Init 初始化
+func (x *JwtNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.Config); err != nil {
+		return err
+	}
+	if x.Config.TokenKey == "" {
+		x.Config.TokenKey = DefaultJwtTokenKey
+	}
+	if x.Config.ClaimsPrefix == "" {
+		x.Config.ClaimsPrefix = DefaultJwtClaimsPrefix
+	}
+	x.methods = nil
+	for _, alg := range strings.Split(x.Config.Algorithm, ",") {
+		alg = strings.TrimSpace(alg)
+		if alg == "" {
+			continue
+		}
+		if !isSupportedJwtAlgorithm(alg) {
+			return fmt.Errorf("unsupported algorithm: %s", alg)
+		}
+		x.methods = append(x.methods, alg)
+	}
+	if len(x.methods) == 0 {
+		return errors.New("algorithm is empty")
+	}
+	switch x.Config.Mode {
+	case JwtSign:
+		if len(x.methods) != 1 {
+			return errors.New("sign requires exactly one algorithm")
+		}
+		x.method = jwt.GetSigningMethod(x.methods[0])
+		key, err := loadKey(x.Config.Key, x.Config.KeyFile)
+		if err != nil {
+			return err
+		}
+		x.signKey, err = parseJwtKey(x.methods[0], key, true)
+		return err
+	case JwtVerify:
+		if x.Config.JwksFile != "" {
+			buf, err := os.ReadFile(x.Config.JwksFile)
+			if err != nil {
+				return err
+			}
+			if x.jwks, err = parseJwks(buf); err != nil {
+				return err
+			}
+		} else {
+			key, err := loadKey(x.Config.Key, x.Config.KeyFile)
+			if err != nil {
+				return err
+			}
+			if x.verifyKey, err = parseJwtKey(x.methods[0], key, false); err != nil {
+				return err
+			}
+			//同一个密钥只能用于同一类算法
+			for _, alg := range x.methods {
+				if alg[:2] != x.methods[0][:2] {
+					return errors.New("algorithms must be of the same family when using a single key")
+				}
+			}
+		}
+		opts := []jwt.ParserOption{jwt.WithValidMethods(x.methods), jwt.WithLeeway(time.Duration(x.Config.Leeway) * time.Second)}
+		if x.Config.Audience != "" {
+			opts = append(opts, jwt.WithAudience(x.Config.Audience))
+		}
+		if x.Config.Issuer != "" {
+			opts = append(opts, jwt.WithIssuer(x.Config.Issuer))
+		}
+		if x.Config.RequireExp {
+			opts = append(opts, jwt.WithExpirationRequired())
+		}
+		x.parser = jwt.NewParser(opts...)
+		return nil
+	default:
+		return fmt.Errorf("unsupported mode: %s", x.Config.Mode)
+	}
+}
+
+// OnMsg 处理消息
+func (x *JwtNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var err error
+	if x.Config.Mode == JwtSign {
+		err = x.sign(msg)
+	} else {
+		err = x.verify(msg)
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else {
+		ctx.TellSuccess(msg)
+	}
+}
+
+// Destroy 销毁
+func (x *JwtNode) Destroy() {
+}
+
+// verify 校验token，并把claims保存到元数据
+func (x *JwtNode) verify(msg types.RuleMsg) error {
+	tokenStr := strings.TrimSpace(msg.Metadata.GetValue(x.Config.TokenKey))
+	if prefix := x.Config.TokenPrefix; prefix != "" && len(tokenStr) >= len(prefix) && strings.EqualFold(tokenStr[:len(prefix)], prefix) {
+		tokenStr = strings.TrimSpace(tokenStr[len(prefix):])
+	}
+	//删除上游设置的claims，只保留token中的claims
+	for k := range msg.Metadata {
+		if strings.HasPrefix(k, x.Config.ClaimsPrefix) {
+			delete(msg.Metadata, k)
+		}
+	}
+	if tokenStr == "" {
+		return fmt.Errorf("metadata %s is empty", x.Config.TokenKey)
+	}
+	claims := jwt.MapClaims{}
+	if _, err := x.parser.ParseWithClaims(tokenStr, claims, x.keyFunc); err != nil {
+		return err
+	}
+	for k, v := range claims {
+		var value string
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			b, _ := json.Marshal(v)
+			value = string(b)
+		default:
+			value = str.ToString(v)
+		}
+		msg.Metadata.PutValue(x.Config.ClaimsPrefix+k, value)
+	}
+	return nil
+}
+
+// keyFunc 获取校验密钥
+func (x *JwtNode) keyFunc(token *jwt.Token) (interface{}, error) {
+	if x.jwks == nil {
+		return x.verifyKey, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" && len(x.jwks) == 1 {
+		for _, key := range x.jwks {
+			return key, nil
+		}
+	}
+	if key, ok := x.jwks[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("key not found for kid: %s", kid)
+}
+
+// sign 根据claims模板签发token
+func (x *JwtNode) sign(msg types.RuleMsg) error {
+	metadata := msg.Metadata.Values()
+	claims := jwt.MapClaims{}
+	for k, v := range x.Config.Claims {
+		if s, ok := v.(string); ok {
+			claims[k] = str.SprintfDict(s, metadata)
+		} else {
+			claims[k] = v
+		}
+	}
+	now := time.Now()
+	if x.Config.Issuer != "" {
+		claims["iss"] = x.Config.Issuer
+	}
+	if x.Config.Audience != "" {
+		claims["aud"] = x.Config.Audience
+	}
+	if x.Config.ExpiresIn > 0 {
+		claims["iat"] = now.Unix()
+		claims["exp"] = now.Add(time.Duration(x.Config.ExpiresIn) * time.Second).Unix()
+	}
+	token := jwt.NewWithClaims(x.method, claims)
+	if x.Config.Kid != "" {
+		token.Header["kid"] = x.Config.Kid
+	}
+	tokenStr, err := token.SignedString(x.signKey)
+	if err != nil {
+		return err
+	}
+	msg.Metadata.PutValue(x.Config.TokenKey, x.Config.TokenPrefix+tokenStr)
+	return nil
+}
+
+// isSupportedJwtAlgorithm 是否支持的算法
+func isSupportedJwtAlgorithm(alg string) bool {
+	switch alg {
+	case "HS256", "HS384", "HS512", "RS256", "RS384", "RS512", "ES256", "ES384", "ES512":
+		return true
+	default:
+		return false
+	}
+}
+
+// parseJwtKey 根据算法解析密钥，private表示是否是签发私钥
+func parseJwtKey(alg, key string, private bool) (interface{}, error) {
+	switch alg[:2] {
+	case "HS":
+		return []byte(key), nil
+	case "RS":
+		if private {
+			return jwt.ParseRSAPrivateKeyFromPEM([]byte(key))
+		}
+		return jwt.ParseRSAPublicKeyFromPEM([]byte(key))
+	default:
+		if private {
+			return jwt.ParseECPrivateKeyFromPEM([]byte(key))
+		}
+		return jwt.ParseECPublicKeyFromPEM([]byte(key))
+	}
+}
+
+// jsonWebKey JWKS中的密钥
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	//RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	//EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	//oct
+	K string `json:"k"`
+}
+
+// parseJwks 解析JWKS，返回kid对应的校验密钥，忽略用途不是签名的密钥
+func parseJwks(data []byte) (map[string]interface{}, error) {
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{})
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwk %s: %w", jwk.Kid, err)
+		}
+		result[jwk.Kid] = key
+	}
+	if len(result) == 0 {
+		return nil, errors.New("jwks has no signing keys")
+	}
+	return result, nil
+}
+
+// publicKey 转换成校验密钥
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+}
@@ -0,0 +1,260 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"github.com/rulego/rulego/utils/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// jwtSign 签发token并返回元数据中的token
+func jwtSign(t *testing.T, configuration types.Configuration, metaData types.Metadata) string {
+	node, err := test.CreateAndInitNode("jwt", configuration, Registry)
+	assert.Nil(t, err)
+	var token string
+	test.NodeOnMsg(t, node, []test.Msg{
+		{MetaData: metaData, Data: "{}"},
+	}, func(msg types.RuleMsg, relationType string, err error) {
+		assert.Equal(t, types.Success, relationType)
+		token = msg.Metadata.GetValue(DefaultJwtTokenKey)
+	})
+	return token
+}
+
+func TestJwtNode(t *testing.T) {
+	var targetNodeType = "jwt"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &JwtNode{}, types.Configuration{
+			"mode":         JwtVerify,
+			"algorithm":    "HS256",
+			"tokenKey":     DefaultJwtTokenKey,
+			"tokenPrefix":  DefaultJwtTokenPrefix,
+			"claimsPrefix": DefaultJwtClaimsPrefix,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"mode":      JwtSign,
+			"key":       "secret",
+			"expiresIn": 60,
+			"claims":    map[string]interface{}{"sub": "${deviceId}"},
+		}, types.Configuration{
+			"mode":      JwtSign,
+			"key":       "secret",
+			"expiresIn": int64(60),
+			"claims":    map[string]interface{}{"sub": "${deviceId}"},
+		}, Registry)
+
+		var testcases = []struct {
+			configuration types.Configuration
+			err           string
+		}{
+			{configuration: types.Configuration{"mode": "decode", "key": "secret"}, err: "unsupported mode: decode"},
+			{configuration: types.Configuration{"algorithm": "none", "key": "secret"}, err: "unsupported algorithm: none"},
+			{configuration: types.Configuration{"algorithm": " ", "key": "secret"}, err: "algorithm is empty"},
+			{configuration: types.Configuration{"algorithm": "HS256"}, err: "key is empty"},
+			{configuration: types.Configuration{"mode": JwtSign, "algorithm": "HS256,HS512", "key": "secret"}, err: "sign requires exactly one algorithm"},
+			{configuration: types.Configuration{"algorithm": "HS256,RS256", "key": "secret"}, err: "algorithms must be of the same family when using a single key"},
+			{configuration: types.Configuration{"mode": JwtSign, "algorithm": "RS256", "key": "secret"}, err: "invalid key: Key must be a PEM encoded PKCS1 or PKCS8 key"},
+			{configuration: types.Configuration{"jwksFile": "./notFound.json"}, err: "open ./notFound.json: no such file or directory"},
+		}
+		for _, item := range testcases {
+			_, err := test.CreateAndInitNode(targetNodeType, item.configuration, Registry)
+			assert.Equal(t, item.err, err.Error())
+		}
+	})
+
+	t.Run("HMAC", func(t *testing.T) {
+		metaData := types.NewMetadata()
+		metaData.PutValue("deviceId", "aa")
+		token := jwtSign(t, types.Configuration{
+			"mode":      JwtSign,
+			"key":       "secret",
+			"issuer":    "rulego",
+			"audience":  "device",
+			"expiresIn": 60,
+			"claims":    map[string]interface{}{"sub": "${deviceId}", "level": 2, "roles": []interface{}{"admin"}},
+		}, metaData)
+		assert.True(t, len(token) > len(DefaultJwtTokenPrefix))
+		assert.Equal(t, DefaultJwtTokenPrefix, token[:len(DefaultJwtTokenPrefix)])
+
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key":        "secret",
+			"issuer":     "rulego",
+			"audience":   "device",
+			"requireExp": true,
+		}, Registry)
+		assert.Nil(t, err)
+		metaData = types.NewMetadata()
+		metaData.PutValue(DefaultJwtTokenKey, token)
+		//上游伪造的claims会被删除
+		metaData.PutValue("jwt_role", "admin")
+		metaData.PutValue("jwt_sub", "bb")
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: metaData, Data: "{}"},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Success, relationType)
+			assert.False(t, msg.Metadata.Has("jwt_role"))
+			assert.Equal(t, "aa", msg.Metadata.GetValue("jwt_sub"))
+			assert.Equal(t, "2", msg.Metadata.GetValue("jwt_level"))
+			assert.Equal(t, `["admin"]`, msg.Metadata.GetValue("jwt_roles"))
+			assert.Equal(t, "rulego", msg.Metadata.GetValue("jwt_iss"))
+			assert.True(t, msg.Metadata.GetValue("jwt_exp") != "")
+		})
+
+		expired, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss": "rulego", "aud": "device", "exp": time.Now().Add(-time.Minute).Unix(),
+		}).SignedString([]byte("secret"))
+		noExp, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss": "rulego", "aud": "device",
+		}).SignedString([]byte("secret"))
+		wrongAud, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss": "rulego", "aud": "other", "exp": time.Now().Add(time.Minute).Unix(),
+		}).SignedString([]byte("secret"))
+		wrongKey, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss": "rulego", "aud": "device", "exp": time.Now().Add(time.Minute).Unix(),
+		}).SignedString([]byte("other"))
+		wrongAlg, _ := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
+			"iss": "rulego", "aud": "device", "exp": time.Now().Add(time.Minute).Unix(),
+		}).SignedString([]byte("secret"))
+
+		var testcases = []struct {
+			token string
+			err   error
+		}{
+			{token: expired, err: jwt.ErrTokenExpired},
+			{token: noExp, err: jwt.ErrTokenRequiredClaimMissing},
+			{token: wrongAud, err: jwt.ErrTokenInvalidAudience},
+			{token: wrongKey, err: jwt.ErrTokenSignatureInvalid},
+			{token: wrongAlg, err: jwt.ErrTokenSignatureInvalid},
+			{token: "aa.bb.cc", err: jwt.ErrTokenMalformed},
+		}
+		for _, item := range testcases {
+			metaData := types.NewMetadata()
+			metaData.PutValue(DefaultJwtTokenKey, item.token)
+			test.NodeOnMsg(t, node, []test.Msg{
+				{MetaData: metaData, Data: "{}"},
+			}, func(msg types.RuleMsg, relationType string, err error) {
+				assert.Equal(t, types.Failure, relationType)
+				assert.True(t, errors.Is(err, item.err))
+				assert.Equal(t, "", msg.Metadata.GetValue("jwt_iss"))
+			})
+		}
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: types.NewMetadata(), Data: "{}"},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Failure, relationType)
+			assert.Equal(t, "metadata Authorization is empty", err.Error())
+		})
+
+		//claims前缀为空使用默认前缀，claims不能覆盖其他元数据
+		node, err = test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"key":          "secret",
+			"claimsPrefix": "",
+		}, Registry)
+		assert.Nil(t, err)
+		assert.Equal(t, DefaultJwtClaimsPrefix, node.(*JwtNode).Config.ClaimsPrefix)
+		overwrite, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"deviceId": "bb",
+		}).SignedString([]byte("secret"))
+		metaData = types.NewMetadata()
+		metaData.PutValue(DefaultJwtTokenKey, overwrite)
+		metaData.PutValue("deviceId", "aa")
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: metaData, Data: "{}"},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Success, relationType)
+			assert.Equal(t, "aa", msg.Metadata.GetValue("deviceId"))
+			assert.Equal(t, "bb", msg.Metadata.GetValue("jwt_deviceId"))
+		})
+	})
+
+	t.Run("JWKS", func(t *testing.T) {
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.Nil(t, err)
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.Nil(t, err)
+		encode := func(b []byte) string {
+			return base64.RawURLEncoding.EncodeToString(b)
+		}
+		jwks, _ := json.Marshal(map[string]interface{}{
+			"keys": []interface{}{
+				map[string]interface{}{"kty": "RSA", "kid": "rsa1", "use": "sig", "n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+				map[string]interface{}{"kty": "EC", "kid": "ec1", "crv": "P-256", "x": encode(ecKey.X.Bytes()), "y": encode(ecKey.Y.Bytes())},
+				map[string]interface{}{"kty": "RSA", "kid": "enc1", "use": "enc", "n": "", "e": ""},
+			},
+		})
+		jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+		assert.Nil(t, os.WriteFile(jwksFile, jwks, 0600))
+
+		rsaPrivatePem, _ := pemKey(t, rsaKey, &rsaKey.PublicKey)
+		ecPrivatePem, _ := pemKey(t, ecKey, &ecKey.PublicKey)
+		rsaToken := jwtSign(t, types.Configuration{"mode": JwtSign, "algorithm": "RS256", "key": rsaPrivatePem, "kid": "rsa1", "tokenPrefix": "", "claims": map[string]interface{}{"sub": "rsa"}}, types.NewMetadata())
+		ecToken := jwtSign(t, types.Configuration{"mode": JwtSign, "algorithm": "ES256", "key": ecPrivatePem, "kid": "ec1", "claims": map[string]interface{}{"sub": "ec"}}, types.NewMetadata())
+		unknownKid := jwtSign(t, types.Configuration{"mode": JwtSign, "algorithm": "ES256", "key": ecPrivatePem, "kid": "ec2"}, types.NewMetadata())
+		//使用ec1的kid但算法不匹配
+		wrongAlg := jwtSign(t, types.Configuration{"mode": JwtSign, "algorithm": "RS256", "key": rsaPrivatePem, "kid": "ec1"}, types.NewMetadata())
+
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"algorithm":    "RS256,ES256",
+			"jwksFile":     jwksFile,
+			"claimsPrefix": "user.",
+		}, Registry)
+		assert.Nil(t, err)
+		for token, sub := range map[string]string{rsaToken: "rsa", ecToken: "ec"} {
+			metaData := types.NewMetadata()
+			metaData.PutValue(DefaultJwtTokenKey, token)
+			test.NodeOnMsg(t, node, []test.Msg{
+				{MetaData: metaData, Data: "{}"},
+			}, func(msg types.RuleMsg, relationType string, err error) {
+				assert.Equal(t, types.Success, relationType)
+				assert.Equal(t, sub, msg.Metadata.GetValue("user.sub"))
+			})
+		}
+		for _, token := range []string{unknownKid, wrongAlg} {
+			metaData := types.NewMetadata()
+			metaData.PutValue(DefaultJwtTokenKey, token)
+			test.NodeOnMsg(t, node, []test.Msg{
+				{MetaData: metaData, Data: "{}"},
+			}, func(msg types.RuleMsg, relationType string, err error) {
+				assert.Equal(t, types.Failure, relationType)
+				assert.NotNil(t, err)
+			})
+		}
+
+		invalidFile := filepath.Join(t.TempDir(), "invalid.json")
+		assert.Nil(t, os.WriteFile(invalidFile, []byte(`{"keys":[{"kty":"EC","kid":"ec1","crv":"P-192"}]}`), 0600))
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"algorithm": "ES256", "jwksFile": invalidFile}, Registry)
+		assert.Equal(t, "invalid jwk ec1: unsupported curve: P-192", err.Error())
+	})
+}
@@ -0,0 +1,187 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+//规则链节点配置示例：
+//{
+//	"id": "s2",
+//	"type": "protobuf",
+//	"name": "protobuf解码",
+//	"debugMode": false,
+//		"configuration": {
+//			"descriptorFile": "./proto/telemetry.pb",
+//			"messageType": "${messageType}",
+//			"operation": "decode"
+//	}
+//}
+import (
+	"errors"
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"github.com/rulego/rulego/utils/str"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"os"
+	"strings"
+)
+
+func init() {
+	Registry.Add(&ProtobufNode{})
+}
+
+// ProtobufNodeConfiguration 节点配置
+type ProtobufNodeConfiguration struct {
+	//DescriptorFile FileDescriptorSet文件路径，可以通过 `protoc --include_imports --descriptor_set_out=xx.pb xx.proto` 生成
+	DescriptorFile string
+	//MessageType 消息类型全名，例如：iot.Telemetry
+	//可以使用 ${metadataKey} 方式从元数据获取
+	MessageType string
+	//Operation 操作：decode(protobuf转换成JSON)、encode(JSON转换成protobuf)
+	Operation string
+	//UseProtoNames JSON字段是否使用proto中定义的字段名，否则使用lowerCamelCase字段名
+	UseProtoNames bool
+	//EmitUnpopulated 解码时是否输出值为默认值的字段
+	EmitUnpopulated bool
+}
+
+// ProtobufNode protobuf编解码节点，根据FileDescriptorSet动态解析消息，不需要生成代码
+// 解码后消息的dataType为JSON，编码后为BINARY
+// 转换成功通过`Success`链发送到下一个节点，失败则发送到`Failure`链
+type ProtobufNode struct {
+	//节点配置
+	Config ProtobufNodeConfiguration
+	files  *protoregistry.Files
+	types  *dynamicpb.Types
+	//messageType 不包含变量的消息类型，初始化时解析
+	messageType protoreflect.MessageDescriptor
+}
+
+// Type 组件类型
+func (x *ProtobufNode) Type() string {
+	return "protobuf"
+}
+
+func (x *ProtobufNode) New() types.Node {
+	return &ProtobufNode{Config: ProtobufNodeConfiguration{
+		Operation: CodecDecode,
+	}}
+}
+
+// Init 初始化
+func (x *ProtobufNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.Config); err != nil {
+		return err
+	}
+	if x.Config.Operation != CodecEncode && x.Config.Operation != CodecDecode {
+		return fmt.Errorf("unsupported operation: %s", x.Config.Operation)
+	}
+	if strings.TrimSpace(x.Config.DescriptorFile) == "" {
+		return errors.New("descriptorFile is empty")
+	}
+	if strings.TrimSpace(x.Config.MessageType) == "" {
+		return errors.New("messageType is empty")
+	}
+	buf, err := os.ReadFile(x.Config.DescriptorFile)
+	if err != nil {
+		return err
+	}
+	var fileSet descriptorpb.FileDescriptorSet
+	if err = proto.Unmarshal(buf, &fileSet); err != nil {
+		return err
+	}
+	if x.files, err = protodesc.NewFiles(&fileSet); err != nil {
+		return err
+	}
+	x.types = dynamicpb.NewTypes(x.files)
+	if !strings.Contains(x.Config.MessageType, "${") {
+		if x.messageType, err = x.findMessageType(x.Config.MessageType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *ProtobufNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	messageType := x.messageType
+	if messageType == nil {
+		var err error
+		if messageType, err = x.findMessageType(str.SprintfDict(x.Config.MessageType, msg.Metadata.Values())); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+	}
+	message := dynamicpb.NewMessage(messageType)
+	if x.Config.Operation == CodecEncode {
+		if err := (protojson.UnmarshalOptions{Resolver: x.types}).Unmarshal([]byte(msg.Data), message); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+		out, err := proto.Marshal(message)
+		if err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+		msg.Data = string(out)
+		msg.DataType = types.BINARY
+	} else {
+		if err := (proto.UnmarshalOptions{Resolver: x.types}).Unmarshal([]byte(msg.Data), message); err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+		out, err := protojson.MarshalOptions{
+			Resolver:        x.types,
+			UseProtoNames:   x.Config.UseProtoNames,
+			EmitUnpopulated: x.Config.EmitUnpopulated,
+		}.Marshal(message)
+		if err == nil {
+			//protojson输出的空白字符不固定，统一去掉
+			out, err = json.Compact(out)
+		}
+		if err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+		msg.Data = string(out)
+		msg.DataType = types.JSON
+	}
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *ProtobufNode) Destroy() {
+}
+
+// findMessageType 查找消息类型描述
+func (x *ProtobufNode) findMessageType(name string) (protoreflect.MessageDescriptor, error) {
+	desc, err := x.files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("message type %s not found: %w", name, err)
+	}
+	messageType, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message type", name)
+	}
+	return messageType, nil
+}
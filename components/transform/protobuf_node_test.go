@@ -0,0 +1,207 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestDescriptorSet 生成测试用的FileDescriptorSet文件
+func writeTestDescriptorSet(t *testing.T) string {
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("telemetry.proto"),
+		Package: proto.String("iot"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Telemetry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("device_id"), JsonName: proto.String("deviceId"), Number: proto.Int32(1), Label: optional, Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+					{Name: proto.String("temperature"), JsonName: proto.String("temperature"), Number: proto.Int32(2), Label: optional, Type: descriptorpb.FieldDescriptorProto_TYPE_DOUBLE.Enum()},
+					{Name: proto.String("ts"), JsonName: proto.String("ts"), Number: proto.Int32(3), Label: optional, Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum()},
+					{Name: proto.String("tags"), JsonName: proto.String("tags"), Number: proto.Int32(4), Label: repeated, Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(".iot.Tag")},
+					{Name: proto.String("status"), JsonName: proto.String("status"), Number: proto.Int32(5), Label: optional, Type: descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum(), TypeName: proto.String(".iot.Status")},
+				},
+			},
+			{
+				Name: proto.String("Tag"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("key"), JsonName: proto.String("key"), Number: proto.Int32(1), Label: optional, Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+					{Name: proto.String("value"), JsonName: proto.String("value"), Number: proto.Int32(2), Label: optional, Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+				},
+			},
+		},
+		EnumType: []*descriptorpb.EnumDescriptorProto{
+			{
+				Name: proto.String("Status"),
+				Value: []*descriptorpb.EnumValueDescriptorProto{
+					{Name: proto.String("UNKNOWN"), Number: proto.Int32(0)},
+					{Name: proto.String("ONLINE"), Number: proto.Int32(1)},
+				},
+			},
+		},
+	}
+	buf, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file}})
+	assert.Nil(t, err)
+	path := filepath.Join(t.TempDir(), "telemetry.pb")
+	assert.Nil(t, os.WriteFile(path, buf, 0644))
+	return path
+}
+
+func TestProtobufNode(t *testing.T) {
+	var targetNodeType = "protobuf"
+	descriptorFile := writeTestDescriptorSet(t)
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &ProtobufNode{}, types.Configuration{
+			"operation": CodecDecode,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"descriptorFile": descriptorFile,
+			"messageType":    "${messageType}",
+			"operation":      CodecEncode,
+		}, types.Configuration{
+			"descriptorFile": descriptorFile,
+			"messageType":    "${messageType}",
+			"operation":      CodecEncode,
+		}, Registry)
+
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"messageType": "iot.Telemetry"}, Registry)
+		assert.Equal(t, "descriptorFile is empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"descriptorFile": descriptorFile}, Registry)
+		assert.Equal(t, "messageType is empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"descriptorFile": descriptorFile, "messageType": "iot.NotFound"}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"descriptorFile": descriptorFile, "messageType": "iot.Status"}, Registry)
+		assert.Equal(t, "iot.Status is not a message type", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"descriptorFile": "./notFound.pb", "messageType": "iot.Telemetry"}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("OnMsg", func(t *testing.T) {
+		encoder, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"descriptorFile": descriptorFile,
+			"messageType":    "${messageType}",
+			"operation":      CodecEncode,
+		}, Registry)
+		assert.Nil(t, err)
+		decoder, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"descriptorFile": descriptorFile,
+			"messageType":    "${messageType}",
+		}, Registry)
+		assert.Nil(t, err)
+
+		var testcases = []struct {
+			messageType string
+			data        string
+		}{
+			{
+				messageType: "iot.Telemetry",
+				data:        `{"deviceId":"aa","temperature":25.5,"ts":"1700000000000","tags":[{"key":"area","value":"A"}],"status":"ONLINE"}`,
+			},
+			{
+				messageType: "iot.Tag",
+				data:        `{"key":"area","value":"B"}`,
+			},
+		}
+		for _, item := range testcases {
+			metaData := types.NewMetadata()
+			metaData.PutValue("messageType", item.messageType)
+			var encoded string
+			test.NodeOnMsg(t, encoder, []test.Msg{
+				{MetaData: metaData, Data: item.data},
+			}, func(msg types.RuleMsg, relationType string, err error) {
+				assert.Equal(t, types.Success, relationType)
+				assert.Equal(t, types.BINARY, msg.DataType)
+				encoded = msg.Data
+			})
+			test.NodeOnMsg(t, decoder, []test.Msg{
+				{MetaData: metaData, DataType: types.BINARY, Data: encoded},
+			}, func(msg types.RuleMsg, relationType string, err error) {
+				assert.Equal(t, types.Success, relationType)
+				assert.Equal(t, types.JSON, msg.DataType)
+				assert.Equal(t, item.data, msg.Data)
+			})
+		}
+	})
+
+	t.Run("OnMsgOptions", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"descriptorFile":  descriptorFile,
+			"messageType":     "iot.Telemetry",
+			"useProtoNames":   true,
+			"emitUnpopulated": true,
+		}, Registry)
+		assert.Nil(t, err)
+		//空消息所有字段都是默认值
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: types.NewMetadata(), DataType: types.BINARY, Data: ""},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Success, relationType)
+			assert.Equal(t, `{"device_id":"","temperature":0,"ts":"0","tags":[],"status":"UNKNOWN"}`, msg.Data)
+		})
+	})
+
+	t.Run("OnMsgErr", func(t *testing.T) {
+		decoder, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"descriptorFile": descriptorFile,
+			"messageType":    "${messageType}",
+		}, Registry)
+		assert.Nil(t, err)
+		encoder, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"descriptorFile": descriptorFile,
+			"messageType":    "iot.Telemetry",
+			"operation":      CodecEncode,
+		}, Registry)
+		assert.Nil(t, err)
+
+		metaData := types.NewMetadata()
+		metaData.PutValue("messageType", "iot.NotFound")
+		test.NodeOnMsg(t, decoder, []test.Msg{
+			{MetaData: metaData, Data: ""},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Failure, relationType)
+			assert.NotNil(t, err)
+		})
+		metaData.PutValue("messageType", "iot.Telemetry")
+		test.NodeOnMsg(t, decoder, []test.Msg{
+			{MetaData: metaData, Data: "\x0a\xff"},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Failure, relationType)
+			assert.NotNil(t, err)
+		})
+		test.NodeOnMsg(t, encoder, []test.Msg{
+			{MetaData: types.NewMetadata(), Data: `{"notFound":1}`},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Failure, relationType)
+			assert.NotNil(t, err)
+			assert.Equal(t, `{"notFound":1}`, msg.Data)
+		})
+	})
+}
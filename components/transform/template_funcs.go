@@ -0,0 +1,157 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+import (
+	"fmt"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/num"
+	"github.com/rulego/rulego/utils/str"
+	"github.com/rulego/rulego/utils/times"
+	"math"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateFuncs templateTransform 模板函数库，可以增加自定义函数，需要在节点初始化之前注册
+//
+// json：json(value) jsonParse(str)
+// 默认值：default(defaultValue, value)，value为空值时返回defaultValue，例如：{{ .msg.name | default "unknown" }}
+// 字符串：upper lower trim replace(str,old,new) split(str,sep) join(list,sep) contains(str,sub) hasPrefix hasSuffix toString
+// 时间，layout 使用 yyyy-MM-dd HH:mm:ss.SSS 风格或者go时间格式：
+// nowMilli() 当前毫秒时间戳；formatTime(ms,layout)；parseTime(str,layout) 返回毫秒时间戳
+// 数学：add sub mul div mod(a,b) round(places,x) toNumber(value)，例如：{{ .msg.temperature | round 1 }}
+var TemplateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		b, err := json.Marshal(value)
+		return string(b), err
+	},
+	"jsonParse": func(s string) (interface{}, error) {
+		var v interface{}
+		err := json.Unmarshal([]byte(s), &v)
+		return v, err
+	},
+	"default": func(defaultValue interface{}, value ...interface{}) interface{} {
+		if len(value) == 0 || isEmptyValue(value[0]) {
+			return defaultValue
+		}
+		return value[0]
+	},
+	"upper": func(value interface{}) string {
+		return strings.ToUpper(str.ToString(value))
+	},
+	"lower": func(value interface{}) string {
+		return strings.ToLower(str.ToString(value))
+	},
+	"trim": func(value interface{}) string {
+		return strings.TrimSpace(str.ToString(value))
+	},
+	"replace": func(s, old, new string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+	"split": strings.Split,
+	"join": func(list interface{}, sep string) string {
+		var items []string
+		v := reflect.ValueOf(list)
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			for i := 0; i < v.Len(); i++ {
+				items = append(items, str.ToString(v.Index(i).Interface()))
+			}
+		}
+		return strings.Join(items, sep)
+	},
+	"contains":  strings.Contains,
+	"hasPrefix": strings.HasPrefix,
+	"hasSuffix": strings.HasSuffix,
+	"toString":  str.ToString,
+	"nowMilli": func() int64 {
+		return time.Now().UnixMilli()
+	},
+	"formatTime": func(value interface{}, layout string) (string, error) {
+		if t, ok := value.(time.Time); ok {
+			return times.Format(t, layout), nil
+		}
+		ms, err := num.ToFloat64(value)
+		if err != nil {
+			return "", err
+		}
+		return times.Format(times.FromUnixMilli(int64(ms)), layout), nil
+	},
+	"parseTime": func(value string, layout string) (int64, error) {
+		t, err := times.Parse(layout, value)
+		if err != nil {
+			return 0, err
+		}
+		return t.UnixMilli(), nil
+	},
+	"add": mathTemplateFunc(func(a, b float64) (float64, error) { return a + b, nil }),
+	"sub": mathTemplateFunc(func(a, b float64) (float64, error) { return a - b, nil }),
+	"mul": mathTemplateFunc(func(a, b float64) (float64, error) { return a * b, nil }),
+	"div": mathTemplateFunc(func(a, b float64) (float64, error) {
+		if b == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return a / b, nil
+	}),
+	"mod": mathTemplateFunc(func(a, b float64) (float64, error) {
+		if b == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return math.Mod(a, b), nil
+	}),
+	"round": func(places int, value interface{}) (float64, error) {
+		v, err := num.ToFloat64(value)
+		if err != nil {
+			return 0, err
+		}
+		p := math.Pow(10, float64(places))
+		return math.Round(v*p) / p, nil
+	},
+	"toNumber": num.ToFloat64,
+}
+
+func mathTemplateFunc(f func(a, b float64) (float64, error)) func(a, b interface{}) (float64, error) {
+	return func(a, b interface{}) (float64, error) {
+		x, err := num.ToFloat64(a)
+		if err != nil {
+			return 0, err
+		}
+		y, err := num.ToFloat64(b)
+		if err != nil {
+			return 0, err
+		}
+		return f(x, y)
+	}
+}
+
+// isEmptyValue nil、空字符串、0、false、空集合都是空值
+func isEmptyValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	default:
+		return v.IsZero()
+	}
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+//规则链节点配置示例：
+//{
+//	"id": "s2",
+//	"type": "templateTransform",
+//	"name": "模板转换",
+//	"debugMode": false,
+//		"configuration": {
+//			"template": "设备{{ .metadata.deviceId }}温度{{ .msg.temperature | round 1 }}，时间：{{ formatTime .msg.ts \"yyyy-MM-dd HH:mm:ss\" }}"
+//	}
+//}
+import (
+	"bytes"
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"github.com/rulego/rulego/utils/maps"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// TemplateFileExt 从模板目录加载的模板文件扩展名
+const TemplateFileExt = ".tmpl"
+
+// 访问不存在的key时的处理方式
+const (
+	TemplateMissingKeyDefault = "default"
+	TemplateMissingKeyZero    = "zero"
+	TemplateMissingKeyError   = "error"
+)
+
+func init() {
+	Registry.Add(&TemplateTransformNode{})
+}
+
+// TemplateTransformNodeConfiguration 节点配置
+type TemplateTransformNodeConfiguration struct {
+	//Template go text/template 模板内容
+	Template string
+	//TemplateDir 模板目录，加载目录下所有*.tmpl文件，模板名称为文件名，例如：alarm.tmpl
+	//也可以在文件中通过{{define "name"}}定义模板，内联模板可以通过{{template "name" .}}引用
+	TemplateDir string
+	//TemplateName 执行的模板名称，为空则执行Template内联模板
+	TemplateName string
+	//MetadataKey 为空则模板执行结果替换消息体，否则把结果保存到metadata该key，消息体保持不变
+	MetadataKey string
+	//DataType 模板执行结果替换消息体后消息的dataType，为空则自动识别：JSON、TEXT或者BINARY
+	DataType string
+	//MissingKey 访问map不存在的key时的处理方式，参考text/template missingkey选项：
+	//default：输出<no value>，zero：输出类型零值，error：执行失败，发送到`Failure`链
+	MissingKey string
+}
+
+// TemplateTransformNode 使用go text/template模板生成消息内容，例如：邮件内容、SQL、HTTP请求体等
+// 模板可以访问以下变量：
+// .msg 消息体，如果消息的dataType是json类型，可以通过 .msg.XX 方式访问msg的字段
+// .metadata 消息元数据；.msgType 消息类型；.dataType 数据类型；.global 规则引擎全局属性`types.Config.Properties`
+// 可以使用`TemplateFuncs`函数库，例如：{{ json .msg }}、{{ .msg.name | default "unknown" | upper }}
+// 执行成功通过`Success`链发送到下一个节点，执行失败则发送到`Failure`链
+type TemplateTransformNode struct {
+	//节点配置
+	Config     TemplateTransformNodeConfiguration
+	ruleConfig types.Config
+	template   *template.Template
+}
+
+// Type 组件类型
+func (x *TemplateTransformNode) Type() string {
+	return "templateTransform"
+}
+
+func (x *TemplateTransformNode) New() types.Node {
+	return &TemplateTransformNode{Config: TemplateTransformNodeConfiguration{
+		Template:   "{{ json .msg }}",
+		MissingKey: TemplateMissingKeyDefault,
+	}}
+}
+
+// Init 初始化
+func (x *TemplateTransformNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.Config); err != nil {
+		return err
+	}
+	if strings.TrimSpace(x.Config.Template) == "" && x.Config.TemplateName == "" {
+		return fmt.Errorf("template and templateName are empty")
+	}
+	switch x.Config.MissingKey {
+	case "":
+		x.Config.MissingKey = TemplateMissingKeyDefault
+	case TemplateMissingKeyDefault, TemplateMissingKeyZero, TemplateMissingKeyError:
+	default:
+		return fmt.Errorf("unsupported missingKey: %s", x.Config.MissingKey)
+	}
+	tmpl := template.New(x.Type()).Funcs(TemplateFuncs).Option("missingkey=" + x.Config.MissingKey)
+	if x.Config.TemplateDir != "" {
+		files, err := filepath.Glob(filepath.Join(x.Config.TemplateDir, "*"+TemplateFileExt))
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("no %s files found in %s", TemplateFileExt, x.Config.TemplateDir)
+		}
+		if tmpl, err = tmpl.ParseFiles(files...); err != nil {
+			return err
+		}
+	}
+	if strings.TrimSpace(x.Config.Template) != "" {
+		if _, err := tmpl.Parse(x.Config.Template); err != nil {
+			return err
+		}
+	}
+	if x.Config.TemplateName != "" && tmpl.Lookup(x.Config.TemplateName) == nil {
+		return fmt.Errorf("template %s not found", x.Config.TemplateName)
+	}
+	x.template = tmpl
+	x.ruleConfig = ruleConfig
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *TemplateTransformNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	var data interface{} = msg.Data
+	if msg.DataType == types.JSON {
+		var dataMap interface{}
+		if err := json.Unmarshal([]byte(msg.Data), &dataMap); err == nil {
+			data = dataMap
+		}
+	}
+	var global map[string]string
+	if x.ruleConfig.Properties != nil {
+		global = x.ruleConfig.Properties.Values()
+	}
+	env := map[string]interface{}{
+		types.MsgKey:      data,
+		types.MetadataKey: msg.Metadata.Values(),
+		types.MsgTypeKey:  msg.Type,
+		types.DataTypeKey: msg.DataType,
+		"global":          global,
+	}
+	var buf bytes.Buffer
+	var err error
+	if x.Config.TemplateName != "" {
+		err = x.template.ExecuteTemplate(&buf, x.Config.TemplateName, env)
+	} else {
+		err = x.template.Execute(&buf, env)
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return
+	}
+	if x.Config.MetadataKey != "" {
+		msg.Metadata.PutValue(x.Config.MetadataKey, buf.String())
+	} else {
+		msg.Data = buf.String()
+		if x.Config.DataType != "" {
+			msg.DataType = types.DataType(x.Config.DataType)
+		} else {
+			msg.DataType = detectDataType(buf.Bytes())
+		}
+	}
+	ctx.TellSuccess(msg)
+}
+
+// Destroy 销毁
+func (x *TemplateTransformNode) Destroy() {
+}
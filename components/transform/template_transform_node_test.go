@@ -0,0 +1,181 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test"
+	"github.com/rulego/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplateTransformNode(t *testing.T) {
+	var targetNodeType = "templateTransform"
+
+	t.Run("NewNode", func(t *testing.T) {
+		test.NodeNew(t, targetNodeType, &TemplateTransformNode{}, types.Configuration{
+			"template":   "{{ json .msg }}",
+			"missingKey": TemplateMissingKeyDefault,
+		}, Registry)
+	})
+
+	t.Run("InitNode", func(t *testing.T) {
+		test.NodeInit(t, targetNodeType, types.Configuration{
+			"template":    "{{ .msg.name }}",
+			"metadataKey": "name",
+		}, types.Configuration{
+			"template":    "{{ .msg.name }}",
+			"metadataKey": "name",
+		}, Registry)
+	})
+
+	t.Run("InitErr", func(t *testing.T) {
+		_, err := test.CreateAndInitNode(targetNodeType, types.Configuration{"template": " "}, Registry)
+		assert.Equal(t, "template and templateName are empty", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"template": "{{ .msg.name "}, Registry)
+		assert.NotNil(t, err)
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"templateName": "notFound"}, Registry)
+		assert.Equal(t, "template notFound not found", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"missingKey": "invalid"}, Registry)
+		assert.Equal(t, "unsupported missingKey: invalid", err.Error())
+		_, err = test.CreateAndInitNode(targetNodeType, types.Configuration{"templateDir": t.TempDir()}, Registry)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("OnMsg", func(t *testing.T) {
+		config := types.NewConfig()
+		config.Properties.PutValue("site", "gz")
+		node := &TemplateTransformNode{}
+		err := node.Init(config, types.Configuration{
+			"template": strings.Join([]string{
+				"{{ .global.site }}/{{ .metadata.deviceId }}/{{ .msgType | lower }}",
+				"name={{ .msg.name | default \"unknown\" | upper }}",
+				"temperature={{ .msg.temperature | round 1 }} avg={{ div (add .msg.a .msg.b) 2 }}",
+				"time={{ formatTime .msg.ts \"yyyy-MM-dd\" }}",
+				"tags={{ join .msg.tags \",\" }}",
+				"{{ range .msg.items }}[{{ .id }}]{{ end }}",
+				"{{ json .msg.items }}",
+			}, "\n"),
+		})
+		assert.Nil(t, err)
+		metaData := types.NewMetadata()
+		metaData.PutValue("deviceId", "aa")
+		var result string
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: metaData, MsgType: "TELEMETRY", Data: `{"temperature":41.26,"a":1,"b":2,"ts":1704067200000,"tags":["x","y"],"items":[{"id":1},{"id":2}]}`},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Success, relationType)
+			result = msg.Data
+		})
+		assert.Equal(t, strings.Join([]string{
+			"gz/aa/telemetry",
+			"name=UNKNOWN",
+			"temperature=41.3 avg=1.5",
+			"time=2024-01-01",
+			"tags=x,y",
+			"[1][2]",
+			`[{"id":1},{"id":2}]`,
+		}, "\n"), result)
+	})
+
+	t.Run("TemplateDir", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "alarm.tmpl"), []byte(`{{ template "title" . }}: {{ .msg.temperature }}`), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "common.tmpl"), []byte(`{{ define "title" }}Alarm {{ .metadata.deviceId }}{{ end }}`), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "ignored.txt"), []byte(`{{ .msg.name `), 0644))
+
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"templateDir":  dir,
+			"templateName": "alarm.tmpl",
+			"template":     "",
+			"metadataKey":  "content",
+		}, Registry)
+		assert.Nil(t, err)
+		inlineNode, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"templateDir": dir,
+			"template":    `<{{ template "title" . }}>`,
+		}, Registry)
+		assert.Nil(t, err)
+		metaData := types.NewMetadata()
+		metaData.PutValue("deviceId", "aa")
+		msgList := []test.Msg{
+			{MetaData: metaData, Data: `{"temperature":41}`},
+		}
+		test.NodeOnMsg(t, node, msgList, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, "Alarm aa: 41", msg.Metadata.GetValue("content"))
+			assert.Equal(t, `{"temperature":41}`, msg.Data)
+		})
+		test.NodeOnMsg(t, inlineNode, msgList, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, "<Alarm aa>", msg.Data)
+		})
+	})
+
+	t.Run("DataType", func(t *testing.T) {
+		var dataTypes []types.DataType
+		for _, configuration := range []types.Configuration{
+			{"template": `{"name":"{{ .msg.name }}"}`},
+			{"template": `name={{ .msg.name }}`},
+			{"template": `{"name":"{{ .msg.name }}"}`, "dataType": string(types.TEXT)},
+		} {
+			node, err := test.CreateAndInitNode(targetNodeType, configuration, Registry)
+			assert.Nil(t, err)
+			test.NodeOnMsg(t, node, []test.Msg{
+				{MetaData: types.NewMetadata(), Data: `{"name":"aa"}`},
+			}, func(msg types.RuleMsg, relationType string, err error) {
+				dataTypes = append(dataTypes, msg.DataType)
+			})
+		}
+		assert.Equal(t, []types.DataType{types.JSON, types.TEXT, types.TEXT}, dataTypes)
+	})
+
+	t.Run("MissingKey", func(t *testing.T) {
+		var results []string
+		for _, missingKey := range []string{TemplateMissingKeyDefault, TemplateMissingKeyZero, TemplateMissingKeyError} {
+			node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+				"template":   "name={{ .metadata.name }}",
+				"missingKey": missingKey,
+			}, Registry)
+			assert.Nil(t, err)
+			test.NodeOnMsg(t, node, []test.Msg{
+				{MetaData: types.NewMetadata(), Data: `{"temperature":41}`},
+			}, func(msg types.RuleMsg, relationType string, err error) {
+				results = append(results, relationType+":"+msg.Data)
+			})
+		}
+		assert.Equal(t, []string{
+			types.Success + ":name=<no value>",
+			types.Success + ":name=",
+			types.Failure + `:{"temperature":41}`,
+		}, results)
+	})
+
+	t.Run("OnMsgErr", func(t *testing.T) {
+		node, err := test.CreateAndInitNode(targetNodeType, types.Configuration{
+			"template": "{{ div .msg.a 0 }}",
+		}, Registry)
+		assert.Nil(t, err)
+		test.NodeOnMsg(t, node, []test.Msg{
+			{MetaData: types.NewMetadata(), Data: `{"a":1}`},
+		}, func(msg types.RuleMsg, relationType string, err error) {
+			assert.Equal(t, types.Failure, relationType)
+			assert.NotNil(t, err)
+		})
+	})
+}
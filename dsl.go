@@ -87,6 +87,27 @@ type RuleNode struct {
 	//例如，一个JS过滤器节点可能有一个`jsScript`字段，定义了过滤逻辑，
 	//而一个REST API调用节点可能有一个`restEndpointUrlPattern`字段，定义了要调用的URL。
 	Configuration types.Configuration `json:"configuration"`
+	//WaitForAll 是否等待所有入节点的消息都到达后，才执行该节点。默认false：每个入节点的消息都会执行一次该节点
+	//同一条消息(消息ID相同)的多个分支汇聚到该节点时，按JoinOptions合并成一条消息后执行一次
+	//注意：分支按消息ID关联，如果分支中的节点创建了新消息(消息ID改变)，该分支的消息无法与其他分支合并，
+	//只能等待超时后通过`Failure`关系发送到下一个节点
+	WaitForAll bool `json:"waitForAll,omitempty"`
+	//JoinOptions 分支消息合并配置，WaitForAll=true时有效
+	JoinOptions *JoinOptions `json:"joinOptions,omitempty"`
+}
+
+// JoinOptions 分支消息合并配置
+type JoinOptions struct {
+	//Timeout 等待所有入节点消息的超时时间，单位毫秒，默认60000
+	//超时则把已到达的消息合并，通过该节点的`Failure`关系发送到下一个节点
+	Timeout int64 `json:"timeout"`
+	//MergeStrategy 消息体合并策略，默认object
+	//object：合并JSON对象消息体的字段，相同字段，按连接定义顺序后面的覆盖前面的
+	//array：按连接定义顺序合并成JSON数组，未到达的消息为null
+	//first：使用最先到达的消息体
+	//last：使用最后到达的消息体
+	//元数据按连接定义顺序合并
+	MergeStrategy string `json:"mergeStrategy"`
 }
 
 // ParserRuleNode 通过json解析节点结构体
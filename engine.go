@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/aspect"
+	"github.com/rulego/rulego/components/action"
 	"sync/atomic"
 	"time"
 )
@@ -138,12 +139,20 @@ func (ctx *DefaultRuleContext) From() types.NodeCtx {
 	return ctx.from
 }
 func (ctx *DefaultRuleContext) RuleChain() types.NodeCtx {
+	//避免返回包含nil指针的接口
+	if ctx.ruleChainCtx == nil {
+		return nil
+	}
 	return ctx.ruleChainCtx
 }
 func (ctx *DefaultRuleContext) Config() types.Config {
 	return ctx.config
 }
 
+func (ctx *DefaultRuleContext) StateStore() types.StateStore {
+	return ctx.config.StateStore
+}
+
 func (ctx *DefaultRuleContext) SetEndFunc(onEndFunc types.OnEndFunc) types.RuleContext {
 	ctx.onEnd = onEndFunc
 	return ctx
@@ -334,6 +343,14 @@ func (ctx *DefaultRuleContext) tellNext(msg types.RuleMsg, nextNode types.NodeCt
 
 	nextCtx := ctx.NewNextNodeRuleContext(nextNode)
 
+	//等待所有入节点的消息到达
+	if ctx.ruleChainCtx != nil {
+		var ok bool
+		if msg, ok = ctx.ruleChainCtx.join(nextCtx, msg); !ok {
+			return
+		}
+	}
+
 	//环绕aop
 	if !nextCtx.executeAroundAop(msg, relationType) {
 		return
@@ -655,6 +672,9 @@ func NewConfig(opts ...types.Option) types.Config {
 	if c.ComponentsRegistry == nil {
 		c.ComponentsRegistry = Registry
 	}
+	if c.HostFunctions == nil {
+		c.HostFunctions = action.Functions
+	}
 	return c
 }
 
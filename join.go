@@ -0,0 +1,264 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"fmt"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/utils/json"
+	"time"
+)
+
+// 分支消息合并策略
+const (
+	JoinMergeObject = "object"
+	JoinMergeArray  = "array"
+	JoinMergeFirst  = "first"
+	JoinMergeLast   = "last"
+)
+
+// defaultJoinTimeout 默认等待所有入节点消息的超时时间，单位毫秒
+const defaultJoinTimeout = 60000
+
+// joinKey 等待合并的消息标识，分支按消息ID关联，分支中创建的新消息不会与其他分支合并
+type joinKey struct {
+	nodeId string
+	msgId  string
+}
+
+// pendingJoin 等待合并的分支消息
+type pendingJoin struct {
+	//key:入节点ID
+	arrivals map[string]*joinArrival
+	//到达顺序
+	seq   int
+	timer *time.Timer
+}
+
+// joinArrival 已到达的分支消息
+type joinArrival struct {
+	ctx *DefaultRuleContext
+	msg types.RuleMsg
+	seq int
+}
+
+// checkJoinOptions 检查分支消息合并配置
+func checkJoinOptions(def *RuleNode) error {
+	if !def.WaitForAll || def.JoinOptions == nil {
+		return nil
+	}
+	switch def.JoinOptions.MergeStrategy {
+	case "", JoinMergeObject, JoinMergeArray, JoinMergeFirst, JoinMergeLast:
+		return nil
+	default:
+		return fmt.Errorf("node id=%s unsupported merge strategy:%s", def.Id, def.JoinOptions.MergeStrategy)
+	}
+}
+
+// join 如果节点配置了WaitForAll，则缓存分支消息，直到所有入节点的消息都到达
+// 返回true：执行该节点，msg为合并后的消息；false：该分支结束，不执行该节点
+func (rc *RuleChainCtx) join(ctx *DefaultRuleContext, msg types.RuleMsg) (types.RuleMsg, bool) {
+	nodeCtx, ok := ctx.self.(*RuleNodeCtx)
+	if !ok || !nodeCtx.SelfDefinition.WaitForAll || ctx.from == nil {
+		return msg, true
+	}
+	rc.RLock()
+	inNodeIds := rc.inNodeIds[ctx.self.GetNodeId()]
+	rc.RUnlock()
+	if len(inNodeIds) <= 1 {
+		return msg, true
+	}
+	options := nodeCtx.SelfDefinition.JoinOptions
+	if options == nil {
+		options = &JoinOptions{}
+	}
+	key := joinKey{nodeId: ctx.GetSelfId(), msgId: msg.Id}
+	var doneList []*DefaultRuleContext
+
+	rc.joinMu.Lock()
+	if rc.joins == nil {
+		rc.joins = make(map[joinKey]*pendingJoin)
+	}
+	pending, ok := rc.joins[key]
+	if !ok {
+		timeout := options.Timeout
+		if timeout <= 0 {
+			timeout = defaultJoinTimeout
+		}
+		pending = &pendingJoin{arrivals: make(map[string]*joinArrival)}
+		pending.timer = time.AfterFunc(time.Duration(timeout)*time.Millisecond, func() {
+			rc.joinTimeout(key, pending, inNodeIds, options)
+		})
+		rc.joins[key] = pending
+	}
+	fromId := ctx.from.GetNodeId().Id
+	if old, ok := pending.arrivals[fromId]; ok {
+		//同一个入节点重复到达，使用最新的消息
+		doneList = append(doneList, old.ctx)
+	}
+	pending.seq++
+	pending.arrivals[fromId] = &joinArrival{ctx: ctx, msg: msg, seq: pending.seq}
+	completed := true
+	for _, id := range inNodeIds {
+		if _, ok := pending.arrivals[id]; !ok {
+			completed = false
+			break
+		}
+	}
+	if completed {
+		pending.timer.Stop()
+		delete(rc.joins, key)
+		for _, item := range pending.arrivals {
+			if item.ctx != ctx {
+				doneList = append(doneList, item.ctx)
+			}
+		}
+	}
+	rc.joinMu.Unlock()
+
+	//合并到其他分支，结束当前分支
+	for _, item := range doneList {
+		item.childDone()
+	}
+	if !completed {
+		return msg, false
+	}
+	merged, err := mergeJoin(pending, inNodeIds, options.MergeStrategy)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return msg, false
+	}
+	return merged, true
+}
+
+// joinTimeout 等待超时，合并已到达的消息，通过最后到达分支的`Failure`关系发送到下一个节点
+func (rc *RuleChainCtx) joinTimeout(key joinKey, pending *pendingJoin, inNodeIds []string, options *JoinOptions) {
+	rc.joinMu.Lock()
+	if rc.joins[key] != pending {
+		rc.joinMu.Unlock()
+		return
+	}
+	delete(rc.joins, key)
+	rc.joinMu.Unlock()
+
+	var last *joinArrival
+	for _, item := range pending.arrivals {
+		if last == nil || item.seq > last.seq {
+			last = item
+		}
+	}
+	for _, item := range pending.arrivals {
+		if item != last {
+			item.ctx.childDone()
+		}
+	}
+	var missing []string
+	for _, id := range inNodeIds {
+		if _, ok := pending.arrivals[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	msg, err := mergeJoin(pending, inNodeIds, options.MergeStrategy)
+	if err != nil {
+		msg = last.msg
+	}
+	last.ctx.TellFailure(msg, fmt.Errorf("join timeout, waiting for nodes:%v", missing))
+}
+
+// stopJoins 停止所有等待合并的消息
+func (rc *RuleChainCtx) stopJoins() {
+	rc.joinMu.Lock()
+	defer rc.joinMu.Unlock()
+	for _, pending := range rc.joins {
+		pending.timer.Stop()
+	}
+	rc.joins = make(map[joinKey]*pendingJoin)
+}
+
+// mergeJoin 按连接定义顺序合并分支消息
+func mergeJoin(pending *pendingJoin, inNodeIds []string, strategy string) (types.RuleMsg, error) {
+	var ordered []*joinArrival
+	var first, last *joinArrival
+	metadata := types.NewMetadata()
+	for _, id := range inNodeIds {
+		item := pending.arrivals[id]
+		ordered = append(ordered, item)
+		if item == nil {
+			continue
+		}
+		for k, v := range item.msg.Metadata.Values() {
+			metadata.PutValue(k, v)
+		}
+		if first == nil || item.seq < first.seq {
+			first = item
+		}
+		if last == nil || item.seq > last.seq {
+			last = item
+		}
+	}
+	var msg types.RuleMsg
+	switch strategy {
+	case JoinMergeFirst:
+		msg = first.msg.Copy()
+	case JoinMergeLast:
+		msg = last.msg.Copy()
+	case JoinMergeArray:
+		var list = make([]interface{}, len(ordered))
+		for i, item := range ordered {
+			if item == nil {
+				continue
+			}
+			list[i] = item.msg.Data
+			if item.msg.DataType == types.JSON {
+				var v interface{}
+				if err := json.Unmarshal([]byte(item.msg.Data), &v); err == nil {
+					list[i] = v
+				}
+			}
+		}
+		data, err := json.Marshal(list)
+		if err != nil {
+			return msg, err
+		}
+		msg = first.msg.Copy()
+		msg.Data = string(data)
+		msg.DataType = types.JSON
+	default:
+		var result = make(map[string]interface{})
+		for _, item := range ordered {
+			if item == nil {
+				continue
+			}
+			var v map[string]interface{}
+			if err := json.Unmarshal([]byte(item.msg.Data), &v); err != nil {
+				return msg, fmt.Errorf("merge strategy=%s requires json object data", JoinMergeObject)
+			}
+			for k, fieldValue := range v {
+				result[k] = fieldValue
+			}
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			return msg, err
+		}
+		msg = first.msg.Copy()
+		msg.Data = string(data)
+		msg.DataType = types.JSON
+	}
+	msg.Metadata = metadata
+	return msg, nil
+}
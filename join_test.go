@@ -0,0 +1,193 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test/assert"
+	"github.com/rulego/rulego/utils/str"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var joinChainDsl = `
+{
+  "ruleChain": {
+    "id": "testJoin",
+    "name": "测试分支合并"
+  },
+  "metadata": {
+    "nodes": [
+      {
+        "id": "s1",
+        "type": "jsTransform",
+        "configuration": {
+          "jsScript": "return {'msg':msg,'metadata':metadata,'msgType':msgType};"
+        }
+      },
+      {
+        "id": "s2",
+        "type": "jsTransform",
+        "configuration": {
+          "jsScript": "metadata.from='s2';metadata.s2='true';return {'msg':{'a':1},'metadata':metadata,'msgType':msgType};"
+        }
+      },
+      {
+        "id": "s3",
+        "type": "jsFilter",
+        "configuration": {
+          "jsScript": "return msg.pass;"
+        }
+      },
+      {
+        "id": "s4",
+        "type": "jsTransform",
+        "configuration": {
+          "jsScript": "metadata.from='s4';metadata.s4='true';return {'msg':{'b':2},'metadata':metadata,'msgType':msgType};"
+        }
+      },
+      {
+        "id": "s5",
+        "type": "jsTransform",
+        "waitForAll": true,
+        "joinOptions": {
+          "timeout": 200,
+          "mergeStrategy": "%s"
+        },
+        "configuration": {
+          "jsScript": "metadata.count=String((parseInt(metadata.count)||0)+1);return {'msg':msg,'metadata':metadata,'msgType':msgType};"
+        }
+      }
+    ],
+    "connections": [
+      {"fromId": "s1", "toId": "s2", "type": "Success"},
+      {"fromId": "s1", "toId": "s3", "type": "Success"},
+      {"fromId": "s3", "toId": "s4", "type": "True"},
+      {"fromId": "s2", "toId": "s5", "type": "Success"},
+      {"fromId": "s4", "toId": "s5", "type": "Success"}
+    ]
+  }
+}
+`
+
+type joinResult struct {
+	msg          types.RuleMsg
+	relationType string
+	err          error
+}
+
+// runJoinChain 执行分支合并规则链，返回所有分支执行结果
+func runJoinChain(t *testing.T, mergeStrategy string, data string) []joinResult {
+	ruleEngine, err := New(str.RandomStr(10), []byte(strings.Replace(joinChainDsl, "%s", mergeStrategy, 1)))
+	assert.Nil(t, err)
+	defer Del(ruleEngine.Id)
+
+	var mu sync.Mutex
+	var results []joinResult
+	msg := types.NewMsg(0, "TEST_MSG_TYPE", types.JSON, types.NewMetadata(), data)
+	ruleEngine.OnMsgAndWait(msg, types.WithOnEnd(func(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) {
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, joinResult{msg: msg, relationType: relationType, err: err})
+	}))
+	mu.Lock()
+	defer mu.Unlock()
+	return results
+}
+
+func TestJoin(t *testing.T) {
+	t.Run("MergeObject", func(t *testing.T) {
+		results := runJoinChain(t, JoinMergeObject, `{"pass":true}`)
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, types.Success, results[0].relationType)
+		assert.Equal(t, `{"a":1,"b":2}`, results[0].msg.Data)
+		//按连接定义顺序合并元数据，只执行一次
+		assert.Equal(t, "s4", results[0].msg.Metadata.GetValue("from"))
+		assert.Equal(t, "true", results[0].msg.Metadata.GetValue("s2"))
+		assert.Equal(t, "true", results[0].msg.Metadata.GetValue("s4"))
+		assert.Equal(t, "1", results[0].msg.Metadata.GetValue("count"))
+	})
+
+	t.Run("MergeArray", func(t *testing.T) {
+		results := runJoinChain(t, JoinMergeArray, `{"pass":true}`)
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, `[{"a":1},{"b":2}]`, results[0].msg.Data)
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		//s3分支结束于False关系，s5等待超时
+		results := runJoinChain(t, JoinMergeArray, `{"pass":false}`)
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, types.False, results[0].relationType)
+		assert.Equal(t, types.Failure, results[1].relationType)
+		assert.NotNil(t, results[1].err)
+		assert.Equal(t, `[{"a":1},null]`, results[1].msg.Data)
+		//超时不执行该节点
+		assert.Equal(t, "", results[1].msg.Metadata.GetValue("count"))
+	})
+
+	t.Run("NewMsgId", func(t *testing.T) {
+		//s4创建了新消息，消息ID不同，无法与s2分支合并，各自等待超时
+		_ = Registry.Register(&NewMsgIdNode{})
+		dsl := strings.Replace(joinChainDsl, "%s", JoinMergeArray, 1)
+		dsl = strings.Replace(dsl, `"id": "s4",
+        "type": "jsTransform",`, `"id": "s4",
+        "type": "test/newMsgId",`, 1)
+		ruleEngine, err := New(str.RandomStr(10), []byte(dsl))
+		assert.Nil(t, err)
+		defer Del(ruleEngine.Id)
+
+		var mu sync.Mutex
+		var results []joinResult
+		msg := types.NewMsg(0, "TEST_MSG_TYPE", types.JSON, types.NewMetadata(), `{"pass":true}`)
+		ruleEngine.OnMsgAndWait(msg, types.WithOnEnd(func(ctx types.RuleContext, msg types.RuleMsg, err error, relationType string) {
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, joinResult{msg: msg, relationType: relationType, err: err})
+		}))
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, 2, len(results))
+		for _, item := range results {
+			assert.Equal(t, types.Failure, item.relationType)
+			assert.NotNil(t, item.err)
+		}
+	})
+
+	t.Run("UnsupportedStrategy", func(t *testing.T) {
+		_, err := New(str.RandomStr(10), []byte(strings.Replace(joinChainDsl, "%s", "aa", 1)))
+		assert.NotNil(t, err)
+	})
+}
+
+// NewMsgIdNode 创建新消息的节点
+type NewMsgIdNode struct {
+	BaseNode
+}
+
+func (n *NewMsgIdNode) Type() string {
+	return "test/newMsgId"
+}
+
+func (n *NewMsgIdNode) New() types.Node {
+	return &NewMsgIdNode{}
+}
+
+func (n *NewMsgIdNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	ctx.TellSuccess(types.NewMsg(0, msg.Type, msg.DataType, msg.Metadata.Copy(), msg.Data))
+}
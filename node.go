@@ -45,6 +45,9 @@ func InitRuleNodeCtx(config types.Config, selfDefinition *RuleNode) (*RuleNodeCt
 		if selfDefinition.Configuration == nil {
 			selfDefinition.Configuration = make(types.Configuration)
 		}
+		if err = checkJoinOptions(selfDefinition); err != nil {
+			return &RuleNodeCtx{}, err
+		}
 		if err = node.Init(config, processGlobalPlaceholders(config, selfDefinition.Configuration)); err != nil {
 			return &RuleNodeCtx{}, err
 		} else {
@@ -100,6 +103,8 @@ func (rn *RuleNodeCtx) Copy(newCtx *RuleNodeCtx) {
 	rn.SelfDefinition.Type = newCtx.SelfDefinition.Type
 	rn.SelfDefinition.DebugMode = newCtx.SelfDefinition.DebugMode
 	rn.SelfDefinition.Configuration = newCtx.SelfDefinition.Configuration
+	rn.SelfDefinition.WaitForAll = newCtx.SelfDefinition.WaitForAll
+	rn.SelfDefinition.JoinOptions = newCtx.SelfDefinition.JoinOptions
 }
 
 // 使用全局配置替换节点占位符配置，例如：${global.propertyKey}
@@ -0,0 +1,92 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileStore 基于文件持久化的状态存储
+// 数据保存在内存，每次写操作后以json格式整体写入文件(先写临时文件再重命名)，重启后从文件恢复
+// 适合key数量不多、写入不频繁的场景
+type FileStore struct {
+	*MemoryStore
+	path string
+	//保证文件写入顺序
+	fileMu sync.Mutex
+}
+
+// NewFileStore 创建文件状态存储，如果文件已存在则加载文件中的数据
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.items); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *FileStore) Set(key string, value interface{}, ttl time.Duration) error {
+	if err := s.MemoryStore.Set(key, value, ttl); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+func (s *FileStore) Incr(key string, delta float64, ttl time.Duration) (float64, error) {
+	value, err := s.MemoryStore.Incr(key, delta, ttl)
+	if err != nil {
+		return 0, err
+	}
+	return value, s.save()
+}
+
+func (s *FileStore) Delete(key string) error {
+	if err := s.MemoryStore.Delete(key); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+func (s *FileStore) save() error {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	data, err := json.Marshal(s.snapshot())
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package state 规则引擎跨消息状态存储实现
+package state
+
+import (
+	"fmt"
+	"github.com/rulego/rulego/utils/num"
+	"sync"
+	"time"
+)
+
+// sweepInterval 每执行多少次写操作清理一次过期key
+const sweepInterval = 1000
+
+type entry struct {
+	Value interface{} `json:"value"`
+	//ExpireAt 过期时间，unix毫秒，0表示永不过期
+	ExpireAt int64 `json:"expireAt,omitempty"`
+}
+
+func (e entry) expired(now int64) bool {
+	return e.ExpireAt > 0 && e.ExpireAt <= now
+}
+
+func expireAt(now int64, ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return now + ttl.Milliseconds()
+}
+
+// MemoryStore 内存状态存储，支持ttl
+// 过期key在读取时惰性删除，并且每隔一定写操作次数统一清理一次，不启动后台协程
+type MemoryStore struct {
+	mu     sync.Mutex
+	items  map[string]entry
+	writes int
+}
+
+// NewMemoryStore 创建内存状态存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: make(map[string]entry)}
+}
+
+func (s *MemoryStore) Get(key string) (interface{}, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if e.expired(time.Now().UnixMilli()) {
+		delete(s.items, key)
+		return nil, false, nil
+	}
+	return e.Value, true, nil
+}
+
+func (s *MemoryStore) Set(key string, value interface{}, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UnixMilli()
+	s.items[key] = entry{Value: value, ExpireAt: expireAt(now, ttl)}
+	s.afterWrite(now)
+	return nil
+}
+
+func (s *MemoryStore) Incr(key string, delta float64, ttl time.Duration) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UnixMilli()
+	var value float64
+	var exp int64
+	if e, ok := s.items[key]; ok && !e.expired(now) {
+		v, err := num.ToFloat64(e.Value)
+		if err != nil {
+			return 0, fmt.Errorf("state key=%s is not a number: %w", key, err)
+		}
+		value = v
+		exp = e.ExpireAt
+	}
+	value += delta
+	if ttl > 0 {
+		exp = expireAt(now, ttl)
+	}
+	s.items[key] = entry{Value: value, ExpireAt: exp}
+	s.afterWrite(now)
+	return value, nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, key)
+	return nil
+}
+
+// Len 返回当前key数量，包括还没被清理的过期key
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
+
+// afterWrite 写操作后按次数清理过期key，调用方需持有锁
+func (s *MemoryStore) afterWrite(now int64) {
+	s.writes++
+	if s.writes < sweepInterval {
+		return
+	}
+	s.writes = 0
+	for k, e := range s.items {
+		if e.expired(now) {
+			delete(s.items, k)
+		}
+	}
+}
+
+// snapshot 复制当前未过期的数据
+func (s *MemoryStore) snapshot() map[string]entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UnixMilli()
+	items := make(map[string]entry, len(s.items))
+	for k, e := range s.items {
+		if !e.expired(now) {
+			items[k] = e
+		}
+	}
+	return items
+}
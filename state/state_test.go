@@ -0,0 +1,94 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"github.com/rulego/rulego/test/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	_, ok, _ := s.Get("a")
+	assert.False(t, ok)
+
+	_ = s.Set("a", "aa", 0)
+	v, ok, _ := s.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "aa", v)
+
+	n, err := s.Incr("count", 1, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(1), n)
+	n, _ = s.Incr("count", 2.5, 0)
+	assert.Equal(t, 3.5, n)
+
+	_, err = s.Incr("a", 1, 0)
+	assert.NotNil(t, err)
+
+	_ = s.Delete("a")
+	_, ok, _ = s.Get("a")
+	assert.False(t, ok)
+
+	//过期
+	_ = s.Set("b", 1, time.Millisecond*50)
+	_, _ = s.Incr("c", 1, time.Millisecond*50)
+	time.Sleep(time.Millisecond * 80)
+	_, ok, _ = s.Get("b")
+	assert.False(t, ok)
+	n, _ = s.Incr("c", 1, 0)
+	assert.Equal(t, float64(1), n)
+
+	//批量清理过期key
+	for i := 0; i < sweepInterval; i++ {
+		_ = s.Set("d", i, time.Millisecond)
+	}
+	_ = s.Set("e", 1, time.Millisecond)
+	time.Sleep(time.Millisecond * 5)
+	for i := 0; i < sweepInterval; i++ {
+		_ = s.Set("f", i, 0)
+	}
+	assert.Equal(t, 3, s.Len())
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "state.json")
+	s, err := NewFileStore(path)
+	assert.Nil(t, err)
+	_ = s.Set("a", "aa", 0)
+	_, _ = s.Incr("count", 2, 0)
+	_ = s.Set("b", "bb", time.Millisecond*10)
+	_ = s.Set("c", "cc", 0)
+	_ = s.Delete("c")
+	time.Sleep(time.Millisecond * 20)
+	_ = s.Set("d", "dd", 0)
+
+	reloaded, err := NewFileStore(path)
+	assert.Nil(t, err)
+	v, ok, _ := reloaded.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "aa", v)
+	v, _, _ = reloaded.Get("count")
+	assert.Equal(t, float64(2), v)
+	_, ok, _ = reloaded.Get("b")
+	assert.False(t, ok)
+	_, ok, _ = reloaded.Get("c")
+	assert.False(t, ok)
+	assert.Equal(t, 3, reloaded.Len())
+}
@@ -111,6 +111,10 @@ func (ctx *NodeTestRuleContext) Config() types.Config {
 	return ctx.config
 }
 
+func (ctx *NodeTestRuleContext) StateStore() types.StateStore {
+	return ctx.config.StateStore
+}
+
 func (ctx *NodeTestRuleContext) SubmitTack(task func()) {
 	go task()
 }
@@ -0,0 +1,5 @@
+module.exports = {
+    multiply: function (a, b) {
+        return a * b;
+    }
+};
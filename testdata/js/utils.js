@@ -0,0 +1,5 @@
+var math = require('./lib/math');
+
+exports.double = function (value) {
+    return math.multiply(value, 2);
+};
@@ -21,6 +21,9 @@ import (
 	"encoding/json"
 )
 
+// RawMessage 延迟解析的原始json数据
+type RawMessage = json.RawMessage
+
 // Marshal marshals the struct to json data.
 // escapeHTML=false
 // disables this behavior.escape &, <, and > to \u0026, \u003c, and \u003e
@@ -54,3 +57,13 @@ func Format(jsonStr []byte) ([]byte, error) {
 	}
 	return buf.Bytes(), nil
 }
+
+// Compact 去掉json中的空白字符
+func Compact(jsonStr []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	err := json.Compact(&buf, jsonStr)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -70,3 +70,11 @@ func TestFormat(t *testing.T) {
 
 	assert.Equal(t, buf.Bytes(), result)
 }
+
+func TestCompact(t *testing.T) {
+	result, err := Compact([]byte("{\n  \"username\": \"test\",  \"age\" : 18\n}"))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"username":"test","age":18}`, string(result))
+	_, err = Compact([]byte(`{"username"`))
+	assert.NotNil(t, err)
+}
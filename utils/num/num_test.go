@@ -0,0 +1,52 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package num
+
+import (
+	"encoding/json"
+	"github.com/rulego/rulego/test/assert"
+	"testing"
+)
+
+func TestToFloat64(t *testing.T) {
+	var testcases = []struct {
+		value    interface{}
+		expected float64
+	}{
+		{value: 1.5, expected: 1.5},
+		{value: float32(2), expected: 2},
+		{value: 3, expected: 3},
+		{value: int64(-4), expected: -4},
+		{value: uint8(5), expected: 5},
+		{value: json.Number("6.5"), expected: 6.5},
+		{value: true, expected: 1},
+		{value: false, expected: 0},
+		{value: " 7.25 ", expected: 7.25},
+		{value: []byte("8"), expected: 8},
+	}
+	for _, item := range testcases {
+		v, err := ToFloat64(item.value)
+		assert.Nil(t, err)
+		assert.Equal(t, item.expected, v)
+	}
+	_, err := ToFloat64("aa")
+	assert.NotNil(t, err)
+	_, err = ToFloat64(nil)
+	assert.NotNil(t, err)
+	_, err = ToFloat64(map[string]interface{}{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package times
+
+import (
+	"strings"
+	"time"
+)
+
+// goLayoutReferenceYear go 时间格式参考年份，包含该值的格式认为是go原生时间格式
+const goLayoutReferenceYear = "2006"
+
+// 日期格式占位符与go时间格式对应关系
+var layoutTokens = map[string]string{
+	"yyyy": "2006",
+	"yy":   "06",
+	"MM":   "01",
+	"M":    "1",
+	"dd":   "02",
+	"d":    "2",
+	"HH":   "15",
+	"H":    "15",
+	"hh":   "03",
+	"h":    "3",
+	"mm":   "04",
+	"m":    "4",
+	"ss":   "05",
+	"s":    "5",
+	"SSS":  "000",
+	"a":    "PM",
+	"Z":    "-0700",
+}
+
+// ToGoLayout 把 yyyy-MM-dd HH:mm:ss.SSS 风格的日期格式转换成go时间格式
+// 支持：yyyy、yy、MM、M、dd、d、HH、H、hh、h、mm、m、ss、s、SSS、a、Z，其他字符原样保留
+// 如果pattern已经是go时间格式(包含2006)，则直接返回
+func ToGoLayout(pattern string) string {
+	if strings.Contains(pattern, goLayoutReferenceYear) {
+		return pattern
+	}
+	var builder strings.Builder
+	for i := 0; i < len(pattern); {
+		j := i + 1
+		for j < len(pattern) && pattern[j] == pattern[i] {
+			j++
+		}
+		token := pattern[i:j]
+		if v, ok := layoutTokens[token]; ok {
+			builder.WriteString(v)
+		} else {
+			builder.WriteString(token)
+		}
+		i = j
+	}
+	return builder.String()
+}
+
+// Format 使用 yyyy-MM-dd HH:mm:ss 风格的日期格式格式化时间
+func Format(t time.Time, pattern string) string {
+	return t.Format(ToGoLayout(pattern))
+}
+
+// Parse 使用 yyyy-MM-dd HH:mm:ss 风格的日期格式解析时间，使用本地时区
+func Parse(pattern string, value string) (time.Time, error) {
+	return time.ParseInLocation(ToGoLayout(pattern), value, time.Local)
+}
+
+// FromUnixMilli 毫秒时间戳转时间
+func FromUnixMilli(ms int64) time.Time {
+	return time.Unix(ms/1e3, (ms%1e3)*int64(time.Millisecond))
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package times
+
+import (
+	"github.com/rulego/rulego/test/assert"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, "2006-01-02 15:04:05.000", ToGoLayout("yyyy-MM-dd HH:mm:ss.SSS"))
+	assert.Equal(t, "060102", ToGoLayout("yyMMdd"))
+	assert.Equal(t, "2006/01/02", ToGoLayout("2006/01/02"))
+
+	tm := time.Date(2024, 3, 5, 8, 9, 10, 0, time.Local)
+	assert.Equal(t, "2024-03-05 08:09:10", Format(tm, "yyyy-MM-dd HH:mm:ss"))
+	assert.Equal(t, "20240305", Format(tm, "yyyyMMdd"))
+
+	parsed, err := Parse("yyyy-MM-dd HH:mm:ss", "2024-03-05 08:09:10")
+	assert.Nil(t, err)
+	assert.True(t, tm.Equal(parsed))
+	_, err = Parse("yyyy-MM-dd", "aa")
+	assert.NotNil(t, err)
+
+	assert.True(t, tm.Equal(FromUnixMilli(tm.UnixMilli())))
+}